@@ -0,0 +1,13 @@
+--
+-- Wildcard grants: "*" as an action or resource name matches any action or
+-- resource, so "*:role", "view:*" and "*:*" can be assigned through the
+-- existing assign_role_permission function.
+--
+
+INSERT INTO public.actions (name, description)
+SELECT '*', 'Wildcard: matches every action'
+WHERE NOT EXISTS (SELECT 1 FROM public.actions WHERE name = '*');
+
+INSERT INTO public.resources (name, description)
+SELECT '*', 'Wildcard: matches every resource'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = '*');
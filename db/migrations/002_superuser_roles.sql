@@ -0,0 +1,71 @@
+--
+-- Superuser roles: replaces the hardcoded super_admin name check. Requests
+-- let through only because of this flag are recorded in rbac_audit_logs.
+--
+
+ALTER TABLE public.roles
+    ADD COLUMN IF NOT EXISTS is_superuser boolean DEFAULT false NOT NULL;
+
+UPDATE public.roles SET is_superuser = true WHERE name = 'super_admin';
+
+-- Flagging a role as superuser needs its own permission: update:role alone
+-- must not be enough to bypass every check.
+INSERT INTO public.resources (name, description)
+SELECT 'superuser', 'Superuser flag of roles'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = 'superuser');
+
+INSERT INTO public.permissions (resource_id, action_id)
+SELECT res.id, a.id FROM public.resources res, public.actions a
+WHERE res.name = 'superuser' AND a.name = 'update'
+  AND NOT EXISTS (SELECT 1 FROM public.permissions p WHERE p.resource_id = res.id AND p.action_id = a.id);
+
+CREATE TABLE IF NOT EXISTS public.rbac_audit_logs (
+    id serial PRIMARY KEY,
+    event character varying(50) NOT NULL,
+    actor character varying(255),
+    target character varying(255),
+    detail jsonb DEFAULT '{}'::jsonb NOT NULL,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS rbac_audit_logs_event_created_at_idx
+    ON public.rbac_audit_logs (event, created_at);
+
+ALTER TABLE public.rbac_audit_logs OWNER TO postgres;
+
+CREATE OR REPLACE FUNCTION public.get_user_by_username(p_username text) RETURNS jsonb
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    v_result JSONB;
+BEGIN
+    SELECT jsonb_build_object(
+        'id', u.id,
+        'username', u.username,
+        'staff_id', u.staff_id,
+        'first_name', u.first_name,
+        'middle_name', u.middle_name,
+        'last_name', u.last_name,
+        'email', u.email,
+        'role_id', u.role_id,
+        'role_name', r.name,
+        'is_superuser', COALESCE(r.is_superuser, false),
+        'permissions', COALESCE(
+            (
+                SELECT ARRAY_AGG(DISTINCT CONCAT(a.name, ':', res.name) ORDER BY CONCAT(a.name, ':', res.name))
+                FROM role_permissions rp
+                JOIN permissions p ON rp.permission_id = p.id
+                JOIN actions a ON p.action_id = a.id
+                JOIN resources res ON p.resource_id = res.id
+                WHERE rp.role_id = u.role_id
+            ),
+            ARRAY[]::TEXT[]
+        )
+    ) INTO v_result
+    FROM users u
+    LEFT JOIN roles r ON u.role_id = r.id
+    WHERE u.username = p_username;
+
+    RETURN v_result;
+END;
+$$;
@@ -0,0 +1,160 @@
+--
+-- Role hierarchy: a role inherits every permission of its parent chain.
+-- role_effective_permissions is the single place effective grants are
+-- computed; the JSON functions and get_user_by_username read from it.
+--
+
+ALTER TABLE public.roles
+    ADD COLUMN IF NOT EXISTS parent_role_id integer
+    REFERENCES public.roles(id) ON DELETE SET NULL;
+
+-- Ancestors of a role, the role itself at depth 0. UNION plus the path check
+-- keeps the walk finite even if a cycle slipped in.
+CREATE OR REPLACE FUNCTION public.role_ancestors(p_role_id integer)
+RETURNS TABLE(role_id integer, role_name text, depth integer)
+    LANGUAGE sql STABLE
+    AS $$
+    WITH RECURSIVE chain AS (
+        SELECT r.id, r.name::text, r.parent_role_id, 0 AS depth, ARRAY[r.id] AS path
+        FROM roles r
+        WHERE r.id = p_role_id
+        UNION ALL
+        SELECT p.id, p.name::text, p.parent_role_id, c.depth + 1, c.path || p.id
+        FROM roles p
+        JOIN chain c ON p.id = c.parent_role_id
+        WHERE NOT p.id = ANY(c.path)
+    )
+    SELECT id, name, depth FROM chain;
+$$;
+
+ALTER FUNCTION public.role_ancestors(p_role_id integer) OWNER TO postgres;
+
+-- Effective grants of a role. A permission held directly and through a
+-- parent is reported once, from the nearest role.
+CREATE OR REPLACE FUNCTION public.role_effective_permissions(p_role_id integer)
+RETURNS TABLE(action_name text, resource_name text, source_role_id integer, source_role_name text, inherited boolean)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT DISTINCT ON (a.name, res.name)
+        a.name::text,
+        res.name::text,
+        anc.role_id,
+        anc.role_name,
+        anc.depth > 0
+    FROM role_ancestors(p_role_id) anc
+    JOIN role_permissions rp ON rp.role_id = anc.role_id
+    JOIN permissions p ON rp.permission_id = p.id
+    JOIN actions a ON p.action_id = a.id
+    JOIN resources res ON p.resource_id = res.id
+    ORDER BY a.name, res.name, anc.depth;
+$$;
+
+ALTER FUNCTION public.role_effective_permissions(p_role_id integer) OWNER TO postgres;
+
+-- Safety net for concurrent parent updates; the API checks first so users
+-- normally get a clean 409 instead of this exception.
+CREATE OR REPLACE FUNCTION public.roles_prevent_cycle() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF NEW.parent_role_id IS NOT NULL AND EXISTS (
+        SELECT 1 FROM role_ancestors(NEW.parent_role_id) WHERE role_id = NEW.id
+    ) THEN
+        RAISE EXCEPTION 'role hierarchy cycle: role % cannot inherit from %', NEW.id, NEW.parent_role_id;
+    END IF;
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS roles_prevent_cycle ON public.roles;
+CREATE TRIGGER roles_prevent_cycle
+    BEFORE INSERT OR UPDATE OF parent_role_id ON public.roles
+    FOR EACH ROW EXECUTE FUNCTION public.roles_prevent_cycle();
+
+CREATE OR REPLACE FUNCTION public.get_user_by_username(p_username text) RETURNS jsonb
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    v_result JSONB;
+BEGIN
+    SELECT jsonb_build_object(
+        'id', u.id,
+        'username', u.username,
+        'staff_id', u.staff_id,
+        'first_name', u.first_name,
+        'middle_name', u.middle_name,
+        'last_name', u.last_name,
+        'email', u.email,
+        'role_id', u.role_id,
+        'role_name', r.name,
+        'is_superuser', COALESCE(r.is_superuser, false),
+        'permissions', COALESCE(
+            (
+                SELECT ARRAY_AGG(CONCAT(ep.action_name, ':', ep.resource_name) ORDER BY CONCAT(ep.action_name, ':', ep.resource_name))
+                FROM role_effective_permissions(u.role_id) ep
+            ),
+            ARRAY[]::TEXT[]
+        )
+    ) INTO v_result
+    FROM users u
+    LEFT JOIN roles r ON u.role_id = r.id
+    WHERE u.username = p_username;
+
+    RETURN v_result;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_role_permissions_json(p_role_id integer) RETURNS jsonb
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    v_role_name text;
+    v_parent_name text;
+    v_permissions_json jsonb;
+BEGIN
+    -- Get role name
+    SELECT r.name, parent.name INTO v_role_name, v_parent_name
+    FROM roles r
+    LEFT JOIN roles parent ON parent.id = r.parent_role_id
+    WHERE r.id = p_role_id;
+
+    -- Raise if role not found
+    IF v_role_name IS NULL THEN
+        RAISE EXCEPTION 'Role with ID % not found', p_role_id;
+    END IF;
+
+    -- Get direct and inherited permissions
+    SELECT jsonb_agg(
+        jsonb_build_object(
+            'resource', ep.resource_name,
+            'action', ep.action_name,
+            'formatted', CONCAT(ep.action_name, ':', ep.resource_name),
+            'inherited', ep.inherited,
+            'inherited_from', CASE WHEN ep.inherited THEN ep.source_role_name END
+        )
+        ORDER BY ep.resource_name, ep.action_name
+    ) INTO v_permissions_json
+    FROM role_effective_permissions(p_role_id) ep;
+
+    -- Return result
+    RETURN jsonb_build_object(
+        'role', v_role_name,
+        'parent_role', v_parent_name,
+        'permissions', COALESCE(v_permissions_json, '[]'::jsonb)
+    );
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_all_roles_permissions_json() RETURNS jsonb
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    v_result jsonb;
+BEGIN
+    SELECT jsonb_agg(get_role_permissions_json(r.id) ORDER BY r.name)
+    INTO v_result
+    FROM roles r;
+
+    RETURN COALESCE(v_result, '[]'::jsonb);
+END;
+$$;
@@ -0,0 +1,84 @@
+--
+-- Multiple roles per user. users.role_id stays as the primary role for older
+-- clients and is mirrored in user_roles; effective permissions are the union
+-- over every assigned role (and their parents).
+--
+
+CREATE TABLE IF NOT EXISTS public.user_roles (
+    id serial PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    role_id integer NOT NULL REFERENCES public.roles(id) ON DELETE CASCADE,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_roles_user_role_key UNIQUE (user_id, role_id)
+);
+
+ALTER TABLE public.user_roles OWNER TO postgres;
+
+INSERT INTO public.user_roles (user_id, role_id)
+SELECT id, role_id FROM public.users WHERE role_id IS NOT NULL
+ON CONFLICT (user_id, role_id) DO NOTHING;
+
+-- Roles currently held by a user.
+CREATE OR REPLACE FUNCTION public.user_active_roles(p_user_id integer)
+RETURNS TABLE(role_id integer)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT ur.role_id FROM user_roles ur WHERE ur.user_id = p_user_id
+    UNION
+    SELECT u.role_id FROM users u WHERE u.id = p_user_id AND u.role_id IS NOT NULL;
+$$;
+
+ALTER FUNCTION public.user_active_roles(p_user_id integer) OWNER TO postgres;
+
+CREATE OR REPLACE FUNCTION public.get_user_by_username(p_username text) RETURNS jsonb
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    v_result JSONB;
+BEGIN
+    SELECT jsonb_build_object(
+        'id', u.id,
+        'username', u.username,
+        'staff_id', u.staff_id,
+        'first_name', u.first_name,
+        'middle_name', u.middle_name,
+        'last_name', u.last_name,
+        'email', u.email,
+        'role_id', u.role_id,
+        'role_name', r.name,
+        'roles', COALESCE(
+            (
+                SELECT jsonb_agg(jsonb_build_object(
+                    'id', ar.id,
+                    'name', ar.name,
+                    'is_superuser', ar.is_superuser
+                ) ORDER BY ar.name)
+                FROM user_active_roles(u.id) uar
+                JOIN roles ar ON ar.id = uar.role_id
+            ),
+            '[]'::jsonb
+        ),
+        'is_superuser', COALESCE(
+            (
+                SELECT bool_or(ar.is_superuser)
+                FROM user_active_roles(u.id) uar
+                JOIN roles ar ON ar.id = uar.role_id
+            ),
+            false
+        ),
+        'permissions', COALESCE(
+            (
+                SELECT ARRAY_AGG(DISTINCT CONCAT(ep.action_name, ':', ep.resource_name) ORDER BY CONCAT(ep.action_name, ':', ep.resource_name))
+                FROM user_active_roles(u.id) uar
+                CROSS JOIN LATERAL role_effective_permissions(uar.role_id) ep
+            ),
+            ARRAY[]::TEXT[]
+        )
+    ) INTO v_result
+    FROM users u
+    LEFT JOIN roles r ON u.role_id = r.id
+    WHERE u.username = p_username;
+
+    RETURN v_result;
+END;
+$$;
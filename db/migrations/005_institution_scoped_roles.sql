@@ -0,0 +1,92 @@
+--
+-- Institution-scoped role assignments. A NULL institution_code keeps the
+-- assignment valid everywhere; otherwise it only applies to tokens issued
+-- for that institution. users.role_id is always treated as global.
+--
+
+ALTER TABLE public.user_roles
+    ADD COLUMN IF NOT EXISTS institution_code character varying(50);
+
+ALTER TABLE public.user_roles
+    DROP CONSTRAINT IF EXISTS user_roles_user_role_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_roles_user_role_institution_key
+    ON public.user_roles (user_id, role_id, COALESCE(institution_code, ''));
+
+DROP FUNCTION IF EXISTS public.user_active_roles(integer);
+
+CREATE FUNCTION public.user_active_roles(p_user_id integer)
+RETURNS TABLE(role_id integer, institution_code text)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT ur.role_id, ur.institution_code::text FROM user_roles ur WHERE ur.user_id = p_user_id
+    UNION
+    SELECT u.role_id, NULL::text FROM users u WHERE u.id = p_user_id AND u.role_id IS NOT NULL;
+$$;
+
+ALTER FUNCTION public.user_active_roles(p_user_id integer) OWNER TO postgres;
+
+CREATE OR REPLACE FUNCTION public.get_user_by_username(p_username text) RETURNS jsonb
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    v_result JSONB;
+BEGIN
+    SELECT jsonb_build_object(
+        'id', u.id,
+        'username', u.username,
+        'staff_id', u.staff_id,
+        'first_name', u.first_name,
+        'middle_name', u.middle_name,
+        'last_name', u.last_name,
+        'email', u.email,
+        'role_id', u.role_id,
+        'role_name', r.name,
+        'roles', COALESCE(
+            (
+                SELECT jsonb_agg(jsonb_build_object(
+                    'id', ar.id,
+                    'name', ar.name,
+                    'is_superuser', ar.is_superuser,
+                    'institution_code', uar.institution_code
+                ) ORDER BY ar.name, uar.institution_code)
+                FROM user_active_roles(u.id) uar
+                JOIN roles ar ON ar.id = uar.role_id
+            ),
+            '[]'::jsonb
+        ),
+        'is_superuser', COALESCE(
+            (
+                SELECT bool_or(ar.is_superuser)
+                FROM user_active_roles(u.id) uar
+                JOIN roles ar ON ar.id = uar.role_id
+            ),
+            false
+        ),
+        'permissions', COALESCE(
+            (
+                SELECT ARRAY_AGG(DISTINCT CONCAT(ep.action_name, ':', ep.resource_name) ORDER BY CONCAT(ep.action_name, ':', ep.resource_name))
+                FROM user_active_roles(u.id) uar
+                CROSS JOIN LATERAL role_effective_permissions(uar.role_id) ep
+            ),
+            ARRAY[]::TEXT[]
+        ),
+        'grants', COALESCE(
+            (
+                SELECT jsonb_agg(DISTINCT jsonb_build_object(
+                    'permission', CONCAT(ep.action_name, ':', ep.resource_name),
+                    'institution_code', uar.institution_code
+                ))
+                FROM user_active_roles(u.id) uar
+                CROSS JOIN LATERAL role_effective_permissions(uar.role_id) ep
+            ),
+            '[]'::jsonb
+        )
+    ) INTO v_result
+    FROM users u
+    LEFT JOIN roles r ON u.role_id = r.id
+    WHERE u.username = p_username;
+
+    RETURN v_result;
+END;
+$$;
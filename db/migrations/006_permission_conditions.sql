@@ -0,0 +1,142 @@
+--
+-- Attribute-based conditions: a role_permissions row may carry a condition
+-- expression (see pkg/services/rbac/helper/condition.go). NULL keeps the
+-- grant unconditional. Conditions are evaluated by the service, not here.
+--
+
+ALTER TABLE public.role_permissions
+    ADD COLUMN IF NOT EXISTS condition text;
+
+DROP FUNCTION IF EXISTS public.role_effective_permissions(integer);
+
+-- A permission held unconditionally and with a condition, or with different
+-- conditions through different roles, is reported once per condition from
+-- the nearest role.
+CREATE FUNCTION public.role_effective_permissions(p_role_id integer)
+RETURNS TABLE(action_name text, resource_name text, source_role_id integer, source_role_name text, inherited boolean, condition text)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT DISTINCT ON (a.name, res.name, rp.condition)
+        a.name::text,
+        res.name::text,
+        anc.role_id,
+        anc.role_name,
+        anc.depth > 0,
+        rp.condition
+    FROM role_ancestors(p_role_id) anc
+    JOIN role_permissions rp ON rp.role_id = anc.role_id
+    JOIN permissions p ON rp.permission_id = p.id
+    JOIN actions a ON p.action_id = a.id
+    JOIN resources res ON p.resource_id = res.id
+    ORDER BY a.name, res.name, rp.condition, anc.depth;
+$$;
+
+ALTER FUNCTION public.role_effective_permissions(p_role_id integer) OWNER TO postgres;
+
+CREATE OR REPLACE FUNCTION public.get_user_by_username(p_username text) RETURNS jsonb
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    v_result JSONB;
+BEGIN
+    SELECT jsonb_build_object(
+        'id', u.id,
+        'username', u.username,
+        'staff_id', u.staff_id,
+        'first_name', u.first_name,
+        'middle_name', u.middle_name,
+        'last_name', u.last_name,
+        'email', u.email,
+        'role_id', u.role_id,
+        'role_name', r.name,
+        'roles', COALESCE(
+            (
+                SELECT jsonb_agg(jsonb_build_object(
+                    'id', ar.id,
+                    'name', ar.name,
+                    'is_superuser', ar.is_superuser,
+                    'institution_code', uar.institution_code
+                ) ORDER BY ar.name, uar.institution_code)
+                FROM user_active_roles(u.id) uar
+                JOIN roles ar ON ar.id = uar.role_id
+            ),
+            '[]'::jsonb
+        ),
+        'is_superuser', COALESCE(
+            (
+                SELECT bool_or(ar.is_superuser)
+                FROM user_active_roles(u.id) uar
+                JOIN roles ar ON ar.id = uar.role_id
+            ),
+            false
+        ),
+        'permissions', COALESCE(
+            (
+                SELECT ARRAY_AGG(DISTINCT CONCAT(ep.action_name, ':', ep.resource_name) ORDER BY CONCAT(ep.action_name, ':', ep.resource_name))
+                FROM user_active_roles(u.id) uar
+                CROSS JOIN LATERAL role_effective_permissions(uar.role_id) ep
+            ),
+            ARRAY[]::TEXT[]
+        ),
+        'grants', COALESCE(
+            (
+                SELECT jsonb_agg(DISTINCT jsonb_build_object(
+                    'permission', CONCAT(ep.action_name, ':', ep.resource_name),
+                    'institution_code', uar.institution_code,
+                    'condition', ep.condition
+                ))
+                FROM user_active_roles(u.id) uar
+                CROSS JOIN LATERAL role_effective_permissions(uar.role_id) ep
+            ),
+            '[]'::jsonb
+        )
+    ) INTO v_result
+    FROM users u
+    LEFT JOIN roles r ON u.role_id = r.id
+    WHERE u.username = p_username;
+
+    RETURN v_result;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_role_permissions_json(p_role_id integer) RETURNS jsonb
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    v_role_name text;
+    v_parent_name text;
+    v_permissions_json jsonb;
+BEGIN
+    -- Get role name
+    SELECT r.name, parent.name INTO v_role_name, v_parent_name
+    FROM roles r
+    LEFT JOIN roles parent ON parent.id = r.parent_role_id
+    WHERE r.id = p_role_id;
+
+    -- Raise if role not found
+    IF v_role_name IS NULL THEN
+        RAISE EXCEPTION 'Role with ID % not found', p_role_id;
+    END IF;
+
+    -- Get direct and inherited permissions
+    SELECT jsonb_agg(
+        jsonb_build_object(
+            'resource', ep.resource_name,
+            'action', ep.action_name,
+            'formatted', CONCAT(ep.action_name, ':', ep.resource_name),
+            'inherited', ep.inherited,
+            'inherited_from', CASE WHEN ep.inherited THEN ep.source_role_name END,
+            'condition', ep.condition
+        )
+        ORDER BY ep.resource_name, ep.action_name
+    ) INTO v_permissions_json
+    FROM role_effective_permissions(p_role_id) ep;
+
+    -- Return result
+    RETURN jsonb_build_object(
+        'role', v_role_name,
+        'parent_role', v_parent_name,
+        'permissions', COALESCE(v_permissions_json, '[]'::jsonb)
+    );
+END;
+$$;
@@ -0,0 +1,7 @@
+--
+-- Resource guarded by the role lifecycle endpoints (create/view/update/delete:role).
+--
+
+INSERT INTO public.resources (name, description)
+SELECT 'role', 'Roles and their assignments'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = 'role');
@@ -0,0 +1,117 @@
+--
+-- Time-bound role assignments. A user_roles row only applies between
+-- valid_from and valid_until (either may be NULL for an open end). Expired
+-- rows are deleted by the application's expiry sweeper, which audits each
+-- revocation. users.role_id stays permanent.
+--
+
+ALTER TABLE public.user_roles
+    ADD COLUMN IF NOT EXISTS valid_from timestamp with time zone,
+    ADD COLUMN IF NOT EXISTS valid_until timestamp with time zone;
+
+ALTER TABLE public.user_roles
+    DROP CONSTRAINT IF EXISTS user_roles_valid_window_check;
+
+ALTER TABLE public.user_roles
+    ADD CONSTRAINT user_roles_valid_window_check
+    CHECK (valid_from IS NULL OR valid_until IS NULL OR valid_until > valid_from);
+
+CREATE INDEX IF NOT EXISTS user_roles_valid_until_idx
+    ON public.user_roles (valid_until) WHERE valid_until IS NOT NULL;
+
+-- Every assignment that has not expired yet, including ones that start later.
+-- The window travels with the cached permission set so the middleware can
+-- enforce it at request time.
+CREATE OR REPLACE FUNCTION public.user_role_assignments(p_user_id integer)
+RETURNS TABLE(role_id integer, institution_code text, valid_from timestamp with time zone, valid_until timestamp with time zone)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT ur.role_id, ur.institution_code::text, ur.valid_from, ur.valid_until
+    FROM user_roles ur
+    WHERE ur.user_id = p_user_id AND (ur.valid_until IS NULL OR ur.valid_until > now())
+    UNION
+    SELECT u.role_id, NULL::text, NULL::timestamp with time zone, NULL::timestamp with time zone
+    FROM users u WHERE u.id = p_user_id AND u.role_id IS NOT NULL;
+$$;
+
+ALTER FUNCTION public.user_role_assignments(p_user_id integer) OWNER TO postgres;
+
+-- Assignments in force right now.
+CREATE OR REPLACE FUNCTION public.user_active_roles(p_user_id integer)
+RETURNS TABLE(role_id integer, institution_code text)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT DISTINCT a.role_id, a.institution_code
+    FROM user_role_assignments(p_user_id) a
+    WHERE a.valid_from IS NULL OR a.valid_from <= now();
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_user_by_username(p_username text) RETURNS jsonb
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    v_result JSONB;
+BEGIN
+    SELECT jsonb_build_object(
+        'id', u.id,
+        'username', u.username,
+        'staff_id', u.staff_id,
+        'first_name', u.first_name,
+        'middle_name', u.middle_name,
+        'last_name', u.last_name,
+        'email', u.email,
+        'role_id', u.role_id,
+        'role_name', r.name,
+        'roles', COALESCE(
+            (
+                SELECT jsonb_agg(jsonb_build_object(
+                    'id', ar.id,
+                    'name', ar.name,
+                    'is_superuser', ar.is_superuser,
+                    'institution_code', ura.institution_code,
+                    'valid_from', ura.valid_from,
+                    'valid_until', ura.valid_until
+                ) ORDER BY ar.name, ura.institution_code)
+                FROM user_role_assignments(u.id) ura
+                JOIN roles ar ON ar.id = ura.role_id
+            ),
+            '[]'::jsonb
+        ),
+        'is_superuser', COALESCE(
+            (
+                SELECT bool_or(ar.is_superuser)
+                FROM user_active_roles(u.id) uar
+                JOIN roles ar ON ar.id = uar.role_id
+            ),
+            false
+        ),
+        'permissions', COALESCE(
+            (
+                SELECT ARRAY_AGG(DISTINCT CONCAT(ep.action_name, ':', ep.resource_name) ORDER BY CONCAT(ep.action_name, ':', ep.resource_name))
+                FROM user_active_roles(u.id) uar
+                CROSS JOIN LATERAL role_effective_permissions(uar.role_id) ep
+            ),
+            ARRAY[]::TEXT[]
+        ),
+        'grants', COALESCE(
+            (
+                SELECT jsonb_agg(DISTINCT jsonb_build_object(
+                    'permission', CONCAT(ep.action_name, ':', ep.resource_name),
+                    'institution_code', ura.institution_code,
+                    'condition', ep.condition,
+                    'valid_from', ura.valid_from,
+                    'valid_until', ura.valid_until
+                ))
+                FROM user_role_assignments(u.id) ura
+                CROSS JOIN LATERAL role_effective_permissions(ura.role_id) ep
+            ),
+            '[]'::jsonb
+        )
+    ) INTO v_result
+    FROM users u
+    LEFT JOIN roles r ON u.role_id = r.id
+    WHERE u.username = p_username;
+
+    RETURN v_result;
+END;
+$$;
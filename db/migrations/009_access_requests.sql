@@ -0,0 +1,150 @@
+--
+-- Just-in-time access requests. A user asks for a role or a single
+-- action:resource permission for a reason and a fixed duration; a holder of
+-- approve:access_request approves or denies it. An approved request is a
+-- time-limited grant in force from decided_at until valid_until, after which
+-- the expiry sweeper marks it expired. Every step is written to
+-- rbac_audit_logs by the application.
+--
+
+INSERT INTO public.actions (name, description)
+SELECT 'approve', 'Approve or deny requests'
+WHERE NOT EXISTS (SELECT 1 FROM public.actions WHERE name = 'approve');
+
+INSERT INTO public.resources (name, description)
+SELECT 'access_request', 'Just-in-time access requests'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = 'access_request');
+
+CREATE TABLE IF NOT EXISTS public.access_requests (
+    id serial PRIMARY KEY,
+    requester_id integer NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    role_id integer REFERENCES public.roles(id) ON DELETE CASCADE,
+    action_id integer REFERENCES public.actions(id) ON DELETE CASCADE,
+    resource_id integer REFERENCES public.resources(id) ON DELETE CASCADE,
+    institution_code character varying(50),
+    reason text NOT NULL,
+    duration_minutes integer NOT NULL CHECK (duration_minutes > 0),
+    status character varying(20) DEFAULT 'pending' NOT NULL
+        CHECK (status IN ('pending', 'approved', 'denied', 'cancelled', 'revoked', 'expired')),
+    decided_by integer REFERENCES public.users(id) ON DELETE SET NULL,
+    decided_at timestamp with time zone,
+    decision_note text,
+    valid_from timestamp with time zone,
+    valid_until timestamp with time zone,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT access_requests_target_check CHECK (
+        (role_id IS NOT NULL AND action_id IS NULL AND resource_id IS NULL)
+        OR (role_id IS NULL AND action_id IS NOT NULL AND resource_id IS NOT NULL)
+    )
+);
+
+ALTER TABLE public.access_requests OWNER TO postgres;
+
+CREATE INDEX IF NOT EXISTS access_requests_requester_idx ON public.access_requests (requester_id, status);
+CREATE INDEX IF NOT EXISTS access_requests_status_idx ON public.access_requests (status, valid_until);
+
+-- Approved role requests count as time-bound assignments.
+CREATE OR REPLACE FUNCTION public.user_role_assignments(p_user_id integer)
+RETURNS TABLE(role_id integer, institution_code text, valid_from timestamp with time zone, valid_until timestamp with time zone)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT ur.role_id, ur.institution_code::text, ur.valid_from, ur.valid_until
+    FROM user_roles ur
+    WHERE ur.user_id = p_user_id AND (ur.valid_until IS NULL OR ur.valid_until > now())
+    UNION
+    SELECT u.role_id, NULL::text, NULL::timestamp with time zone, NULL::timestamp with time zone
+    FROM users u WHERE u.id = p_user_id AND u.role_id IS NOT NULL
+    UNION
+    SELECT ar.role_id, ar.institution_code::text, ar.valid_from, ar.valid_until
+    FROM access_requests ar
+    WHERE ar.requester_id = p_user_id AND ar.status = 'approved' AND ar.role_id IS NOT NULL
+      AND ar.valid_until > now();
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_user_by_username(p_username text) RETURNS jsonb
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    v_result JSONB;
+BEGIN
+    SELECT jsonb_build_object(
+        'id', u.id,
+        'username', u.username,
+        'staff_id', u.staff_id,
+        'first_name', u.first_name,
+        'middle_name', u.middle_name,
+        'last_name', u.last_name,
+        'email', u.email,
+        'role_id', u.role_id,
+        'role_name', r.name,
+        'roles', COALESCE(
+            (
+                SELECT jsonb_agg(jsonb_build_object(
+                    'id', ar.id,
+                    'name', ar.name,
+                    'is_superuser', ar.is_superuser,
+                    'institution_code', ura.institution_code,
+                    'valid_from', ura.valid_from,
+                    'valid_until', ura.valid_until
+                ) ORDER BY ar.name, ura.institution_code)
+                FROM user_role_assignments(u.id) ura
+                JOIN roles ar ON ar.id = ura.role_id
+            ),
+            '[]'::jsonb
+        ),
+        'is_superuser', COALESCE(
+            (
+                SELECT bool_or(ar.is_superuser)
+                FROM user_active_roles(u.id) uar
+                JOIN roles ar ON ar.id = uar.role_id
+            ),
+            false
+        ),
+        'permissions', COALESCE(
+            (
+                SELECT ARRAY_AGG(DISTINCT CONCAT(ep.action_name, ':', ep.resource_name) ORDER BY CONCAT(ep.action_name, ':', ep.resource_name))
+                FROM user_active_roles(u.id) uar
+                CROSS JOIN LATERAL role_effective_permissions(uar.role_id) ep
+            ),
+            ARRAY[]::TEXT[]
+        ),
+        'grants', COALESCE(
+            (
+                SELECT jsonb_agg(DISTINCT g.item)
+                FROM (
+                    SELECT jsonb_build_object(
+                        'permission', CONCAT(ep.action_name, ':', ep.resource_name),
+                        'institution_code', ura.institution_code,
+                        'condition', ep.condition,
+                        'valid_from', ura.valid_from,
+                        'valid_until', ura.valid_until
+                    ) AS item
+                    FROM user_role_assignments(u.id) ura
+                    CROSS JOIN LATERAL role_effective_permissions(ura.role_id) ep
+                    UNION ALL
+                    -- Approved single-permission requests
+                    SELECT jsonb_build_object(
+                        'permission', CONCAT(a.name, ':', res.name),
+                        'institution_code', acr.institution_code,
+                        'condition', NULL,
+                        'valid_from', acr.valid_from,
+                        'valid_until', acr.valid_until
+                    )
+                    FROM access_requests acr
+                    JOIN actions a ON a.id = acr.action_id
+                    JOIN resources res ON res.id = acr.resource_id
+                    WHERE acr.requester_id = u.id AND acr.status = 'approved'
+                      AND acr.valid_until > now()
+                ) g
+            ),
+            '[]'::jsonb
+        )
+    ) INTO v_result
+    FROM users u
+    LEFT JOIN roles r ON u.role_id = r.id
+    WHERE u.username = p_username;
+
+    RETURN v_result;
+END;
+$$;
@@ -0,0 +1,130 @@
+--
+-- Static separation-of-duties rules. A rule is a set of mutually exclusive
+-- roles and/or action:resource permissions; a user (or a role) violates it
+-- when it holds two or more of them. Roles are held through any unexpired
+-- assignment, including inherited parent roles; permissions are held when an
+-- effective grant covers them, wildcards included. The API checks rules
+-- before committing assignments and grants; sod_violations() reports what
+-- slipped in before a rule existed.
+--
+
+INSERT INTO public.resources (name, description)
+SELECT 'sod_rule', 'Separation-of-duties rules'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = 'sod_rule');
+
+CREATE TABLE IF NOT EXISTS public.sod_rules (
+    id serial PRIMARY KEY,
+    name character varying(100) NOT NULL UNIQUE,
+    description text,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE public.sod_rules OWNER TO postgres;
+
+CREATE TABLE IF NOT EXISTS public.sod_rule_members (
+    id serial PRIMARY KEY,
+    rule_id integer NOT NULL REFERENCES public.sod_rules(id) ON DELETE CASCADE,
+    role_id integer REFERENCES public.roles(id) ON DELETE CASCADE,
+    action_id integer REFERENCES public.actions(id) ON DELETE CASCADE,
+    resource_id integer REFERENCES public.resources(id) ON DELETE CASCADE,
+    CONSTRAINT sod_rule_members_target_check CHECK (
+        (role_id IS NOT NULL AND action_id IS NULL AND resource_id IS NULL)
+        OR (role_id IS NULL AND action_id IS NOT NULL AND resource_id IS NOT NULL)
+    )
+);
+
+ALTER TABLE public.sod_rule_members OWNER TO postgres;
+
+CREATE UNIQUE INDEX IF NOT EXISTS sod_rule_members_unique_key
+    ON public.sod_rule_members (rule_id, COALESCE(role_id, 0), COALESCE(action_id, 0), COALESCE(resource_id, 0));
+
+-- Members of every rule with a readable label.
+CREATE OR REPLACE FUNCTION public.sod_members()
+RETURNS TABLE(rule_id integer, rule_name text, role_id integer, action_name text, resource_name text, label text)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT m.rule_id, sr.name::text, m.role_id, a.name::text, res.name::text,
+           COALESCE(r.name::text, CONCAT(a.name, ':', res.name))
+    FROM sod_rule_members m
+    JOIN sod_rules sr ON sr.id = m.rule_id
+    LEFT JOIN roles r ON r.id = m.role_id
+    LEFT JOIN actions a ON a.id = m.action_id
+    LEFT JOIN resources res ON res.id = m.resource_id;
+$$;
+
+ALTER FUNCTION public.sod_members() OWNER TO postgres;
+
+-- Rules a role violates on its own: through its ancestor chain and its
+-- effective permissions.
+CREATE OR REPLACE FUNCTION public.sod_role_violations(p_role_id integer)
+RETURNS TABLE(rule_id integer, rule_name text, members text)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT sm.rule_id, sm.rule_name, string_agg(sm.label, ', ' ORDER BY sm.label)
+    FROM sod_members() sm
+    WHERE sm.role_id IN (SELECT anc.role_id FROM role_ancestors(p_role_id) anc)
+       OR (sm.role_id IS NULL AND EXISTS (
+            SELECT 1 FROM role_effective_permissions(p_role_id) ep
+            WHERE ep.action_name IN (sm.action_name, '*') AND ep.resource_name IN (sm.resource_name, '*')
+       ))
+    GROUP BY sm.rule_id, sm.rule_name
+    HAVING count(*) > 1;
+$$;
+
+ALTER FUNCTION public.sod_role_violations(p_role_id integer) OWNER TO postgres;
+
+-- Rules a user violates across every unexpired assignment, whatever the
+-- institution, plus approved single-permission access requests.
+CREATE OR REPLACE FUNCTION public.sod_user_violations(p_user_id integer)
+RETURNS TABLE(rule_id integer, rule_name text, members text)
+    LANGUAGE sql STABLE
+    AS $$
+    WITH held_roles AS (
+        SELECT DISTINCT anc.role_id
+        FROM user_role_assignments(p_user_id) ura
+        CROSS JOIN LATERAL role_ancestors(ura.role_id) anc
+    ),
+    held_grants AS (
+        -- held_roles already includes ancestors, so direct grants suffice;
+        -- conditional grants count as held
+        SELECT a.name::text AS action_name, res.name::text AS resource_name
+        FROM held_roles hr
+        JOIN role_permissions rp ON rp.role_id = hr.role_id
+        JOIN permissions p ON p.id = rp.permission_id
+        JOIN actions a ON a.id = p.action_id
+        JOIN resources res ON res.id = p.resource_id
+        UNION
+        SELECT a.name::text, res.name::text
+        FROM access_requests acr
+        JOIN actions a ON a.id = acr.action_id
+        JOIN resources res ON res.id = acr.resource_id
+        WHERE acr.requester_id = p_user_id AND acr.status = 'approved' AND acr.valid_until > now()
+    )
+    SELECT sm.rule_id, sm.rule_name, string_agg(sm.label, ', ' ORDER BY sm.label)
+    FROM sod_members() sm
+    WHERE sm.role_id IN (SELECT role_id FROM held_roles)
+       OR (sm.role_id IS NULL AND EXISTS (
+            SELECT 1 FROM held_grants hg
+            WHERE hg.action_name IN (sm.action_name, '*') AND hg.resource_name IN (sm.resource_name, '*')
+       ))
+    GROUP BY sm.rule_id, sm.rule_name
+    HAVING count(*) > 1;
+$$;
+
+ALTER FUNCTION public.sod_user_violations(p_user_id integer) OWNER TO postgres;
+
+-- Every current violation, by user and by role.
+CREATE OR REPLACE FUNCTION public.sod_violations()
+RETURNS TABLE(subject_type text, subject_id integer, subject text, rule_id integer, rule_name text, members text)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT 'role', r.id, r.name::text, v.rule_id, v.rule_name, v.members
+    FROM roles r
+    CROSS JOIN LATERAL sod_role_violations(r.id) v
+    UNION ALL
+    SELECT 'user', u.id, u.username::text, v.rule_id, v.rule_name, v.members
+    FROM users u
+    CROSS JOIN LATERAL sod_user_violations(u.id) v;
+$$;
+
+ALTER FUNCTION public.sod_violations() OWNER TO postgres;
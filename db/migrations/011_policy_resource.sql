@@ -0,0 +1,7 @@
+--
+-- Resource guarded by the policy export/import endpoints (view/update:policy).
+--
+
+INSERT INTO public.resources (name, description)
+SELECT 'policy', 'Whole RBAC configuration export and import'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = 'policy');
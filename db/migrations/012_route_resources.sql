@@ -0,0 +1,21 @@
+--
+-- Resources named by route requirements in routers.APIRoute that were never
+-- seeded. The service checks every route requirement against the actions
+-- and resources tables at startup (ROUTE_VALIDATION_MODE=warn|fail|off).
+--
+
+INSERT INTO public.resources (name, description)
+SELECT 'action', 'RBAC actions'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = 'action');
+
+INSERT INTO public.resources (name, description)
+SELECT 'resource', 'RBAC resources'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = 'resource');
+
+INSERT INTO public.resources (name, description)
+SELECT 'permission', 'Role permission grants and access checks'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = 'permission');
+
+INSERT INTO public.resources (name, description)
+SELECT 'route', 'API route to permission map'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = 'route');
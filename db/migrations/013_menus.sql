@@ -0,0 +1,42 @@
+--
+-- Navigation menus. Menus nest through parent_id and are ordered by
+-- sort_order within a parent; system_tag tells apart the front ends sharing
+-- this service (NULL menus show in every system). A user sees a menu when it
+-- is assigned through role_menus to one of their roles in force, parents
+-- included, or when they satisfy its permission expression
+-- (e.g. "view:role || view:permission"). Ancestors of a visible menu are
+-- always returned so the tree stays connected.
+--
+
+INSERT INTO public.resources (name, description)
+SELECT 'menu', 'Navigation menus and their role assignments'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = 'menu');
+
+CREATE TABLE IF NOT EXISTS public.menus (
+    id serial PRIMARY KEY,
+    parent_id integer REFERENCES public.menus(id),
+    name character varying(100) NOT NULL,
+    slug character varying(100) NOT NULL UNIQUE,
+    path text,
+    icon character varying(100),
+    sort_order integer DEFAULT 0 NOT NULL,
+    system_tag character varying(50),
+    permission text,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT menus_parent_check CHECK (parent_id IS NULL OR parent_id <> id)
+);
+
+ALTER TABLE public.menus OWNER TO postgres;
+
+CREATE INDEX IF NOT EXISTS menus_parent_idx ON public.menus (parent_id, sort_order);
+
+CREATE TABLE IF NOT EXISTS public.role_menus (
+    id serial PRIMARY KEY,
+    role_id integer NOT NULL REFERENCES public.roles(id) ON DELETE CASCADE,
+    menu_id integer NOT NULL REFERENCES public.menus(id) ON DELETE CASCADE,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT role_menus_unique_key UNIQUE (role_id, menu_id)
+);
+
+ALTER TABLE public.role_menus OWNER TO postgres;
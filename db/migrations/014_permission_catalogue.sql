@@ -0,0 +1,103 @@
+--
+-- The permissions table is a catalogue managed through /rbac/permissions.
+-- remove_role_permission no longer deletes a pair when its last role lets go
+-- of it; permission_deleted is kept in the result for older callers and is
+-- always false. Deleting a pair still granted to a role is refused by the API.
+--
+
+CREATE OR REPLACE FUNCTION public.remove_role_permission(p_role_id integer, p_action_name text, p_resource_name text) RETURNS TABLE(success boolean, message text, role_name text, action_name text, resource_name text, permission_deleted boolean)
+    LANGUAGE plpgsql ROWS 1
+    AS $$
+DECLARE
+    v_role_name text;
+    v_action_id integer;
+    v_resource_id integer;
+    v_permission_id integer;
+    v_rows_deleted integer;
+BEGIN
+    -- Check if role exists and get its name
+    SELECT name INTO v_role_name FROM roles WHERE id = p_role_id;
+    IF v_role_name IS NULL THEN
+        RETURN QUERY SELECT 
+            false::boolean AS success,
+            ('Role with ID "' || p_role_id || '" does not exist')::text AS message,
+            NULL::text AS role_name,
+            p_action_name AS action_name,
+            p_resource_name AS resource_name,
+            false::boolean AS permission_deleted;
+        RETURN;
+    END IF;
+
+    -- Check if action exists and get its ID
+    SELECT id INTO v_action_id FROM actions WHERE name = p_action_name;
+    IF v_action_id IS NULL THEN
+        RETURN QUERY SELECT 
+            false::boolean AS success,
+            ('Action "' || p_action_name || '" does not exist')::text AS message,
+            v_role_name AS role_name,
+            p_action_name AS action_name,
+            p_resource_name AS resource_name,
+            false::boolean AS permission_deleted;
+        RETURN;
+    END IF;
+
+    -- Check if resource exists and get its ID
+    SELECT id INTO v_resource_id FROM resources WHERE name = p_resource_name;
+    IF v_resource_id IS NULL THEN
+        RETURN QUERY SELECT 
+            false::boolean AS success,
+            ('Resource "' || p_resource_name || '" does not exist')::text AS message,
+            v_role_name AS role_name,
+            p_action_name AS action_name,
+            p_resource_name AS resource_name,
+            false::boolean AS permission_deleted;
+        RETURN;
+    END IF;
+
+    -- Get permission ID
+    SELECT id INTO v_permission_id 
+    FROM permissions 
+    WHERE resource_id = v_resource_id 
+    AND action_id = v_action_id;
+    
+    IF v_permission_id IS NULL THEN
+        RETURN QUERY SELECT 
+            false::boolean AS success,
+            ('Permission "' || p_action_name || ':' || p_resource_name || '" does not exist')::text AS message,
+            v_role_name AS role_name,
+            p_action_name AS action_name,
+            p_resource_name AS resource_name,
+            false::boolean AS permission_deleted;
+        RETURN;
+    END IF;
+
+    -- Delete the role-permission relationship
+    DELETE FROM role_permissions 
+    WHERE role_id = p_role_id 
+    AND permission_id = v_permission_id;
+    
+    GET DIAGNOSTICS v_rows_deleted = ROW_COUNT;
+    
+    IF v_rows_deleted > 0 THEN
+        RETURN QUERY SELECT 
+            true::boolean AS success,
+            ('Action "' || p_action_name || '" on resource "' || p_resource_name || 
+             '" removed from role "' || v_role_name || '"')::text AS message,
+            v_role_name AS role_name,
+            p_action_name AS action_name,
+            p_resource_name AS resource_name,
+            false::boolean AS permission_deleted;
+    ELSE
+        RETURN QUERY SELECT 
+            false::boolean AS success,
+            ('Action "' || p_action_name || '" on resource "' || p_resource_name || 
+             '" was not assigned to role "' || v_role_name || '"')::text AS message,
+            v_role_name AS role_name,
+            p_action_name AS action_name,
+            p_resource_name AS resource_name,
+            false::boolean AS permission_deleted;
+    END IF;
+END;
+$$;
+
+ALTER FUNCTION public.remove_role_permission(p_role_id integer, p_action_name text, p_resource_name text) OWNER TO postgres;
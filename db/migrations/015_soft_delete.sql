@@ -0,0 +1,155 @@
+--
+-- Soft delete for actions, resources and roles. Deleting one through the API
+-- sets archived_at instead of removing the row; grants, assignments and
+-- approved requests that depend on it stay in their tables but stop taking
+-- effect, and restoring the row (archived_at back to NULL) brings them back
+-- as they were. role_effective_permissions and user_role_assignments are the
+-- places this is enforced.
+--
+
+ALTER TABLE public.actions ADD COLUMN IF NOT EXISTS archived_at timestamp with time zone;
+ALTER TABLE public.resources ADD COLUMN IF NOT EXISTS archived_at timestamp with time zone;
+ALTER TABLE public.roles ADD COLUMN IF NOT EXISTS archived_at timestamp with time zone;
+
+-- Grants on archived actions or resources are skipped, and an archived role
+-- cuts the chain: neither it nor anything above it is inherited.
+CREATE OR REPLACE FUNCTION public.role_effective_permissions(p_role_id integer)
+RETURNS TABLE(action_name text, resource_name text, source_role_id integer, source_role_name text, inherited boolean, condition text)
+    LANGUAGE sql STABLE
+    AS $$
+    WITH chain AS (
+        SELECT anc.role_id, anc.role_name, anc.depth, r.archived_at
+        FROM role_ancestors(p_role_id) anc
+        JOIN roles r ON r.id = anc.role_id
+    ),
+    active AS (
+        SELECT * FROM chain
+        WHERE depth < COALESCE((SELECT min(depth) FROM chain WHERE archived_at IS NOT NULL), 2147483647)
+    )
+    SELECT DISTINCT ON (a.name, res.name, rp.condition)
+        a.name::text,
+        res.name::text,
+        anc.role_id,
+        anc.role_name,
+        anc.depth > 0,
+        rp.condition
+    FROM active anc
+    JOIN role_permissions rp ON rp.role_id = anc.role_id
+    JOIN permissions p ON rp.permission_id = p.id
+    JOIN actions a ON p.action_id = a.id AND a.archived_at IS NULL
+    JOIN resources res ON p.resource_id = res.id AND res.archived_at IS NULL
+    ORDER BY a.name, res.name, rp.condition, anc.depth;
+$$;
+
+-- Assignments of archived roles are kept but not reported.
+CREATE OR REPLACE FUNCTION public.user_role_assignments(p_user_id integer)
+RETURNS TABLE(role_id integer, institution_code text, valid_from timestamp with time zone, valid_until timestamp with time zone)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT held.role_id, held.institution_code, held.valid_from, held.valid_until
+    FROM (
+        SELECT ur.role_id, ur.institution_code::text AS institution_code, ur.valid_from, ur.valid_until
+        FROM user_roles ur
+        WHERE ur.user_id = p_user_id AND (ur.valid_until IS NULL OR ur.valid_until > now())
+        UNION
+        SELECT u.role_id, NULL::text, NULL::timestamp with time zone, NULL::timestamp with time zone
+        FROM users u WHERE u.id = p_user_id AND u.role_id IS NOT NULL
+        UNION
+        SELECT ar.role_id, ar.institution_code::text, ar.valid_from, ar.valid_until
+        FROM access_requests ar
+        WHERE ar.requester_id = p_user_id AND ar.status = 'approved' AND ar.role_id IS NOT NULL
+          AND ar.valid_until > now()
+    ) held
+    JOIN roles r ON r.id = held.role_id
+    WHERE r.archived_at IS NULL;
+$$;
+
+-- Approved single-permission requests on archived actions or resources are
+-- skipped like role grants.
+CREATE OR REPLACE FUNCTION public.get_user_by_username(p_username text) RETURNS jsonb
+    LANGUAGE plpgsql
+    AS $$
+DECLARE
+    v_result JSONB;
+BEGIN
+    SELECT jsonb_build_object(
+        'id', u.id,
+        'username', u.username,
+        'staff_id', u.staff_id,
+        'first_name', u.first_name,
+        'middle_name', u.middle_name,
+        'last_name', u.last_name,
+        'email', u.email,
+        'role_id', u.role_id,
+        'role_name', r.name,
+        'roles', COALESCE(
+            (
+                SELECT jsonb_agg(jsonb_build_object(
+                    'id', ar.id,
+                    'name', ar.name,
+                    'is_superuser', ar.is_superuser,
+                    'institution_code', ura.institution_code,
+                    'valid_from', ura.valid_from,
+                    'valid_until', ura.valid_until
+                ) ORDER BY ar.name, ura.institution_code)
+                FROM user_role_assignments(u.id) ura
+                JOIN roles ar ON ar.id = ura.role_id
+            ),
+            '[]'::jsonb
+        ),
+        'is_superuser', COALESCE(
+            (
+                SELECT bool_or(ar.is_superuser)
+                FROM user_active_roles(u.id) uar
+                JOIN roles ar ON ar.id = uar.role_id
+            ),
+            false
+        ),
+        'permissions', COALESCE(
+            (
+                SELECT ARRAY_AGG(DISTINCT CONCAT(ep.action_name, ':', ep.resource_name) ORDER BY CONCAT(ep.action_name, ':', ep.resource_name))
+                FROM user_active_roles(u.id) uar
+                CROSS JOIN LATERAL role_effective_permissions(uar.role_id) ep
+            ),
+            ARRAY[]::TEXT[]
+        ),
+        'grants', COALESCE(
+            (
+                SELECT jsonb_agg(DISTINCT g.item)
+                FROM (
+                    SELECT jsonb_build_object(
+                        'permission', CONCAT(ep.action_name, ':', ep.resource_name),
+                        'institution_code', ura.institution_code,
+                        'condition', ep.condition,
+                        'valid_from', ura.valid_from,
+                        'valid_until', ura.valid_until
+                    ) AS item
+                    FROM user_role_assignments(u.id) ura
+                    CROSS JOIN LATERAL role_effective_permissions(ura.role_id) ep
+                    UNION ALL
+                    -- Approved single-permission requests
+                    SELECT jsonb_build_object(
+                        'permission', CONCAT(a.name, ':', res.name),
+                        'institution_code', acr.institution_code,
+                        'condition', NULL,
+                        'valid_from', acr.valid_from,
+                        'valid_until', acr.valid_until
+                    )
+                    FROM access_requests acr
+                    JOIN actions a ON a.id = acr.action_id
+                    JOIN resources res ON res.id = acr.resource_id
+                    WHERE acr.requester_id = u.id AND acr.status = 'approved'
+                      AND acr.valid_until > now()
+                      AND a.archived_at IS NULL AND res.archived_at IS NULL
+                ) g
+            ),
+            '[]'::jsonb
+        )
+    ) INTO v_result
+    FROM users u
+    LEFT JOIN roles r ON u.role_id = r.id
+    WHERE u.username = p_username;
+
+    RETURN v_result;
+END;
+$$;
@@ -0,0 +1,12 @@
+--
+-- Resource guarded by the outbound call metrics endpoint (view:metrics).
+--
+
+INSERT INTO public.resources (name, description)
+SELECT 'metrics', 'Outbound call metrics and circuit breaker state'
+WHERE NOT EXISTS (SELECT 1 FROM public.resources WHERE name = 'metrics');
+
+INSERT INTO public.permissions (resource_id, action_id)
+SELECT res.id, a.id FROM public.resources res, public.actions a
+WHERE res.name = 'metrics' AND a.name = 'view'
+  AND NOT EXISTS (SELECT 1 FROM public.permissions p WHERE p.resource_id = res.id AND p.action_id = a.id);
@@ -0,0 +1,35 @@
+--
+-- The primary role (users.role_id) no longer grants anything by itself; it is
+-- a label for the user_roles row that does. Until now it was unioned in as a
+-- permanent global assignment, so it could not be limited to an institution
+-- or a time window, and a scoped user_roles row for the same role was widened
+-- to every institution.
+--
+-- Primary roles without any user_roles row keep the global, permanent
+-- assignment they had. Users whose primary role is already held only for
+-- some institution keep just that.
+--
+
+INSERT INTO public.user_roles (user_id, role_id)
+SELECT u.id, u.role_id FROM public.users u
+WHERE u.role_id IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM public.user_roles ur WHERE ur.user_id = u.id AND ur.role_id = u.role_id);
+
+CREATE OR REPLACE FUNCTION public.user_role_assignments(p_user_id integer)
+RETURNS TABLE(role_id integer, institution_code text, valid_from timestamp with time zone, valid_until timestamp with time zone)
+    LANGUAGE sql STABLE
+    AS $$
+    SELECT held.role_id, held.institution_code, held.valid_from, held.valid_until
+    FROM (
+        SELECT ur.role_id, ur.institution_code::text AS institution_code, ur.valid_from, ur.valid_until
+        FROM user_roles ur
+        WHERE ur.user_id = p_user_id AND (ur.valid_until IS NULL OR ur.valid_until > now())
+        UNION
+        SELECT ar.role_id, ar.institution_code::text, ar.valid_from, ar.valid_until
+        FROM access_requests ar
+        WHERE ar.requester_id = p_user_id AND ar.status = 'approved' AND ar.role_id IS NOT NULL
+          AND ar.valid_until > now()
+    ) held
+    JOIN roles r ON r.id = held.role_id
+    WHERE r.archived_at IS NULL;
+$$;
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"go_template_v3/pkg/config"
+	prvAuth "go_template_v3/pkg/services/auth/provider"
+	hlpRbac "go_template_v3/pkg/services/rbac/helper"
 	"go_template_v3/routers"
 	"log"
 	"strings"
@@ -37,6 +39,21 @@ func init() {
 
 	// Connect to DB
 	config.PostgreSQLConnect()
+
+	// Use the in-memory identity provider for local runs without soteria
+	if strings.ToUpper(utils_v1.GetEnv("IDENTITY_PROVIDER")) == "FAKE" {
+		fmt.Println("IDENTITY PROVIDER: FAKE")
+		prvAuth.Use(prvAuth.NewFake())
+	}
+
+	// Connect to Redis (optional, used by the shared caches)
+	if utils_v1.GetEnv("REDIS_ADDRESS") != "" {
+		if redisConf, err := config.DecryptRedisConfig(); err != nil {
+			fmt.Printf("Redis config decryption error: %s\n", err.Error())
+		} else if !config.RedisConnect(redisConf.RedisAddress, redisConf.Password) {
+			config.RedisClient = nil
+		}
+	}
 }
 
 func main() {
@@ -61,6 +78,14 @@ func main() {
 	// Initialize API Endpoints
 	routers.APIRoute(app)
 
+	// Check route permissions against the actions and resources tables
+	if err := hlpRbac.ValidateRoutes(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Revoke expired time-bound role assignments in the background
+	hlpRbac.StartRoleExpirySweeper()
+
 	// TLS Configuration
 	if strings.ToUpper(utils_v1.GetEnv("SSL_MODE")) == "ENABLED" {
 		fmt.Println("SSL_MODE: ENABLED")
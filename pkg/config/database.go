@@ -105,6 +105,23 @@ func PostgreSQLConnect() bool {
 	return true
 }
 
+func DecryptRedisConfig() (*model.Redis, error) {
+	decrypted := model.Redis{}
+
+	// CREDENTIALS
+	decrypted.RedisAddress, RedisError = encryption.Decrypt(utils_v1.GetEnv("REDIS_ADDRESS"), utils_v1.GetEnv("SECRET_KEY"))
+	if RedisError != nil {
+		return nil, RedisError
+	}
+	if utils_v1.GetEnv("REDIS_PASSWORD") != "" {
+		decrypted.Password, RedisError = encryption.Decrypt(utils_v1.GetEnv("REDIS_PASSWORD"), utils_v1.GetEnv("SECRET_KEY"))
+		if RedisError != nil {
+			return nil, RedisError
+		}
+	}
+	return &decrypted, nil
+}
+
 func RedisConnect(address, password string) bool {
 	RedisClient = redis.NewClient(&redis.Options{
 		Addr:     address,
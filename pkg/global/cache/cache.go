@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go_template_v3/pkg/config"
+)
+
+// Store is a small key/value cache with TTLs and string-set indexes, so the
+// same callers can run against process memory or a shared Redis.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// Incr atomically adds one to the integer stored at key and returns the
+	// new value. Counters never expire.
+	Incr(ctx context.Context, key string) (int64, error)
+
+	// AddToIndex adds member to the set stored at index and extends its TTL.
+	AddToIndex(ctx context.Context, index, member string, ttl time.Duration) error
+	// IndexMembers returns every member of the set stored at index.
+	IndexMembers(ctx context.Context, index string) ([]string, error)
+}
+
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// NewStore builds the store for the given backend name. Redis requires
+// config.RedisConnect to have succeeded; otherwise memory is used.
+func NewStore(backend string) Store {
+	if strings.ToLower(backend) == BackendRedis {
+		if config.RedisClient != nil {
+			return NewRedisStore(config.RedisClient)
+		}
+		fmt.Println("Redis cache requested but Redis is not connected, using memory cache")
+	}
+	return NewMemoryStore()
+}
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+type memoryIndex struct {
+	members   map[string]struct{}
+	expiresAt time.Time
+}
+
+// MemoryStore keeps entries in process memory. Expired entries are dropped
+// on access and by a periodic sweep.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]memoryEntry
+	indexes map[string]memoryIndex
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{
+		entries: map[string]memoryEntry{},
+		indexes: map[string]memoryIndex{},
+	}
+	go s.sweep(time.Minute)
+	return s
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.RLock()
+	entry, ok := s.entries[key]
+	s.mu.RUnlock()
+
+	if !ok || expired(entry.expiresAt) {
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	s.entries[key] = memoryEntry{value: value, expiresAt: expiry(ttl)}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	for _, key := range keys {
+		delete(s.entries, key)
+		delete(s.indexes, key)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Incr(_ context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current int64
+	if entry, ok := s.entries[key]; ok && !expired(entry.expiresAt) {
+		n, err := strconv.ParseInt(string(entry.value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("value at %q is not an integer", key)
+		}
+		current = n
+	}
+
+	current++
+	s.entries[key] = memoryEntry{value: []byte(strconv.FormatInt(current, 10))}
+	return current, nil
+}
+
+func (s *MemoryStore) AddToIndex(_ context.Context, index, member string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, ok := s.indexes[index]
+	if !ok || expired(idx.expiresAt) {
+		idx = memoryIndex{members: map[string]struct{}{}}
+	}
+	idx.members[member] = struct{}{}
+	idx.expiresAt = expiry(ttl)
+	s.indexes[index] = idx
+	return nil
+}
+
+func (s *MemoryStore) IndexMembers(_ context.Context, index string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idx, ok := s.indexes[index]
+	if !ok || expired(idx.expiresAt) {
+		return nil, nil
+	}
+
+	members := make([]string, 0, len(idx.members))
+	for member := range idx.members {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (s *MemoryStore) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		for key, entry := range s.entries {
+			if expired(entry.expiresAt) {
+				delete(s.entries, key)
+			}
+		}
+		for key, idx := range s.indexes {
+			if expired(idx.expiresAt) {
+				delete(s.indexes, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// expiry converts a TTL into a deadline; zero means no expiry.
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func expired(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
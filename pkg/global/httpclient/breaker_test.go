@@ -0,0 +1,203 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tripped returns a breaker that has just opened after three failures.
+func tripped() *breaker {
+	b := newBreaker(BreakerConfig{FailureThreshold: 3, OpenFor: 30 * time.Second})
+	for i := 0; i < 3; i++ {
+		b.failure()
+	}
+	return b
+}
+
+func TestBreakerOpensOnConsecutiveFailures(t *testing.T) {
+	b := newBreaker(BreakerConfig{FailureThreshold: 3, OpenFor: 30 * time.Second})
+
+	// A success in between resets the count
+	b.failure()
+	b.failure()
+	b.success()
+	b.failure()
+	b.failure()
+	if b.currentState() != stateClosed || !b.allow() {
+		t.Fatalf("state = %s, want closed", b.currentState())
+	}
+
+	b.failure()
+	if b.currentState() != stateOpen || b.allow() {
+		t.Fatalf("state = %s, want open", b.currentState())
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	b := tripped()
+	b.openedAt = time.Now().Add(-time.Minute)
+
+	if !b.allow() {
+		t.Fatalf("no probe allowed after the open period")
+	}
+	// Only one request may probe at a time
+	if b.currentState() != stateHalfOpen || b.allow() {
+		t.Fatalf("state = %s, want half-open with the probe slot taken", b.currentState())
+	}
+
+	// A probe that ends without an outcome frees the slot for the next one
+	b.release()
+	if !b.allow() {
+		t.Fatalf("released probe slot was not reusable")
+	}
+
+	b.failure()
+	if b.currentState() != stateOpen || b.allow() {
+		t.Fatalf("failed probe: state = %s, want open", b.currentState())
+	}
+
+	b.openedAt = time.Now().Add(-time.Minute)
+	b.allow()
+	b.success()
+	if b.currentState() != stateClosed || !b.allow() {
+		t.Fatalf("successful probe: state = %s, want closed", b.currentState())
+	}
+}
+
+func TestBreakerDefaults(t *testing.T) {
+	b := newBreaker(BreakerConfig{FailureThreshold: -1})
+	if b.cfg.FailureThreshold != 5 || b.cfg.OpenFor != 30*time.Second {
+		t.Fatalf("defaults = %+v, want threshold 5 and 30s", b.cfg)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for retry, ceiling := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		for i := 0; i < 50; i++ {
+			if d := backoff(policy, retry); d < 0 || d > ceiling {
+				t.Fatalf("backoff(%d) = %s, want within [0, %s]", retry, d, ceiling)
+			}
+		}
+	}
+
+	// The shift overflows long before MaxAttempts could get there
+	if d := backoff(policy, 80); d < 0 || d > time.Second {
+		t.Fatalf("backoff after overflow = %s, want capped at MaxDelay", d)
+	}
+	if d := backoff(RetryPolicy{}, 3); d != 0 {
+		t.Fatalf("backoff without a base delay = %s, want 0", d)
+	}
+}
+
+// statusServer answers with the given statuses in order, repeating the last.
+func statusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(calls.Add(1)) - 1
+		w.WriteHeader(statuses[min(i, len(statuses)-1)])
+		_, _ = w.Write([]byte(`{"retCode":"200"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, calls
+}
+
+func TestClientRetries(t *testing.T) {
+	retry503 := RetryPolicy{MaxAttempts: 3, RetryOnStatus: []int{http.StatusServiceUnavailable}}
+
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		statuses     []int
+		wantCalls    int32
+		wantRetries  int64
+		wantFailures int64
+	}{
+		{"recovers on a retryable status", retry503, []int{503, 503, 200}, 3, 2, 2},
+		{"returns the last response after max attempts", retry503, []int{503}, 3, 2, 3},
+		// 500 counts against the breaker but is not worth repeating
+		{"server error outside the retry list", retry503, []int{500, 200}, 1, 0, 1},
+		{"client errors are neither retried nor failures", retry503, []int{404}, 1, 0, 0},
+		{"endpoints without a policy are tried once", RetryPolicy{}, []int{503, 200}, 1, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := statusServer(t, tt.statuses...)
+			c := newClient("test", BreakerConfig{FailureThreshold: 10})
+			c.SetPolicy("ping", tt.policy)
+
+			resp, err := c.Do(context.Background(), Request{Endpoint: "ping", Method: "GET", URL: server.URL})
+			if err != nil || resp == nil {
+				t.Fatalf("Do = %v, %v; want the decoded body", resp, err)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", got, tt.wantCalls)
+			}
+			if m := c.metrics.snapshot()["ping"]; m.Retries != tt.wantRetries || m.Failures != tt.wantFailures {
+				t.Fatalf("metrics = %+v, want %d retries and %d failures", m, tt.wantRetries, tt.wantFailures)
+			}
+		})
+	}
+}
+
+func TestClientShortCircuits(t *testing.T) {
+	server, calls := statusServer(t, http.StatusInternalServerError)
+	c := newClient("test", BreakerConfig{FailureThreshold: 2, OpenFor: time.Minute})
+	req := Request{Endpoint: "ping", Method: "GET", URL: server.URL}
+
+	for i := 0; i < 2; i++ {
+		_, _ = c.Do(context.Background(), req)
+	}
+	if _, err := c.Do(context.Background(), req); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("calls = %d, want 2", got)
+	}
+	if m := c.metrics.snapshot()["ping"]; m.ShortCircuited != 1 {
+		t.Fatalf("short_circuited = %d, want 1", m.ShortCircuited)
+	}
+}
+
+func TestClientCallerCancellationIsNotAFailure(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	c := newClient("test", BreakerConfig{FailureThreshold: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Do(ctx, Request{Endpoint: "ping", Method: "GET", URL: server.URL}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if c.breaker.currentState() != stateClosed {
+		t.Fatalf("state = %s, want closed", c.breaker.currentState())
+	}
+	if m := c.metrics.snapshot()["ping"]; m.Failures != 0 {
+		t.Fatalf("failures = %d, want 0", m.Failures)
+	}
+}
+
+func TestClientRejectsNonJSONBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html>maintenance</html>"))
+	}))
+	t.Cleanup(server.Close)
+
+	c := newClient("test", BreakerConfig{})
+	if _, err := c.Do(context.Background(), Request{Endpoint: "ping", Method: "GET", URL: server.URL}); err == nil {
+		t.Fatalf("Do decoded an HTML body")
+	}
+}
@@ -0,0 +1,244 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// RetryPolicy describes how one endpoint is retried. Only idempotent
+// endpoints should get more than one attempt.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// RetryOnStatus lists response codes worth another attempt, in addition
+	// to transport errors.
+	RetryOnStatus []int
+}
+
+// NoRetry is the default for non-idempotent calls.
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// IdempotentRetry is a sensible policy for GET-style calls.
+var IdempotentRetry = RetryPolicy{
+	MaxAttempts:   3,
+	BaseDelay:     100 * time.Millisecond,
+	MaxDelay:      time.Second,
+	RetryOnStatus: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+}
+
+// Request is one outbound call. Endpoint is a short stable name used for
+// retry policies and metrics, e.g. "validate-header".
+type Request struct {
+	Endpoint string
+	Method   string
+	URL      string
+	Body     []byte
+	Headers  map[string]string
+	Timeout  time.Duration
+}
+
+// Client wraps net/http with per-endpoint retries, jittered backoff, a
+// circuit breaker for the whole provider and outcome metrics.
+type Client struct {
+	name     string
+	http     *http.Client
+	breaker  *breaker
+	metrics  *metrics
+	mu       sync.RWMutex
+	policies map[string]RetryPolicy
+}
+
+// New creates a client and registers it for Snapshot.
+func New(name string, cfg BreakerConfig) *Client {
+	c := newClient(name, cfg)
+
+	registryMu.Lock()
+	registry[name] = c
+	registryMu.Unlock()
+
+	return c
+}
+
+// ForName returns the registered client with that name, creating one with
+// default breaker settings when missing.
+func ForName(name string) *Client {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if c, ok := registry[name]; ok {
+		return c
+	}
+	c := newClient(name, BreakerConfig{})
+	registry[name] = c
+	return c
+}
+
+func newClient(name string, cfg BreakerConfig) *Client {
+	return &Client{
+		name:     name,
+		http:     &http.Client{},
+		breaker:  newBreaker(cfg),
+		metrics:  &metrics{},
+		policies: map[string]RetryPolicy{},
+	}
+}
+
+// SetPolicy overrides the retry policy for an endpoint.
+func (c *Client) SetPolicy(endpoint string, policy RetryPolicy) *Client {
+	c.mu.Lock()
+	c.policies[endpoint] = policy
+	c.mu.Unlock()
+	return c
+}
+
+func (c *Client) policy(endpoint string) RetryPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if p, ok := c.policies[endpoint]; ok && p.MaxAttempts > 0 {
+		return p
+	}
+	return NoRetry
+}
+
+// Do sends the request and decodes the JSON body into a map or slice, the
+// same shape utils.SendRequest has always returned. Non-2xx bodies are still
+// decoded because upstream services report errors through retCode.
+func (c *Client) Do(ctx context.Context, req Request) (interface{}, error) {
+	counters := c.metrics.counters(req.Endpoint)
+	policy := c.policy(req.Endpoint)
+
+	var lastErr error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			counters.retries.Add(1)
+			if err := sleep(ctx, backoff(policy, attempt-1)); err != nil {
+				return nil, err
+			}
+		}
+
+		if !c.breaker.allow() {
+			counters.shortCircuited.Add(1)
+			return nil, fmt.Errorf("%s: %w", c.name, ErrCircuitOpen)
+		}
+
+		counters.requests.Add(1)
+		status, body, err := c.send(ctx, req)
+
+		// The caller gave up; that says nothing about the provider's health
+		if err != nil && ctx.Err() != nil {
+			c.breaker.release()
+			return nil, err
+		}
+
+		retryable := err != nil || slices.Contains(policy.RetryOnStatus, status)
+		if err != nil || status >= http.StatusInternalServerError {
+			c.breaker.failure()
+			counters.failures.Add(1)
+		} else {
+			c.breaker.success()
+			counters.successes.Add(1)
+		}
+
+		lastErr = err
+		if !retryable || attempt == policy.MaxAttempts {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return decode(body)
+		}
+	}
+
+	return nil, lastErr
+}
+
+func (c *Client) send(ctx context.Context, req Request) (int, []byte, error) {
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+		defer cancel()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Set default content-type header if not provided
+	if _, exists := req.Headers["Content-Type"]; !exists {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range req.Headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// backoff returns a full-jitter exponential delay for the given retry number.
+func backoff(policy RetryPolicy, retry int) time.Duration {
+	if policy.BaseDelay <= 0 {
+		return 0
+	}
+
+	ceiling := policy.BaseDelay << (retry - 1)
+	if policy.MaxDelay > 0 && (ceiling > policy.MaxDelay || ceiling <= 0) {
+		ceiling = policy.MaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func decode(body []byte) (interface{}, error) {
+	// Handle empty response
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	// Try to parse response as JSON object
+	var jsonRespObject map[string]interface{}
+	if err := json.Unmarshal(body, &jsonRespObject); err == nil {
+		return jsonRespObject, nil
+	}
+
+	// If parsing as JSON object fails, try as JSON array
+	var jsonRespArray []interface{}
+	if err := json.Unmarshal(body, &jsonRespArray); err == nil {
+		return jsonRespArray, nil
+	}
+
+	return nil, fmt.Errorf("response is neither a JSON object nor a JSON array: %s", string(body))
+}
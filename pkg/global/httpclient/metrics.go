@@ -0,0 +1,86 @@
+package httpclient
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// EndpointMetrics counts call outcomes for one endpoint.
+type EndpointMetrics struct {
+	Requests       int64 `json:"requests"`
+	Successes      int64 `json:"successes"`
+	Failures       int64 `json:"failures"`
+	Retries        int64 `json:"retries"`
+	ShortCircuited int64 `json:"short_circuited"`
+}
+
+// ClientMetrics is the snapshot returned by Snapshot.
+type ClientMetrics struct {
+	Name         string                     `json:"name"`
+	BreakerState string                     `json:"breaker_state"`
+	Endpoints    map[string]EndpointMetrics `json:"endpoints"`
+}
+
+type endpointCounters struct {
+	requests       atomic.Int64
+	successes      atomic.Int64
+	failures       atomic.Int64
+	retries        atomic.Int64
+	shortCircuited atomic.Int64
+}
+
+type metrics struct {
+	endpoints sync.Map // endpoint -> *endpointCounters
+}
+
+func (m *metrics) counters(endpoint string) *endpointCounters {
+	if c, ok := m.endpoints.Load(endpoint); ok {
+		return c.(*endpointCounters)
+	}
+	c, _ := m.endpoints.LoadOrStore(endpoint, &endpointCounters{})
+	return c.(*endpointCounters)
+}
+
+func (m *metrics) snapshot() map[string]EndpointMetrics {
+	out := map[string]EndpointMetrics{}
+	m.endpoints.Range(func(key, value any) bool {
+		c := value.(*endpointCounters)
+		out[key.(string)] = EndpointMetrics{
+			Requests:       c.requests.Load(),
+			Successes:      c.successes.Load(),
+			Failures:       c.failures.Load(),
+			Retries:        c.retries.Load(),
+			ShortCircuited: c.shortCircuited.Load(),
+		}
+		return true
+	})
+	return out
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Client{}
+)
+
+// Snapshot returns the metrics of every client created with New.
+func Snapshot() []ClientMetrics {
+	registryMu.Lock()
+	clients := make([]*Client, 0, len(registry))
+	for _, c := range registry {
+		clients = append(clients, c)
+	}
+	registryMu.Unlock()
+
+	sort.Slice(clients, func(i, j int) bool { return clients[i].name < clients[j].name })
+
+	out := make([]ClientMetrics, 0, len(clients))
+	for _, c := range clients {
+		out = append(out, ClientMetrics{
+			Name:         c.name,
+			BreakerState: c.breaker.currentState().String(),
+			Endpoints:    c.metrics.snapshot(),
+		})
+	}
+	return out
+}
@@ -0,0 +1,197 @@
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// ============================================
+// LIST QUERY CONVENTION
+// ============================================
+//
+// Every list endpoint accepts the same query parameters:
+//
+//	?page=1           1-based page number (default 1)
+//	?page_size=20     items per page (default 20, at most 100)
+//	?q=text           case-insensitive name search
+//	?sort=name        one of the endpoint's sort fields
+//	?order=asc|desc   sort direction
+//
+// and answers with {"items": [...], "meta": {...}}.
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+	// MaxOffset caps (page-1)*page_size so the offset never overflows.
+	MaxOffset = math.MaxInt32
+)
+
+var ErrInvalidQuery = errors.New("invalid list query")
+
+// Sorting lists the sort fields of an endpoint. Columns maps each field to
+// the SQL column or expression it orders by; in-memory lists only use the keys.
+type Sorting struct {
+	Columns map[string]string
+	Default string
+	Desc    bool
+}
+
+// Params is a parsed list query.
+type Params struct {
+	Page     int
+	PageSize int
+	Search   string
+	Sort     string
+	Desc     bool
+	column   string
+}
+
+type Meta struct {
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	Total      int64  `json:"total"`
+	TotalPages int    `json:"total_pages"`
+	Sort       string `json:"sort"`
+	Order      string `json:"order"`
+	Search     string `json:"q,omitempty"`
+}
+
+type Page struct {
+	Items interface{} `json:"items"`
+	Meta  Meta        `json:"meta"`
+}
+
+// Parse reads the list query of the request. Unknown sort fields and
+// malformed numbers are rejected with ErrInvalidQuery.
+func Parse(c fiber.Ctx, sorting Sorting) (Params, error) {
+	p := Params{
+		Page:     1,
+		PageSize: DefaultPageSize,
+		Search:   strings.TrimSpace(c.Query("q")),
+		Sort:     sorting.Default,
+		Desc:     sorting.Desc,
+	}
+
+	if raw := c.Query("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return p, fmt.Errorf("%w: page must be a positive number", ErrInvalidQuery)
+		}
+		p.Page = page
+	}
+
+	if raw := c.Query("page_size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 || size > MaxPageSize {
+			return p, fmt.Errorf("%w: page_size must be between 1 and %d", ErrInvalidQuery, MaxPageSize)
+		}
+		p.PageSize = size
+	}
+
+	if p.Page-1 > MaxOffset/p.PageSize {
+		return p, fmt.Errorf("%w: page is out of range", ErrInvalidQuery)
+	}
+
+	if raw := strings.TrimSpace(c.Query("sort")); raw != "" {
+		if _, ok := sorting.Columns[raw]; !ok {
+			fields := make([]string, 0, len(sorting.Columns))
+			for field := range sorting.Columns {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			return p, fmt.Errorf("%w: sort must be one of %s", ErrInvalidQuery, strings.Join(fields, ", "))
+		}
+		p.Sort = raw
+	}
+	p.column = sorting.Columns[p.Sort]
+
+	switch strings.ToLower(c.Query("order")) {
+	case "":
+	case "asc":
+		p.Desc = false
+	case "desc":
+		p.Desc = true
+	default:
+		return p, fmt.Errorf("%w: order must be asc or desc", ErrInvalidQuery)
+	}
+
+	return p, nil
+}
+
+// Offset is the number of rows to skip, never negative.
+func (p Params) Offset() int {
+	if p.Page < 1 {
+		return 0
+	}
+	return (p.Page - 1) * p.PageSize
+}
+
+// OrderBy is the ORDER BY clause of the sort field, built from the whitelisted
+// column only; tiebreak (e.g. "id") keeps pages stable.
+func (p Params) OrderBy(tiebreak string) string {
+	order := p.column + " " + p.direction()
+	if tiebreak != "" && tiebreak != p.column {
+		order += ", " + tiebreak + " " + p.direction()
+	}
+	return order
+}
+
+// Like is the search term as an ILIKE pattern, with wildcards escaped.
+func (p Params) Like() string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(p.Search) + "%"
+}
+
+func (p Params) direction() string {
+	if p.Desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// NewPage wraps one page of items with its metadata.
+func NewPage(items interface{}, p Params, total int64) Page {
+	return Page{
+		Items: items,
+		Meta: Meta{
+			Page:       p.Page,
+			PageSize:   p.PageSize,
+			Total:      total,
+			TotalPages: int(math.Ceil(float64(total) / float64(p.PageSize))),
+			Sort:       p.Sort,
+			Order:      strings.ToLower(p.direction()),
+			Search:     p.Search,
+		},
+	}
+}
+
+// Apply searches, sorts and pages a list already in memory, for data that
+// comes back from a database function rather than a query. match reports
+// whether an item matches the lower-cased search term; compare orders two
+// items by a sort field. It returns the page and the number of matches.
+func Apply[T any](items []T, p Params, match func(item T, search string) bool, compare func(a, b T, field string) int) ([]T, int64) {
+	filtered := make([]T, 0, len(items))
+	search := strings.ToLower(p.Search)
+	for _, item := range items {
+		if search == "" || match(item, search) {
+			filtered = append(filtered, item)
+		}
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if p.Desc {
+			return compare(filtered[j], filtered[i], p.Sort) < 0
+		}
+		return compare(filtered[i], filtered[j], p.Sort) < 0
+	})
+
+	total := int64(len(filtered))
+	start := min(p.Offset(), len(filtered))
+	end := min(start+p.PageSize, len(filtered))
+	return filtered[start:end], total
+}
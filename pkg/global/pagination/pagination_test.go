@@ -0,0 +1,175 @@
+package pagination
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+var roleSorting = Sorting{
+	Columns: map[string]string{"name": "r.name", "id": "r.id"},
+	Default: "name",
+}
+
+// parseQuery runs Parse against a request with the given query string.
+func parseQuery(t *testing.T, sorting Sorting, query string) (Params, error) {
+	t.Helper()
+
+	var p Params
+	var parseErr error
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) error {
+		p, parseErr = Parse(c, sorting)
+		return nil
+	})
+
+	if _, err := app.Test(httptest.NewRequest("GET", "/?"+query, nil)); err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	return p, parseErr
+}
+
+func TestParseRejectsBadQueries(t *testing.T) {
+	for _, query := range []string{
+		"page=0",
+		"page=-1",
+		"page=1.5",
+		"page_size=0",
+		"page_size=" + strconv.Itoa(MaxPageSize+1),
+		// Only whitelisted fields reach ORDER BY
+		"sort=password",
+		"sort=r.name%3B+DROP+TABLE+roles",
+		"order=sideways",
+		// The offset must fit whatever the page size, and huge pages must
+		// not wrap around to a negative offset
+		"page=" + strconv.Itoa(MaxOffset/DefaultPageSize+2),
+		"page=9223372036854775807&page_size=100",
+		"page=99999999999999999999",
+	} {
+		t.Run(query, func(t *testing.T) {
+			if _, err := parseQuery(t, roleSorting, query); !errors.Is(err, ErrInvalidQuery) {
+				t.Fatalf("err = %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	descByDefault := Sorting{Columns: roleSorting.Columns, Default: "id", Desc: true}
+
+	tests := []struct {
+		name    string
+		sorting Sorting
+		query   string
+		want    string
+	}{
+		{"default field with a tiebreak", roleSorting, "", "r.name ASC, r.id ASC"},
+		{"order is case-insensitive", roleSorting, "order=DESC", "r.name DESC, r.id DESC"},
+		// Sorting by the tiebreak column does not repeat it
+		{"sort by the tiebreak", roleSorting, "sort=id", "r.id ASC"},
+		{"endpoint default direction", descByDefault, "", "r.id DESC"},
+		{"explicit order overrides the default", descByDefault, "order=asc", "r.id ASC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseQuery(t, tt.sorting, tt.query)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := p.OrderBy("r.id"); got != tt.want {
+				t.Fatalf("OrderBy = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchAndPaging(t *testing.T) {
+	p, err := parseQuery(t, roleSorting, "q=+50%25_off+&page=3&page_size=50")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if p.Page != 3 || p.PageSize != 50 || p.Offset() != 100 {
+		t.Fatalf("Parse = %+v (offset %d), want page 3 of 50 at offset 100", p, p.Offset())
+	}
+	// The search is trimmed and its LIKE wildcards are taken literally
+	if p.Search != "50%_off" || p.Like() != `%50\%\_off%` {
+		t.Fatalf("Search = %q, Like = %q", p.Search, p.Like())
+	}
+	if got := (Params{Search: `C:\`}).Like(); got != `%C:\\%` {
+		t.Fatalf("Like = %q, want the backslash escaped", got)
+	}
+}
+
+func TestParseLastPageInRange(t *testing.T) {
+	last := MaxOffset/DefaultPageSize + 1
+	p, err := parseQuery(t, roleSorting, "page="+strconv.Itoa(last))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if p.Offset() != MaxOffset-MaxOffset%DefaultPageSize {
+		t.Fatalf("Offset = %d, want the largest multiple of %d within MaxOffset", p.Offset(), DefaultPageSize)
+	}
+}
+
+func TestApply(t *testing.T) {
+	type office struct{ name, region string }
+	offices := []office{{"Makati", "NCR"}, {"cebu", "VII"}, {"Baguio", "CAR"}, {"Manila", "NCR"}, {"Davao", "XI"}}
+	match := func(o office, search string) bool { return strings.Contains(strings.ToLower(o.name), search) }
+	compare := func(a, b office, field string) int {
+		if field == "region" {
+			return strings.Compare(a.region, b.region)
+		}
+		return strings.Compare(strings.ToLower(a.name), strings.ToLower(b.name))
+	}
+	names := func(list []office) string {
+		out := make([]string, len(list))
+		for i, o := range list {
+			out[i] = o.name
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		name      string
+		params    Params
+		want      string
+		wantTotal int64
+	}{
+		{"first page", Params{Page: 1, PageSize: 2, Sort: "name"}, "Baguio,cebu", 5},
+		{"last partial page", Params{Page: 3, PageSize: 2, Sort: "name"}, "Manila", 5},
+		{"past the end", Params{Page: 9, PageSize: 2, Sort: "name"}, "", 5},
+		{"descending", Params{Page: 1, PageSize: 2, Sort: "name", Desc: true}, "Manila,Makati", 5},
+		// Equal keys keep their original order in either direction
+		{"stable ties", Params{Page: 2, PageSize: 2, Sort: "region", Desc: true}, "Makati,Manila", 5},
+		{"search ignores case", Params{Page: 1, PageSize: 10, Sort: "name", Search: "MA"}, "Makati,Manila", 2},
+		// Params built by hand rather than by Parse must not panic
+		{"invalid page", Params{Page: -5, PageSize: 2, Sort: "name"}, "Baguio,cebu", 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total := Apply(offices, tt.params, match, compare)
+			if names(got) != tt.want || total != tt.wantTotal {
+				t.Fatalf("Apply = %s (%d), want %s (%d)", names(got), total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+
+	if offices[0].name != "Makati" {
+		t.Fatalf("Apply reordered its input")
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	for total, wantPages := range map[int64]int{0: 0, 1: 1, 20: 1, 21: 2} {
+		meta := NewPage([]string{}, Params{Page: 1, PageSize: 20, Sort: "name", Desc: true}, total).Meta
+		if meta.TotalPages != wantPages || meta.Order != "desc" {
+			t.Fatalf("total %d: Meta = %+v, want %d pages ordered desc", total, meta, wantPages)
+		}
+	}
+}
@@ -1,12 +1,10 @@
 package utils
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
+	"context"
+	"go_template_v3/pkg/global/httpclient"
 	"log"
-	"net/http"
+	"net/url"
 	"os/exec"
 	"strconv"
 	"time"
@@ -25,61 +23,27 @@ func TimestampToUnix(timestamp string) int64 {
 }
 
 func SendRequest(baseURL string, method string, body []byte, headers map[string]string, timeout int) (interface{}, error) {
-	reqBody := bytes.NewBuffer(body)
-
-	// Create the request
-	req, err := http.NewRequest(method, baseURL, reqBody)
-	if err != nil {
-		return nil, err
-	}
-
-	// Set default content-type header if not provided
-	if _, exists := headers["Content-Type"]; !exists {
-		req.Header.Set("Content-Type", "application/json")
-	}
-
-	// Add custom headers
-	for key, value := range headers {
-		fmt.Printf("HEADER: %s: %s\n", key, value)
-		req.Header.Set(key, value)
-	}
-
-	client := &http.Client{
-		Timeout: time.Second * time.Duration(timeout),
-	}
-
-	// Send the request
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	return SendRequestWithContext(context.Background(), baseURL, method, body, headers, timeout)
+}
 
-	// Read response body
-	body, err = io.ReadAll(resp.Body)
+// SendRequestWithContext sends a single-attempt request through the shared
+// client of the target host, so it still counts toward that host's circuit
+// breaker and metrics. Metrics are keyed by method only: paths can carry
+// usernames or IDs and would make the key set unbounded.
+func SendRequestWithContext(ctx context.Context, baseURL string, method string, body []byte, headers map[string]string, timeout int) (interface{}, error) {
+	parsed, err := url.Parse(baseURL)
 	if err != nil {
 		return nil, err
 	}
 
-	// Handle empty response
-	if len(body) == 0 {
-		return nil, nil
-	}
-
-	// Try to parse response as JSON object
-	var jsonRespObject map[string]interface{}
-	if err := json.Unmarshal(body, &jsonRespObject); err == nil {
-		return jsonRespObject, nil
-	}
-
-	// If parsing as JSON object fails, try as JSON array
-	var jsonRespArray []interface{}
-	if err := json.Unmarshal(body, &jsonRespArray); err == nil {
-		return jsonRespArray, nil
-	}
-
-	// If neither parsing works, return an error
-	return nil, fmt.Errorf("response is neither a JSON object nor a JSON array: %s", string(body))
+	return httpclient.ForName(parsed.Host).Do(ctx, httpclient.Request{
+		Endpoint: method,
+		Method:   method,
+		URL:      baseURL,
+		Body:     body,
+		Headers:  headers,
+		Timeout:  time.Second * time.Duration(timeout),
+	})
 }
 
 func SpeakNotif(message string) {
@@ -1,10 +1,15 @@
 package middleware
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	errAuth "go_template_v3/pkg/services/auth/error"
+	hlpAuth "go_template_v3/pkg/services/auth/helper"
 	mdlAuth "go_template_v3/pkg/services/auth/model"
-	scpAuth "go_template_v3/pkg/services/auth/script"
+	prvAuth "go_template_v3/pkg/services/auth/provider"
+	hlpRbac "go_template_v3/pkg/services/rbac/helper"
+	"log"
 	"net/http"
 	"strings"
 
@@ -71,71 +76,82 @@ func AuthMiddleware(c fiber.Ctx) error {
 		)
 	}
 
-	// 2. Call Cagabay validate-header API
-	apiURL := utils_v1.GetEnv("CAGABAY_BASE_URL") +
-		"/soteria-go/api/public/v1/auth/security-management/validate-header"
-
-	headers := map[string]string{
-		"Authorization": "Bearer " + tokenString,
-		"x-api-key":     utils_v1.GetEnv("CAGABAY_API_KEY"),
-		"Content-Type":  "application/json",
+	// 2. Serve repeated requests from the validation cache, unless the token
+	// was revoked by logout or user deletion since it was cached
+	if cached, ok := hlpAuth.GetCachedToken(c.Context(), tokenString); ok {
+		if hlpAuth.IsTokenRevoked(c.Context(), tokenString, cached.Details.Username) {
+			return v1.JSONResponseWithError(
+				c,
+				respcode.ERR_CODE_401,
+				"Token has been terminated",
+				nil,
+				http.StatusUnauthorized,
+			)
+		}
+		user, err := hlpRbac.GetUserPermissions(c.Context(), cached.Details.Username)
+		if err != nil {
+			return v1.JSONResponseWithError(c,
+				respcode.ERR_CODE_500,
+				"Failed to fetch User",
+				err,
+				http.StatusInternalServerError,
+			)
+		}
+		setAuthLocals(c, cached.Details, user)
+		return c.Next()
 	}
 
-	resp, err := utils_v1.SendRequest(apiURL, "GET", nil, headers, 10)
+	// 3. Validate token (locally against JWKS, or through the identity provider)
+	details, err := validateToken(c.Context(), tokenString)
 	if err != nil {
-		return v1.JSONResponseWithError(
-			c,
-			respcode.ERR_CODE_401,
-			"Token validation failed",
-			err,
-			http.StatusUnauthorized,
-		)
-	}
-
-	// 3. Parse Cagabay response
-	var apiResp mdlAuth.ValidateTokenAPIResponse
-	respBytes, _ := json.Marshal(resp)
-	if err := json.Unmarshal(respBytes, &apiResp); err != nil {
-		return v1.JSONResponseWithError(
-			c,
-			respcode.ERR_CODE_310,
-			"Failed to parse token validation response",
-			err,
-			http.StatusInternalServerError,
-		)
-	}
-
-	// 4. Handle validation result
-	switch apiResp.RetCode {
-	case "215":
-		// success → continue
-	case "109":
-		return v1.JSONResponseWithError(
-			c,
-			respcode.ERR_CODE_401,
-			"Token has been terminated",
-			nil,
-			http.StatusUnauthorized,
-		)
-	default:
-		return v1.JSONResponseWithError(
-			c,
-			respcode.ERR_CODE_401,
-			"Token validation failed",
-			nil,
-			http.StatusUnauthorized,
-		)
+		switch {
+		case errors.Is(err, errAuth.ErrValidationResponse):
+			return v1.JSONResponseWithError(
+				c,
+				respcode.ERR_CODE_310,
+				"Failed to parse token validation response",
+				err,
+				http.StatusInternalServerError,
+			)
+		case errors.Is(err, errAuth.ErrTokenTerminated):
+			return v1.JSONResponseWithError(
+				c,
+				respcode.ERR_CODE_401,
+				"Token has been terminated",
+				nil,
+				http.StatusUnauthorized,
+			)
+		case errors.Is(err, errAuth.ErrTokenExpired):
+			return v1.JSONResponseWithError(
+				c,
+				respcode.ERR_CODE_401,
+				"Token has expired",
+				nil,
+				http.StatusUnauthorized,
+			)
+		case errors.Is(err, errAuth.ErrValidationUnavailable):
+			return v1.JSONResponseWithError(
+				c,
+				respcode.ERR_CODE_401,
+				"Token validation failed",
+				err,
+				http.StatusUnauthorized,
+			)
+		default:
+			return v1.JSONResponseWithError(
+				c,
+				respcode.ERR_CODE_401,
+				"Token validation failed",
+				nil,
+				http.StatusUnauthorized,
+			)
+		}
 	}
 
-	// 5. Store validated data in context
-	if apiResp.Data != nil && apiResp.Data.Details != nil {
-		c.Locals("username", apiResp.Data.Details.Username)
-		c.Locals("institution_code", apiResp.Data.Details.InstiCode)
-		c.Locals("institution_name", apiResp.Data.Details.InstiName)
-		c.Locals("app_code", apiResp.Data.Details.AppCode)
-		c.Locals("app_name", apiResp.Data.Details.AppName)
+	// 4. Store validated data in context
+	if details != nil {
 		// Fetch user with permissions
-		user, err := scpAuth.GetUserByUsername(apiResp.Data.Details.Username)
+		user, err := hlpRbac.GetUserPermissions(c.Context(), details.Username)
 		if err != nil {
 			return v1.JSONResponseWithError(c,
 				respcode.ERR_CODE_500,
@@ -144,10 +160,40 @@ func AuthMiddleware(c fiber.Ctx) error {
 				http.StatusInternalServerError,
 			)
 		}
-		c.Locals("user", user)
+		setAuthLocals(c, details, user)
+		hlpAuth.CacheToken(c.Context(), tokenString, &hlpAuth.CachedToken{Details: details, Email: user.Email})
 		fmt.Printf("User %s authenticated with role: %s, permissions: %v\n",
 			user.Username, user.RoleName, user.Permissions)
 	}
 
 	return c.Next()
 }
+
+func setAuthLocals(c fiber.Ctx, details *mdlAuth.ValidateTokenDetails, user *mdlAuth.UserWithPermissions) {
+	c.Locals("username", details.Username)
+	c.Locals("institution_code", details.InstiCode)
+	c.Locals("institution_name", details.InstiName)
+	c.Locals("app_code", details.AppCode)
+	c.Locals("app_name", details.AppName)
+	c.Locals("user", user)
+}
+
+// validateToken picks the verification mode from AUTH_VERIFICATION_MODE.
+// REMOTE (default) always asks the identity provider. LOCAL verifies the JWT
+// against the cached JWKS, rejects tokens revoked by logout or user deletion,
+// and only falls back to the provider when it cannot decide.
+func validateToken(ctx context.Context, tokenString string) (*mdlAuth.ValidateTokenDetails, error) {
+	if strings.ToUpper(utils_v1.GetEnv("AUTH_VERIFICATION_MODE")) == "LOCAL" {
+		details, err := hlpAuth.VerifyTokenLocally(tokenString)
+		if err == nil && hlpAuth.IsTokenRevoked(ctx, tokenString, details.Username) {
+			// Logged out or deleted since the token was issued
+			return nil, errAuth.ErrTokenTerminated
+		}
+		if !errors.Is(err, errAuth.ErrVerificationUndecided) {
+			return details, err
+		}
+		log.Printf("Local token verification undecided, falling back to identity provider: %v\n", err)
+	}
+
+	return prvAuth.Current().ValidateToken(ctx, tokenString)
+}
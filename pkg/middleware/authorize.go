@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+	hlpRbac "go_template_v3/pkg/services/rbac/helper"
+	mdlRbac "go_template_v3/pkg/services/rbac/model"
+	scpRbac "go_template_v3/pkg/services/rbac/script"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Authorize evaluates req for the authenticated user of the request. resource
+// holds attributes of the record being acted on (e.g. "owner", "branch") and
+// is exposed to grant conditions as resource.<key>; pass nil when there is no
+// record. Handlers call this for record-level checks after loading the record:
+//
+//	decision := middleware.Authorize(c, hlpRbac.Permission("update:user"),
+//		map[string]interface{}{"branch": target.BranchCode})
+//	if !decision.Allowed { ... }
+func Authorize(c fiber.Ctx, req hlpRbac.Requirement, resource map[string]interface{}) hlpRbac.Decision {
+	user, ok := c.Locals("user").(*mdlAuth.UserWithPermissions)
+	if !ok || user == nil {
+		return hlpRbac.Decision{Code: hlpRbac.ReasonUserNotFound, MatchedGrants: []string{}, Reason: "no authenticated user"}
+	}
+
+	attrs := RequestAttributes(c, user)
+	if resource != nil {
+		attrs = attrs.Merge("resource.", resource)
+	}
+
+	// Superuser roles pass anyway, but every bypass is audited
+	decision := hlpRbac.Decide(user, tokenInstitution(c), permissionSet(c, user), req, attrs)
+	if decision.Code == hlpRbac.ReasonSuperuser {
+		auditBypass(c, user, req)
+	}
+
+	return decision
+}
+
+// RequestAttributes exposes the subject and request to grant conditions:
+//
+//	subject.username, subject.staff_id, subject.institution, subject.branch,
+//	subject.roles, request.method, request.path, request.ip, request.hour,
+//	request.weekday (0 = Sunday), request.date and request.param.<name>.
+//
+// subject.branch is read from Locals("branch_code") when a handler or an
+// upstream middleware has set it.
+func RequestAttributes(c fiber.Ctx, user *mdlAuth.UserWithPermissions) hlpRbac.Attributes {
+	attrs := hlpRbac.SubjectAttributes(user, tokenInstitution(c))
+	for k, v := range hlpRbac.TimeAttributes(time.Now()) {
+		attrs[k] = v
+	}
+	attrs["request.method"] = c.Method()
+	attrs["request.path"] = c.Path()
+	attrs["request.ip"] = c.IP()
+	if branch, ok := c.Locals("branch_code").(string); ok {
+		attrs["subject.branch"] = branch
+	}
+	for _, name := range c.Route().Params {
+		attrs["request.param."+strings.TrimPrefix(name, ":")] = c.Params(name)
+	}
+	return attrs
+}
+
+func auditBypass(c fiber.Ctx, user *mdlAuth.UserWithPermissions, req hlpRbac.Requirement) {
+	detail := map[string]interface{}{
+		"role":        user.RoleName,
+		"institution": tokenInstitution(c),
+		"requirement": req.String(),
+		"method":      c.Method(),
+		"path":        c.Path(),
+	}
+	if err := scpRbac.RecordAudit(mdlRbac.AuditSuperuserBypass, user.Username, c.Path(), detail); err != nil {
+		log.Printf("Failed to audit superuser bypass for %s: %v", user.Username, err)
+	}
+}
+
+// permissionSet builds the lookup set of grants that apply to the token's
+// institution once per request and keeps it in Locals for any further checks
+// down the chain.
+func permissionSet(c fiber.Ctx, user *mdlAuth.UserWithPermissions) hlpRbac.PermissionSet {
+	if set, ok := c.Locals("permission_set").(hlpRbac.PermissionSet); ok {
+		return set
+	}
+	set := hlpRbac.ScopedPermissionSet(user, tokenInstitution(c))
+	c.Locals("permission_set", set)
+	return set
+}
+
+func tokenInstitution(c fiber.Ctx) string {
+	institutionCode, _ := c.Locals("institution_code").(string)
+	return institutionCode
+}
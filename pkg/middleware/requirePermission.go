@@ -1,17 +1,46 @@
 package middleware
 
 import (
-	"fmt"
 	mdlAuth "go_template_v3/pkg/services/auth/model"
+	hlpRbac "go_template_v3/pkg/services/rbac/helper"
 
 	v1 "github.com/FDSAP-Git-Org/hephaestus/helper/v1"
 	"github.com/FDSAP-Git-Org/hephaestus/respcode"
 	"github.com/gofiber/fiber/v3"
 )
 
+// RequirePermission allows the request when the user holds the single
+// "action:resource" permission, directly or through a wildcard grant.
 func RequirePermission(permission string) fiber.Handler {
+	return Require(hlpRbac.Permission(permission))
+}
+
+// RequireAny allows the request when the user holds at least one of the permissions.
+func RequireAny(permissions ...string) fiber.Handler {
+	return Require(hlpRbac.Any(permissions...))
+}
+
+// RequireAll allows the request only when the user holds every permission.
+func RequireAll(permissions ...string) fiber.Handler {
+	return Require(hlpRbac.All(permissions...))
+}
+
+// RequireExpr allows the request when the expression holds, e.g.
+// "view:role && (update:role || *:role)". The expression is parsed once at
+// route registration; an invalid one panics so it is caught at startup.
+func RequireExpr(expr string) fiber.Handler {
+	req, err := hlpRbac.ParseRequirement(expr)
+	if err != nil {
+		panic(err)
+	}
+	return Require(req)
+}
+
+// Require allows the request when the user's permissions satisfy req.
+// Conditional grants are evaluated against subject and request attributes
+// only; handlers that need resource attributes call Authorize themselves.
+func Require(req hlpRbac.Requirement) fiber.Handler {
 	return func(c fiber.Ctx) error {
-		fmt.Println("CHECKING PERMISSIONS...")
 		// 1️⃣ Check if user context exists
 		rawUser := c.Locals("user")
 
@@ -21,24 +50,17 @@ func RequirePermission(permission string) fiber.Handler {
 		}
 
 		// 2️⃣ Validate type assertion
-		user, ok := rawUser.(*mdlAuth.UserWithPermissions)
-		if !ok || user == nil {
+		if user, ok := rawUser.(*mdlAuth.UserWithPermissions); !ok || user == nil {
 			// Internal server error
 			return v1.JSONResponse(c, respcode.ERR_CODE_300, "Invalid user context.", fiber.StatusInternalServerError)
 		}
 
-		// 3️⃣ Automatically allow super admin
-		if user.RoleName == "super_admin" {
+		// 3️⃣ Check required permissions
+		if decision := Authorize(c, req, nil); decision.Allowed {
 			return c.Next()
 		}
 
-		// 4️⃣ Check required permission
-		for _, p := range user.Permissions {
-			if p == permission {
-				return c.Next()
-			}
-		}
-		// 5️⃣ Permission denied
+		// 4️⃣ Permission denied
 		// "Access denied."
 		return v1.JSONResponse(c, respcode.ERR_CODE_105_CD, respcode.ERR_CODE_105_CD_MSG, fiber.StatusForbidden)
 	}
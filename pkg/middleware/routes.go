@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	hlpRbac "go_template_v3/pkg/services/rbac/helper"
+	mdlRbac "go_template_v3/pkg/services/rbac/model"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Routes registers handlers on a group and records each route with the
+// permission it requires in the RBAC route registry, which is validated at
+// startup and served by GET /rbac/routes. The permission check always runs
+// before the handler.
+type Routes struct {
+	router fiber.Router
+	prefix string
+	access string
+}
+
+// SecuredRoutes wraps a group that already runs AuthMiddleware. Routes
+// registered on it with an empty expression only need a valid token.
+func SecuredRoutes(router fiber.Router) *Routes {
+	return &Routes{router: router, prefix: groupPrefix(router), access: mdlRbac.RouteAccessAuthenticated}
+}
+
+// PublicRoutes wraps a group without authentication. Its routes cannot
+// require permissions.
+func PublicRoutes(router fiber.Router) *Routes {
+	return &Routes{router: router, prefix: groupPrefix(router), access: mdlRbac.RouteAccessPublic}
+}
+
+func groupPrefix(router fiber.Router) string {
+	if group, ok := router.(*fiber.Group); ok {
+		return group.Prefix
+	}
+	return ""
+}
+
+func (r *Routes) Get(path, expr string, handler fiber.Handler) {
+	r.Add(fiber.MethodGet, path, expr, handler)
+}
+
+func (r *Routes) Post(path, expr string, handler fiber.Handler) {
+	r.Add(fiber.MethodPost, path, expr, handler)
+}
+
+func (r *Routes) Put(path, expr string, handler fiber.Handler) {
+	r.Add(fiber.MethodPut, path, expr, handler)
+}
+
+func (r *Routes) Delete(path, expr string, handler fiber.Handler) {
+	r.Add(fiber.MethodDelete, path, expr, handler)
+}
+
+// Add registers handler behind expr, a requirement expression as accepted by
+// RequireExpr (e.g. "view:role" or "create:permission && delete:permission").
+// An invalid expression, or one on a public group, panics at startup.
+func (r *Routes) Add(method, path, expr string, handler fiber.Handler) {
+	if expr == "" {
+		hlpRbac.RegisterRoute(method, r.prefix+path, r.access, nil)
+		r.router.Add([]string{method}, path, handler)
+		return
+	}
+
+	if r.access == mdlRbac.RouteAccessPublic {
+		panic("permission " + expr + " on public route " + method + " " + r.prefix + path)
+	}
+	req, err := hlpRbac.ParseRequirement(expr)
+	if err != nil {
+		panic(err)
+	}
+
+	hlpRbac.RegisterRoute(method, r.prefix+path, mdlRbac.RouteAccessPermission, req)
+	r.router.Add([]string{method}, path, Require(req), handler)
+}
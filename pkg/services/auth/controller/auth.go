@@ -1,19 +1,22 @@
 package ctrAuth
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	v1 "github.com/FDSAP-Git-Org/hephaestus/helper/v1"
 	"github.com/FDSAP-Git-Org/hephaestus/respcode"
-	utils_v1 "github.com/FDSAP-Git-Org/hephaestus/utils/v1"
 	"github.com/gofiber/fiber/v3"
 
+	errAuth "go_template_v3/pkg/services/auth/error"
 	hlpAuth "go_template_v3/pkg/services/auth/helper"
 	mdlAuth "go_template_v3/pkg/services/auth/model"
+	prvAuth "go_template_v3/pkg/services/auth/provider"
 	scpAuth "go_template_v3/pkg/services/auth/script"
+	hlpRbac "go_template_v3/pkg/services/rbac/helper"
 )
 
 // ============================================
@@ -40,30 +43,9 @@ func RegisterUser(c fiber.Ctx) error {
 	}
 
 	// Call external staff registration endpoint
-	apiURL := utils_v1.GetEnv("CAGABAY_BASE_URL") + "/soteria-go/api/public/v1/auth/user-management/register-new-user/staff"
-	headers := map[string]string{
-		"Content-Type": "application/json",
-		"x-api-key":    utils_v1.GetEnv("CAGABAY_API_KEY"),
-	}
-
-	body, _ := json.Marshal(apiReq)
-	resp, err := utils_v1.SendRequest(apiURL, "POST", body, headers, 30)
+	apiResp, err := prvAuth.Current().RegisterStaff(c.Context(), &apiReq)
 	if err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_405,
-			"Request to external API failed", err, http.StatusInternalServerError)
-	}
-
-	// Unmarshal to typed struct
-	var apiResp mdlAuth.StaffRegistrationAPIResponse
-	respBytes, _ := json.Marshal(resp)
-	if err := json.Unmarshal(respBytes, &apiResp); err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_310,
-			"Failed to parse external API response", err, http.StatusInternalServerError)
-	}
-
-	if apiResp.RetCode != "203" {
-		return v1.JSONResponseWithError(c, apiResp.RetCode,
-			apiResp.Data.Message, nil, http.StatusBadRequest)
+		return providerErrorResponse(c, err)
 	}
 
 	// Hash password
@@ -106,30 +88,9 @@ func LoginUser(c fiber.Ctx) error {
 	}
 
 	// Call external login API
-	apiURL := utils_v1.GetEnv("CAGABAY_BASE_URL") + "/soteria-go/api/public/v1/auth/user-logs/login"
-	headers := map[string]string{
-		"Content-Type": "application/json",
-		"x-api-key":    utils_v1.GetEnv("CAGABAY_API_KEY"),
-	}
-
-	body, _ := json.Marshal(req)
-	resp, err := utils_v1.SendRequest(apiURL, "POST", body, headers, 30)
+	apiResp, err := prvAuth.Current().Login(c.Context(), &req)
 	if err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_405,
-			"Request to external API failed", err, http.StatusInternalServerError)
-	}
-
-	// Unmarshal to typed struct
-	var apiResp mdlAuth.LoginAPIResponse
-	respBytes, _ := json.Marshal(resp)
-	if err := json.Unmarshal(respBytes, &apiResp); err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_310,
-			"Failed to parse external API response", err, http.StatusInternalServerError)
-	}
-
-	if apiResp.RetCode != "201" {
-		return v1.JSONResponseWithError(c, apiResp.RetCode,
-			apiResp.Data.Message, nil, http.StatusBadRequest)
+		return providerErrorResponse(c, err)
 	}
 
 	// Check if user exists in DB
@@ -163,49 +124,22 @@ func LogoutUser(c fiber.Ctx) error {
 	}
 
 	// Call external logout API
-	apiURL := utils_v1.GetEnv("CAGABAY_BASE_URL") +
-		"/soteria-go/api/public/v1/auth/user-logs/logout"
-
-	headers := map[string]string{
-		"Content-Type": "application/json",
-		"x-api-key":    utils_v1.GetEnv("CAGABAY_API_KEY"),
-	}
-
-	body, _ := json.Marshal(req)
-	resp, err := utils_v1.SendRequest(apiURL, "POST", body, headers, 30)
+	apiResp, err := prvAuth.Current().Logout(c.Context(), &req)
 	if err != nil {
-		return v1.JSONResponseWithError(
-			c,
-			respcode.ERR_CODE_405,
-			"Request to external API failed",
-			err,
-			http.StatusInternalServerError,
-		)
+		return providerErrorResponse(c, err)
 	}
 
-	// Parse external API response
-	var apiResp mdlAuth.LogoutAPIResponse
-	respBytes, _ := json.Marshal(resp)
-	if err := json.Unmarshal(respBytes, &apiResp); err != nil {
-		return v1.JSONResponseWithError(
-			c,
-			respcode.ERR_CODE_310,
-			"Failed to parse external API response",
-			err,
-			http.StatusInternalServerError,
-		)
-	}
-
-	// Handle non-success logout
-	if apiResp.RetCode != "202" {
-		return v1.JSONResponseWithError(
-			c,
-			apiResp.RetCode,
-			apiResp.Data.Message,
-			nil,
-			http.StatusBadRequest,
-		)
+	// Terminated tokens must stop working immediately
+	hlpAuth.EvictUserTokens(c.Context(),
+		req.UserIdentity,
+		apiResp.Data.Details.Username,
+		apiResp.Data.Details.Email,
+	)
+	if token := strings.TrimPrefix(c.Get("Authorization"), "Bearer "); token != "" {
+		hlpAuth.EvictToken(c.Context(), token)
+		hlpAuth.RevokeToken(c.Context(), token)
 	}
+	hlpAuth.RevokeUserTokens(c.Context(), apiResp.Data.Details.Username)
 
 	// Get internal user ID by email
 	userID, err := scpAuth.GetUserIDByEmail(apiResp.Data.Details.Email)
@@ -251,30 +185,9 @@ func ChangeTempPassword(c fiber.Ctx) error {
 	}
 
 	// External API call
-	apiURL := utils_v1.GetEnv("CAGABAY_BASE_URL") + "/soteria-go/api/public/v1/auth/security-management/change-password"
-	headers := map[string]string{
-		"Content-Type": "application/json",
-		"x-api-key":    utils_v1.GetEnv("CAGABAY_API_KEY"),
-	}
-
-	body, _ := json.Marshal(req)
-	resp, err := utils_v1.SendRequest(apiURL, "POST", body, headers, 30)
+	apiResp, err := prvAuth.Current().ChangePassword(c.Context(), &req)
 	if err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_405,
-			"Request to external API failed", err, http.StatusInternalServerError)
-	}
-
-	// Parse external response
-	var apiResp mdlAuth.ChangePasswordAPIResponse
-	respBytes, _ := json.Marshal(resp)
-	if err := json.Unmarshal(respBytes, &apiResp); err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_310,
-			"Failed to parse external API response", err, http.StatusInternalServerError)
-	}
-
-	if apiResp.RetCode != "203" {
-		return v1.JSONResponseWithError(c, apiResp.RetCode,
-			apiResp.Data.Message, nil, http.StatusBadRequest)
+		return providerErrorResponse(c, err)
 	}
 
 	// Update local DB
@@ -303,40 +216,24 @@ func DeleteUser(c fiber.Ctx) error {
 	}
 
 	// Call external API
-	apiURL := utils_v1.GetEnv("CAGABAY_BASE_URL") + "/soteria-go/api/public/v1/auth/user-management/delete-user"
-	headers := map[string]string{
-		"Content-Type":  "application/json",
-		"x-api-key":     utils_v1.GetEnv("CAGABAY_API_KEY"),
-		"Authorization": authHeader,
-	}
-
-	body, _ := json.Marshal(req)
-	resp, err := utils_v1.SendRequest(apiURL, "POST", body, headers, 30)
+	apiResp, err := prvAuth.Current().DeleteUser(c.Context(), authHeader, &req)
 	if err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_405,
-			"Request to external API failed", err, http.StatusInternalServerError)
-	}
-
-	// Parse response
-	var apiResp mdlAuth.DeleteUserAPIResponse
-	respBytes, _ := json.Marshal(resp)
-
-	if err := json.Unmarshal(respBytes, &apiResp); err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_310,
-			"Failed to parse external API response", err, http.StatusInternalServerError)
-	}
-
-	if apiResp.RetCode != "210" {
-		return v1.JSONResponseWithError(c, apiResp.RetCode,
-			apiResp.Data.Message, nil, http.StatusBadRequest)
+		return providerErrorResponse(c, err)
 	}
 
 	// Delete internally
-	if err := scpAuth.DeleteUserByIdentity(req.UserIdentity); err != nil {
+	usernames, err := scpAuth.DeleteUserByIdentity(req.UserIdentity)
+	if err != nil {
 		return v1.JSONResponseWithError(c, "314",
 			"Deleting Data Failed", err, http.StatusInternalServerError)
 	}
 
+	// Drop any cached token and permission set of the deleted user; the
+	// identity may be an email, so revocations and permissions go by username
+	hlpAuth.EvictUserTokens(c.Context(), req.UserIdentity)
+	hlpAuth.RevokeUserTokens(c.Context(), usernames...)
+	hlpRbac.EvictUserPermissions(c.Context(), usernames...)
+
 	return v1.JSONResponseWithData(c, apiResp.RetCode,
 		apiResp.Data.Message, nil, http.StatusOK)
 }
@@ -358,35 +255,9 @@ func UpdateUser(c fiber.Ctx) error {
 	}
 
 	// Call external API
-	apiURL := utils_v1.GetEnv("CAGABAY_BASE_URL") +
-		"/soteria-go/api/public/v1/auth/user-management/update-user/staff/" + username
-
-	headers := map[string]string{
-		"Content-Type":  "application/json",
-		"x-api-key":     utils_v1.GetEnv("CAGABAY_API_KEY"),
-		"Authorization": authHeader,
-	}
-
-	body, _ := json.Marshal(req)
-	resp, err := utils_v1.SendRequest(apiURL, "POST", body, headers, 30)
+	apiResp, err := prvAuth.Current().UpdateUser(c.Context(), authHeader, username, &req)
 	if err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_405,
-			"Request to external API failed", err, http.StatusInternalServerError)
-	}
-
-	// Unmarshal external response
-	var apiResp mdlAuth.UpdateUserAPIResponse
-	respBytes, _ := json.Marshal(resp)
-
-	if err := json.Unmarshal(respBytes, &apiResp); err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_310,
-			"Failed to parse external API response", err, http.StatusInternalServerError)
-	}
-
-	// API failure
-	if apiResp.RetCode != "203" && apiResp.RetCode != "204" {
-		return v1.JSONResponseWithError(c, apiResp.RetCode,
-			apiResp.Data.Message, nil, http.StatusBadRequest)
+		return providerErrorResponse(c, err)
 	}
 
 	// Get user ID from DB
@@ -406,6 +277,23 @@ func UpdateUser(c fiber.Ctx) error {
 		apiResp.Data.Message, apiResp.Data.Details, http.StatusOK)
 }
 
+// providerErrorResponse maps identity provider errors onto the responses the
+// auth endpoints have always returned.
+func providerErrorResponse(c fiber.Ctx, err error) error {
+	var rejection *errAuth.ProviderRejection
+	switch {
+	case errors.As(err, &rejection):
+		return v1.JSONResponseWithError(c, rejection.RetCode,
+			rejection.Message, nil, http.StatusBadRequest)
+	case errors.Is(err, errAuth.ErrProviderResponse):
+		return v1.JSONResponseWithError(c, respcode.ERR_CODE_310,
+			"Failed to parse external API response", err, http.StatusInternalServerError)
+	default:
+		return v1.JSONResponseWithError(c, respcode.ERR_CODE_405,
+			"Request to external API failed", err, http.StatusInternalServerError)
+	}
+}
+
 // ============================================
 // FORGOT PASSWORD ENDPOINT
 // ============================================
@@ -0,0 +1,28 @@
+package errAuth
+
+import "errors"
+
+var (
+	ErrTokenInvalid          = errors.New("token is invalid")
+	ErrTokenExpired          = errors.New("token has expired")
+	ErrTokenTerminated       = errors.New("token has been terminated")
+	ErrVerificationUndecided = errors.New("token cannot be verified locally")
+	ErrValidationUnavailable = errors.New("token validation service unavailable")
+	ErrValidationResponse    = errors.New("invalid token validation response")
+)
+
+var (
+	ErrProviderUnavailable = errors.New("identity provider request failed")
+	ErrProviderResponse    = errors.New("invalid identity provider response")
+)
+
+// ProviderRejection is returned when the identity provider answers with a
+// non-success retCode. Controllers forward the code and message as-is.
+type ProviderRejection struct {
+	RetCode string
+	Message string
+}
+
+func (e *ProviderRejection) Error() string {
+	return "identity provider rejected request: " + e.RetCode + " " + e.Message
+}
@@ -0,0 +1,258 @@
+package hlpAuth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"go_template_v3/pkg/global/httpclient"
+
+	utils_v1 "github.com/FDSAP-Git-Org/hephaestus/utils/v1"
+)
+
+// ============================================
+// JWKS KEY SET CACHE
+// ============================================
+
+const (
+	defaultJWKSRefreshInterval = 5 * time.Minute
+	// Minimum gap between forced refreshes triggered by unknown key IDs,
+	// so a flood of forged tokens cannot hammer the JWKS endpoint.
+	minJWKSForcedRefresh = 30 * time.Second
+)
+
+var errKeyNotFound = errors.New("signing key not found in key set")
+
+type jwksDocument struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type keySet struct {
+	mu            sync.RWMutex
+	keys          map[string]crypto.PublicKey
+	fetchedAt     time.Time
+	lastAttemptAt time.Time
+	startOnce     sync.Once
+}
+
+var (
+	jwks           = &keySet{}
+	jwksClientOnce sync.Once
+	jwksHTTP       *httpclient.Client
+)
+
+// jwksClient builds the JWKS client on first use, after main has loaded the
+// env file, so it shares the CAGABAY_BREAKER_* settings.
+func jwksClient() *httpclient.Client {
+	jwksClientOnce.Do(func() {
+		jwksHTTP = httpclient.New("cagabay-jwks", httpclient.BreakerConfig{
+			FailureThreshold: jwksEnvInt("CAGABAY_BREAKER_THRESHOLD"),
+			OpenFor:          time.Duration(jwksEnvInt("CAGABAY_BREAKER_OPEN_SECONDS")) * time.Second,
+		}).SetPolicy("jwks", httpclient.IdempotentRetry)
+	})
+	return jwksHTTP
+}
+
+// jwksEnvInt returns 0 for unset or invalid values so the breaker defaults apply.
+func jwksEnvInt(key string) int {
+	if value, err := strconv.Atoi(utils_v1.GetEnv(key)); err == nil && value > 0 {
+		return value
+	}
+	return 0
+}
+
+// jwksRefreshInterval reads JWKS_REFRESH_INTERVAL (in seconds).
+func jwksRefreshInterval() time.Duration {
+	if secs, err := strconv.Atoi(utils_v1.GetEnv("JWKS_REFRESH_INTERVAL")); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return defaultJWKSRefreshInterval
+}
+
+// start launches the background refresher on first use.
+func (ks *keySet) start() {
+	ks.startOnce.Do(func() {
+		if err := ks.refresh(); err != nil {
+			log.Printf("Initial JWKS fetch failed: %v", err)
+		}
+
+		go func() {
+			ticker := time.NewTicker(jwksRefreshInterval())
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := ks.refresh(); err != nil {
+					// Keep serving the previous keys until the next tick
+					log.Printf("JWKS refresh failed: %v", err)
+				}
+			}
+		}()
+	})
+}
+
+// refresh downloads the key set from CAGABAY_JWKS_URL and swaps it in.
+func (ks *keySet) refresh() error {
+	ks.mu.Lock()
+	ks.lastAttemptAt = time.Now()
+	ks.mu.Unlock()
+
+	apiURL := utils_v1.GetEnv("CAGABAY_JWKS_URL")
+	if apiURL == "" {
+		return fmt.Errorf("CAGABAY_JWKS_URL is not configured")
+	}
+
+	headers := map[string]string{
+		"x-api-key": utils_v1.GetEnv("CAGABAY_API_KEY"),
+	}
+
+	resp, err := jwksClient().Do(context.Background(), httpclient.Request{
+		Endpoint: "jwks",
+		Method:   "GET",
+		URL:      apiURL,
+		Headers:  headers,
+		Timeout:  10 * time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %v", err)
+	}
+
+	var doc jwksDocument
+	respBytes, _ := json.Marshal(resp)
+	if err := json.Unmarshal(respBytes, &doc); err != nil {
+		return fmt.Errorf("failed to parse JWKS: %v", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Printf("Skipping JWKS key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS contains no usable signing keys")
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.fetchedAt = time.Now()
+	ks.mu.Unlock()
+
+	return nil
+}
+
+// lookup returns the key for kid. An unknown kid triggers one rate-limited
+// refresh so keys rotated on the provider side are picked up immediately.
+func (ks *keySet) lookup(kid string) (crypto.PublicKey, error) {
+	ks.start()
+
+	if key, ok := ks.get(kid); ok {
+		return key, nil
+	}
+
+	ks.mu.RLock()
+	canForce := time.Since(ks.lastAttemptAt) >= minJWKSForcedRefresh
+	ks.mu.RUnlock()
+
+	if canForce {
+		if err := ks.refresh(); err != nil {
+			return nil, err
+		}
+		if key, ok := ks.get(kid); ok {
+			return key, nil
+		}
+	}
+
+	return nil, errKeyNotFound
+}
+
+func (ks *keySet) get(kid string) (crypto.PublicKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	// Tokens without a kid are accepted only when the set is unambiguous
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeJWKInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func decodeJWKInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64url value: %v", err)
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
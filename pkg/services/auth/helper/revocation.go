@@ -0,0 +1,119 @@
+package hlpAuth
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	utils_v1 "github.com/FDSAP-Git-Org/hephaestus/utils/v1"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ============================================
+// TOKEN REVOCATION
+// ============================================
+//
+// Locally verified tokens never reach Cagabay, so logout and user deletion
+// record revocations here and AuthMiddleware checks them before accepting a
+// local verification. Entries share the token cache backend; use redis when
+// running more than one instance.
+
+const (
+	defaultRevocationTTL = 24 * time.Hour
+	revokedTokenPrefix   = "auth:revoked:"
+	revokedUserPrefix    = "auth:revoked-user:"
+)
+
+// RevocationTTL reads TOKEN_REVOCATION_TTL (in seconds). It must cover the
+// longest token lifetime Cagabay issues.
+func RevocationTTL() time.Duration {
+	if secs, err := strconv.Atoi(utils_v1.GetEnv("TOKEN_REVOCATION_TTL")); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return defaultRevocationTTL
+}
+
+// RevokeToken rejects the token until it expires.
+func RevokeToken(ctx context.Context, tokenString string) {
+	ttl := RevocationTTL()
+	if exp, ok := tokenTime(tokenString, "exp"); ok {
+		// Keep the entry past the verification leeway, never past the cap
+		ttl = min(time.Until(exp)+time.Minute, ttl)
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	if err := store().Set(ctx, revokedTokenPrefix+tokenKey(tokenString), []byte("1"), ttl); err != nil {
+		log.Printf("Token revocation failed: %v", err)
+	}
+}
+
+// RevokeUserTokens rejects every token of the usernames issued up to now.
+func RevokeUserTokens(ctx context.Context, usernames ...string) {
+	now := []byte(strconv.FormatInt(time.Now().Unix(), 10))
+	for _, username := range usernames {
+		if username == "" {
+			continue
+		}
+		if err := store().Set(ctx, revokedUserKey(username), now, RevocationTTL()); err != nil {
+			log.Printf("User token revocation failed: %v", err)
+		}
+	}
+}
+
+// IsTokenRevoked reports whether the token, or every token of username issued
+// up to a revocation, was revoked. iat only has second precision, so a token
+// issued in the same second as the revocation counts as revoked. Tokens
+// without an iat claim are treated as revoked once their user is.
+func IsTokenRevoked(ctx context.Context, tokenString, username string) bool {
+	if _, ok, err := store().Get(ctx, revokedTokenPrefix+tokenKey(tokenString)); err != nil {
+		log.Printf("Token revocation read failed: %v", err)
+	} else if ok {
+		return true
+	}
+
+	raw, ok, err := store().Get(ctx, revokedUserKey(username))
+	if err != nil {
+		log.Printf("User token revocation read failed: %v", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	revokedAt, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return false
+	}
+	issuedAt, ok := tokenTime(tokenString, "iat")
+	return !ok || issuedAt.Unix() <= revokedAt
+}
+
+func revokedUserKey(username string) string {
+	return revokedUserPrefix + strings.ToLower(strings.TrimSpace(username))
+}
+
+// tokenTime reads a numeric date claim without verifying the token; callers
+// only use it to size or compare revocations.
+func tokenTime(tokenString, name string) (time.Time, bool) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return time.Time{}, false
+	}
+
+	var date *jwt.NumericDate
+	var err error
+	switch name {
+	case "exp":
+		date, err = claims.GetExpirationTime()
+	case "iat":
+		date, err = claims.GetIssuedAt()
+	}
+	if err != nil || date == nil {
+		return time.Time{}, false
+	}
+	return date.Time, true
+}
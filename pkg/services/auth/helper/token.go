@@ -0,0 +1,104 @@
+package hlpAuth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	errAuth "go_template_v3/pkg/services/auth/error"
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+
+	utils_v1 "github.com/FDSAP-Git-Org/hephaestus/utils/v1"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ============================================
+// LOCAL TOKEN VERIFICATION
+// ============================================
+
+var localSigningMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// VerifyTokenLocally checks the token signature, expiry and claims against the
+// cached JWKS. It returns errAuth.ErrVerificationUndecided when the token has
+// to be sent to Cagabay instead (opaque token, unknown key, JWKS down, or
+// missing identity claims). Revocations are checked by the caller through
+// IsTokenRevoked.
+func VerifyTokenLocally(tokenString string) (*mdlAuth.ValidateTokenDetails, error) {
+	// Tokens signed with an algorithm we hold no keys for are left to Cagabay
+	unverified, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		// Not a JWT at all, most likely an opaque Cagabay session token
+		return nil, fmt.Errorf("%w: %v", errAuth.ErrVerificationUndecided, err)
+	}
+	if !slices.Contains(localSigningMethods, unverified.Method.Alg()) {
+		return nil, fmt.Errorf("%w: unsupported signing method %s", errAuth.ErrVerificationUndecided, unverified.Method.Alg())
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(localSigningMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if issuer := utils_v1.GetEnv("JWT_ISSUER"); issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience := utils_v1.GetEnv("JWT_AUDIENCE"); audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	var keyErr error
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := jwks.lookup(kid)
+		if err != nil {
+			keyErr = err
+		}
+		return key, err
+	}, opts...)
+
+	switch {
+	case keyErr != nil:
+		return nil, fmt.Errorf("%w: %v", errAuth.ErrVerificationUndecided, keyErr)
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, errAuth.ErrTokenExpired
+	case err != nil || !token.Valid:
+		return nil, fmt.Errorf("%w: %v", errAuth.ErrTokenInvalid, err)
+	}
+
+	details := detailsFromClaims(claims)
+	if details.Username == "" {
+		return nil, fmt.Errorf("%w: username claim missing", errAuth.ErrVerificationUndecided)
+	}
+
+	return details, nil
+}
+
+// detailsFromClaims maps the token claims onto the same shape returned by the
+// Cagabay validate-header endpoint. Claims may sit at the top level or under
+// a "body" object.
+func detailsFromClaims(claims jwt.MapClaims) *mdlAuth.ValidateTokenDetails {
+	source := map[string]interface{}(claims)
+	if body, ok := claims["body"].(map[string]interface{}); ok {
+		source = body
+	}
+
+	claim := func(key string) string {
+		if value, ok := source[key].(string); ok {
+			return value
+		}
+		if value, ok := claims[key].(string); ok {
+			return value
+		}
+		return ""
+	}
+
+	return &mdlAuth.ValidateTokenDetails{
+		Username:  claim("username"),
+		InstiCode: claim("insti_code"),
+		InstiName: claim("insti_name"),
+		AppCode:   claim("app_code"),
+		AppName:   claim("app_name"),
+	}
+}
@@ -0,0 +1,159 @@
+package hlpAuth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go_template_v3/pkg/global/cache"
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+
+	utils_v1 "github.com/FDSAP-Git-Org/hephaestus/utils/v1"
+)
+
+// ============================================
+// TOKEN VALIDATION CACHE
+// ============================================
+
+const (
+	defaultTokenCacheTTL = 60 * time.Second
+	tokenKeyPrefix       = "auth:token:"
+	tokenUserIndexPrefix = "auth:token-user:"
+)
+
+var (
+	tokenStore     cache.Store
+	tokenStoreOnce sync.Once
+)
+
+// CachedToken is what AuthMiddleware stores per bearer token. Permissions are
+// not stored here; they live in the versioned RBAC cache so role changes
+// apply without waiting for the token entry to expire.
+type CachedToken struct {
+	Details *mdlAuth.ValidateTokenDetails `json:"details"`
+	Email   string                        `json:"email,omitempty"`
+}
+
+// TokenCacheTTL reads TOKEN_CACHE_TTL (in seconds). A value of 0 disables the cache.
+func TokenCacheTTL() time.Duration {
+	raw := utils_v1.GetEnv("TOKEN_CACHE_TTL")
+	if raw == "" {
+		return defaultTokenCacheTTL
+	}
+	secs, err := strconv.Atoi(raw)
+	if err != nil || secs < 0 {
+		return defaultTokenCacheTTL
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// store lazily builds the backend picked by TOKEN_CACHE_BACKEND (memory or redis).
+func store() cache.Store {
+	tokenStoreOnce.Do(func() {
+		tokenStore = cache.NewStore(utils_v1.GetEnv("TOKEN_CACHE_BACKEND"))
+	})
+	return tokenStore
+}
+
+// tokenKey never stores the raw bearer token, only its SHA-256 hash.
+func tokenKey(tokenString string) string {
+	sum := sha256.Sum256([]byte(tokenString))
+	return tokenKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+func userIndexKey(identity string) string {
+	return tokenUserIndexPrefix + strings.ToLower(strings.TrimSpace(identity))
+}
+
+// GetCachedToken returns the cached validation result for the token, if any.
+func GetCachedToken(ctx context.Context, tokenString string) (*CachedToken, bool) {
+	if TokenCacheTTL() == 0 {
+		return nil, false
+	}
+
+	raw, ok, err := store().Get(ctx, tokenKey(tokenString))
+	if err != nil {
+		log.Printf("Token cache read failed: %v", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	var entry CachedToken
+	if err := json.Unmarshal(raw, &entry); err != nil || entry.Details == nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// CacheToken stores a successful validation, never past the token's expiry,
+// and indexes it under the user's username and email so it can be evicted on
+// logout or deletion.
+func CacheToken(ctx context.Context, tokenString string, entry *CachedToken) {
+	ttl := TokenCacheTTL()
+	if ttl == 0 || entry == nil || entry.Details == nil {
+		return
+	}
+	// The index must outlive every entry in it, not just this one
+	indexTTL := ttl
+	if exp, ok := tokenTime(tokenString, "exp"); ok {
+		ttl = min(time.Until(exp), ttl)
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	key := tokenKey(tokenString)
+	if err := store().Set(ctx, key, raw, ttl); err != nil {
+		log.Printf("Token cache write failed: %v", err)
+		return
+	}
+
+	for _, identity := range []string{entry.Details.Username, entry.Email} {
+		if identity == "" {
+			continue
+		}
+		if err := store().AddToIndex(ctx, userIndexKey(identity), key, indexTTL); err != nil {
+			log.Printf("Token cache index write failed: %v", err)
+		}
+	}
+}
+
+// EvictToken drops a single token from the cache.
+func EvictToken(ctx context.Context, tokenString string) {
+	if err := store().Delete(ctx, tokenKey(tokenString)); err != nil {
+		log.Printf("Token cache eviction failed: %v", err)
+	}
+}
+
+// EvictUserTokens drops every cached token belonging to the given usernames or emails.
+func EvictUserTokens(ctx context.Context, identities ...string) {
+	for _, identity := range identities {
+		if identity == "" {
+			continue
+		}
+
+		index := userIndexKey(identity)
+		keys, err := store().IndexMembers(ctx, index)
+		if err != nil {
+			log.Printf("Token cache index read failed: %v", err)
+			continue
+		}
+
+		if err := store().Delete(ctx, append(keys, index)...); err != nil {
+			log.Printf("Token cache eviction failed: %v", err)
+		}
+	}
+}
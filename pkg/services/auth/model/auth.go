@@ -1,5 +1,7 @@
 package mdlAuth
 
+import "time"
+
 // ==========================
 // REGISTER STAFF
 // ==========================
@@ -270,15 +272,40 @@ type ValidateTokenDetails struct {
 }
 
 type UserWithPermissions struct {
-	ID          int64    `json:"id"`
-	UserID      int64    `json:"user_id"`
-	Username    string   `json:"username"`
-	StaffID     string   `json:"staff_id"`
-	FirstName   string   `json:"first_name"`
-	MiddleName  string   `json:"middle_name"`
-	LastName    string   `json:"last_name"`
-	Email       string   `json:"email"`
-	RoleID      *int     `json:"role_id"` // Pointer to handle NULL
-	RoleName    string   `json:"role_name"`
-	Permissions []string `json:"permissions"`
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Username    string     `json:"username"`
+	StaffID     string     `json:"staff_id"`
+	FirstName   string     `json:"first_name"`
+	MiddleName  string     `json:"middle_name"`
+	LastName    string     `json:"last_name"`
+	Email       string     `json:"email"`
+	RoleID      *int       `json:"role_id"`   // Pointer to handle NULL
+	RoleName    string     `json:"role_name"` // primary role, kept for older clients
+	Roles       []UserRole `json:"roles"`
+	IsSuperuser bool       `json:"is_superuser"`
+	Permissions []string   `json:"permissions"` // union over every role and institution
+	// Grants are the permissions with the institution they apply to; this is
+	// what permission checks use.
+	Grants []PermissionGrant `json:"grants"`
+}
+
+// UserRole is one role assignment. Assignments outside their
+// ValidFrom/ValidUntil window (nil is open-ended) are ignored.
+type UserRole struct {
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	IsSuperuser     bool       `json:"is_superuser"`
+	InstitutionCode *string    `json:"institution_code"` // nil applies to every institution
+	ValidFrom       *time.Time `json:"valid_from"`
+	ValidUntil      *time.Time `json:"valid_until"`
+}
+
+// PermissionGrant carries the window of the assignment it came through.
+type PermissionGrant struct {
+	Permission      string     `json:"permission"`
+	InstitutionCode *string    `json:"institution_code"` // nil applies to every institution
+	Condition       *string    `json:"condition"`        // nil is unconditional
+	ValidFrom       *time.Time `json:"valid_from"`
+	ValidUntil      *time.Time `json:"valid_until"`
 }
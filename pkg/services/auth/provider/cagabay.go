@@ -0,0 +1,210 @@
+package prvAuth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"go_template_v3/pkg/global/httpclient"
+
+	errAuth "go_template_v3/pkg/services/auth/error"
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+
+	utils_v1 "github.com/FDSAP-Git-Org/hephaestus/utils/v1"
+)
+
+const soteriaBasePath = "/soteria-go/api/public/v1/auth"
+
+// Cagabay talks to the soteria service configured by CAGABAY_BASE_URL.
+type Cagabay struct {
+	client *httpclient.Client
+}
+
+// NewCagabay sets up the shared client. Only validate-header is idempotent,
+// so it is the only endpoint that is retried; every endpoint shares the
+// circuit breaker so a Cagabay outage fails fast everywhere.
+func NewCagabay() *Cagabay {
+	client := httpclient.New("cagabay", httpclient.BreakerConfig{
+		FailureThreshold: envInt("CAGABAY_BREAKER_THRESHOLD", 5),
+		OpenFor:          time.Duration(envInt("CAGABAY_BREAKER_OPEN_SECONDS", 30)) * time.Second,
+	})
+	client.SetPolicy("validate-header", httpclient.IdempotentRetry)
+
+	return &Cagabay{client: client}
+}
+
+func (p *Cagabay) RegisterStaff(ctx context.Context, req *mdlAuth.StaffRegistrationApiRequest) (*mdlAuth.StaffRegistrationAPIResponse, error) {
+	var apiResp mdlAuth.StaffRegistrationAPIResponse
+	if err := p.call(ctx, "register", "POST", "/user-management/register-new-user/staff", "", req, 30, &apiResp); err != nil {
+		return nil, err
+	}
+
+	message := apiResp.Message
+	if apiResp.Data != nil {
+		message = apiResp.Data.Message
+	}
+	if err := checkRetCode(apiResp.RetCode, message, "203"); err != nil {
+		return nil, err
+	}
+	return &apiResp, nil
+}
+
+func (p *Cagabay) Login(ctx context.Context, req *mdlAuth.LoginRequest) (*mdlAuth.LoginAPIResponse, error) {
+	var apiResp mdlAuth.LoginAPIResponse
+	if err := p.call(ctx, "login", "POST", "/user-logs/login", "", req, 30, &apiResp); err != nil {
+		return nil, err
+	}
+
+	message := apiResp.Message
+	if apiResp.Data != nil {
+		message = apiResp.Data.Message
+	}
+	if err := checkRetCode(apiResp.RetCode, message, "201"); err != nil {
+		return nil, err
+	}
+	return &apiResp, nil
+}
+
+func (p *Cagabay) Logout(ctx context.Context, req *mdlAuth.LogoutRequest) (*mdlAuth.LogoutAPIResponse, error) {
+	var apiResp mdlAuth.LogoutAPIResponse
+	if err := p.call(ctx, "logout", "POST", "/user-logs/logout", "", req, 30, &apiResp); err != nil {
+		return nil, err
+	}
+
+	message := apiResp.Message
+	if apiResp.Data != nil {
+		message = apiResp.Data.Message
+	}
+	if err := checkRetCode(apiResp.RetCode, message, "202"); err != nil {
+		return nil, err
+	}
+	return &apiResp, nil
+}
+
+func (p *Cagabay) ChangePassword(ctx context.Context, req *mdlAuth.ChangePasswordRequest) (*mdlAuth.ChangePasswordAPIResponse, error) {
+	var apiResp mdlAuth.ChangePasswordAPIResponse
+	if err := p.call(ctx, "change-password", "POST", "/security-management/change-password", "", req, 30, &apiResp); err != nil {
+		return nil, err
+	}
+
+	message := apiResp.Message
+	if apiResp.Data != nil {
+		message = apiResp.Data.Message
+	}
+	if err := checkRetCode(apiResp.RetCode, message, "203"); err != nil {
+		return nil, err
+	}
+	return &apiResp, nil
+}
+
+func (p *Cagabay) DeleteUser(ctx context.Context, authHeader string, req *mdlAuth.DeleteUserRequest) (*mdlAuth.DeleteUserAPIResponse, error) {
+	var apiResp mdlAuth.DeleteUserAPIResponse
+	if err := p.call(ctx, "delete-user", "POST", "/user-management/delete-user", authHeader, req, 30, &apiResp); err != nil {
+		return nil, err
+	}
+
+	message := apiResp.Message
+	if apiResp.Data != nil {
+		message = apiResp.Data.Message
+	}
+	if err := checkRetCode(apiResp.RetCode, message, "210"); err != nil {
+		return nil, err
+	}
+	return &apiResp, nil
+}
+
+func (p *Cagabay) UpdateUser(ctx context.Context, authHeader, username string, req *mdlAuth.UpdateUserRequest) (*mdlAuth.UpdateUserAPIResponse, error) {
+	var apiResp mdlAuth.UpdateUserAPIResponse
+	if err := p.call(ctx, "update-user", "POST", "/user-management/update-user/staff/"+username, authHeader, req, 30, &apiResp); err != nil {
+		return nil, err
+	}
+
+	message := apiResp.Message
+	if apiResp.Data != nil {
+		message = apiResp.Data.Message
+	}
+	if err := checkRetCode(apiResp.RetCode, message, "203", "204"); err != nil {
+		return nil, err
+	}
+	return &apiResp, nil
+}
+
+func (p *Cagabay) ValidateToken(ctx context.Context, token string) (*mdlAuth.ValidateTokenDetails, error) {
+	var apiResp mdlAuth.ValidateTokenAPIResponse
+	err := p.call(ctx, "validate-header", "GET", "/security-management/validate-header", "Bearer "+token, nil, 10, &apiResp)
+	switch {
+	case errors.Is(err, errAuth.ErrProviderUnavailable):
+		return nil, fmt.Errorf("%w: %v", errAuth.ErrValidationUnavailable, err)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", errAuth.ErrValidationResponse, err)
+	}
+
+	switch apiResp.RetCode {
+	case "215":
+		// success → continue
+	case "109":
+		return nil, errAuth.ErrTokenTerminated
+	default:
+		return nil, errAuth.ErrTokenInvalid
+	}
+
+	if apiResp.Data == nil {
+		return nil, nil
+	}
+	return apiResp.Data.Details, nil
+}
+
+// call sends the request and decodes the JSON response into out.
+func (p *Cagabay) call(ctx context.Context, endpoint, method, path, authHeader string, payload interface{}, timeout int, out interface{}) error {
+	apiURL := utils_v1.GetEnv("CAGABAY_BASE_URL") + soteriaBasePath + path
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		"x-api-key":    utils_v1.GetEnv("CAGABAY_API_KEY"),
+	}
+	if authHeader != "" {
+		headers["Authorization"] = authHeader
+	}
+
+	var body []byte
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+
+	resp, err := p.client.Do(ctx, httpclient.Request{
+		Endpoint: endpoint,
+		Method:   method,
+		URL:      apiURL,
+		Body:     body,
+		Headers:  headers,
+		Timeout:  time.Duration(timeout) * time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errAuth.ErrProviderUnavailable, err)
+	}
+
+	respBytes, _ := json.Marshal(resp)
+	if err := json.Unmarshal(respBytes, out); err != nil {
+		return fmt.Errorf("%w: %v", errAuth.ErrProviderResponse, err)
+	}
+	return nil
+}
+
+// checkRetCode turns a non-success retCode into a ProviderRejection
+func checkRetCode(retCode, message string, success ...string) error {
+	if slices.Contains(success, retCode) {
+		return nil
+	}
+	return &errAuth.ProviderRejection{RetCode: retCode, Message: message}
+}
+
+func envInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(utils_v1.GetEnv(key)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,347 @@
+package prvAuth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	errAuth "go_template_v3/pkg/services/auth/error"
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+)
+
+// Fake is an in-memory IdentityProvider that mimics the Cagabay retCodes,
+// so the auth flows can run without the real soteria service.
+type Fake struct {
+	mu         sync.Mutex
+	nextID     int
+	users      map[string]*fakeUser // keyed by fakeKey
+	tokens     map[string]*fakeUser
+	terminated map[string]bool
+}
+
+type fakeUser struct {
+	profile  mdlAuth.RegisterStaffResult
+	password string
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		users:      map[string]*fakeUser{},
+		tokens:     map[string]*fakeUser{},
+		terminated: map[string]bool{},
+	}
+}
+
+// fakeKey identifies a user the way Cagabay does, by institution and staff
+// ID. Seeded users without a staff ID fall back to their username.
+func fakeKey(profile mdlAuth.RegisterStaffResult) string {
+	if profile.StaffID == "" {
+		return profile.InstitutionCode + "/@" + profile.Username
+	}
+	return profile.InstitutionCode + "/" + profile.StaffID
+}
+
+// AddUser seeds a user with a known password and returns the stored profile.
+func (f *Fake) AddUser(profile mdlAuth.RegisterStaffResult, password string) mdlAuth.RegisterStaffResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.addUser(profile, password)
+}
+
+// addUser is AddUser for callers already holding f.mu.
+func (f *Fake) addUser(profile mdlAuth.RegisterStaffResult, password string) mdlAuth.RegisterStaffResult {
+	f.nextID++
+	if profile.Username == "" {
+		profile.Username = profile.StaffID
+	}
+	profile.UserID = f.nextID
+	profile.Password = password
+	f.users[fakeKey(profile)] = &fakeUser{profile: profile, password: password}
+	return profile
+}
+
+// IssueToken logs the user in without a password check and returns a token.
+func (f *Fake) IssueToken(username string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	token := randomHex(16)
+	if u := f.find(username); u != nil {
+		f.tokens[token] = u
+	}
+	return token
+}
+
+func (f *Fake) RegisterStaff(_ context.Context, req *mdlAuth.StaffRegistrationApiRequest) (*mdlAuth.StaffRegistrationAPIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	profile := mdlAuth.RegisterStaffResult{
+		Username:        req.Username,
+		StaffID:         req.StaffID,
+		FirstName:       req.FirstName,
+		MiddleName:      req.MiddleName,
+		LastName:        req.LastName,
+		Email:           req.Email,
+		PhoneNo:         req.PhoneNo,
+		Birthdate:       req.Birthdate,
+		InstitutionCode: req.InstitutionCode,
+	}
+	if _, ok := f.users[fakeKey(profile)]; ok {
+		return nil, &errAuth.ProviderRejection{RetCode: "409", Message: "Staff is already registered"}
+	}
+	profile = f.addUser(profile, "T3mpP@ssw0rd-"+randomHex(4))
+
+	return &mdlAuth.StaffRegistrationAPIResponse{
+		RetCode: "203",
+		Message: "Successful",
+		Data: &mdlAuth.StaffRegistrationAPIData{
+			Message:   "User registered successfully",
+			IsSuccess: true,
+			Details:   &profile,
+		},
+	}, nil
+}
+
+func (f *Fake) Login(_ context.Context, req *mdlAuth.LoginRequest) (*mdlAuth.LoginAPIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	u := f.find(req.UserIdentity)
+	if u == nil || u.password != req.Password {
+		return nil, &errAuth.ProviderRejection{RetCode: "104", Message: "Invalid credentials"}
+	}
+
+	token := randomHex(16)
+	f.tokens[token] = u
+
+	return &mdlAuth.LoginAPIResponse{
+		RetCode: "201",
+		Message: "Successful",
+		Data: &mdlAuth.LoginAPIData{
+			Message:   "Login successful",
+			IsSuccess: true,
+			Details: &mdlAuth.LoginResult{
+				Username:        u.profile.Username,
+				StaffID:         u.profile.StaffID,
+				FirstName:       u.profile.FirstName,
+				MiddleName:      u.profile.MiddleName,
+				LastName:        u.profile.LastName,
+				Email:           u.profile.Email,
+				PhoneNo:         u.profile.PhoneNo,
+				LastLogin:       time.Now().Format("2006-01-02 15:04:05"),
+				IsLoggedIn:      true,
+				InstitutionID:   u.profile.InstitutionID,
+				InstitutionCode: u.profile.InstitutionCode,
+				InstitutionName: u.profile.InstitutionName,
+				Token:           token,
+			},
+		},
+	}, nil
+}
+
+func (f *Fake) Logout(_ context.Context, req *mdlAuth.LogoutRequest) (*mdlAuth.LogoutAPIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	u := f.find(req.UserIdentity)
+	if u == nil {
+		return nil, &errAuth.ProviderRejection{RetCode: "404", Message: "User not found"}
+	}
+
+	for token, holder := range f.tokens {
+		if holder == u {
+			delete(f.tokens, token)
+			f.terminated[token] = true
+		}
+	}
+
+	return &mdlAuth.LogoutAPIResponse{
+		RetCode: "202",
+		Message: "Successful",
+		Data: &mdlAuth.LogoutAPIData{
+			Message:   "Logout successful",
+			IsSuccess: true,
+			Details: &mdlAuth.LogoutResult{
+				Username:        u.profile.Username,
+				StaffID:         u.profile.StaffID,
+				FirstName:       u.profile.FirstName,
+				MiddleName:      u.profile.MiddleName,
+				LastName:        u.profile.LastName,
+				Email:           u.profile.Email,
+				PhoneNo:         u.profile.PhoneNo,
+				InstitutionID:   u.profile.InstitutionID,
+				InstitutionCode: u.profile.InstitutionCode,
+				InstitutionName: u.profile.InstitutionName,
+			},
+		},
+	}, nil
+}
+
+func (f *Fake) ChangePassword(_ context.Context, req *mdlAuth.ChangePasswordRequest) (*mdlAuth.ChangePasswordAPIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	u := f.find(req.Username)
+	if u == nil {
+		return nil, &errAuth.ProviderRejection{RetCode: "404", Message: "User not found"}
+	}
+	u.password = req.NewPassword
+
+	return &mdlAuth.ChangePasswordAPIResponse{
+		RetCode: "203",
+		Message: "Successful",
+		Data: &mdlAuth.ChangePasswordAPIResponseData{
+			Message:   "Password changed successfully",
+			IsSuccess: true,
+			Details: &mdlAuth.ChangePasswordResult{
+				StaffID:         u.profile.StaffID,
+				FirstName:       u.profile.FirstName,
+				MiddleName:      u.profile.MiddleName,
+				LastName:        u.profile.LastName,
+				Email:           u.profile.Email,
+				PhoneNo:         u.profile.PhoneNo,
+				Birthdate:       u.profile.Birthdate,
+				InstitutionName: u.profile.InstitutionName,
+				Password:        u.password,
+			},
+		},
+	}, nil
+}
+
+func (f *Fake) DeleteUser(_ context.Context, authHeader string, req *mdlAuth.DeleteUserRequest) (*mdlAuth.DeleteUserAPIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.tokens[strings.TrimPrefix(authHeader, "Bearer ")]; !ok {
+		return nil, &errAuth.ProviderRejection{RetCode: "401", Message: "Unauthorized"}
+	}
+
+	u := f.find(req.UserIdentity)
+	if u == nil {
+		return nil, &errAuth.ProviderRejection{RetCode: "404", Message: "User not found"}
+	}
+	delete(f.users, fakeKey(u.profile))
+	for token, holder := range f.tokens {
+		if holder == u {
+			delete(f.tokens, token)
+		}
+	}
+
+	return &mdlAuth.DeleteUserAPIResponse{
+		RetCode: "210",
+		Message: "Successful",
+		Data: &mdlAuth.DeleteUserAPIData{
+			Message:   "User deleted successfully",
+			IsSuccess: true,
+		},
+	}, nil
+}
+
+func (f *Fake) UpdateUser(_ context.Context, authHeader, username string, req *mdlAuth.UpdateUserRequest) (*mdlAuth.UpdateUserAPIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.tokens[strings.TrimPrefix(authHeader, "Bearer ")]; !ok {
+		return nil, &errAuth.ProviderRejection{RetCode: "401", Message: "Unauthorized"}
+	}
+
+	u := f.find(username)
+	if u == nil {
+		return nil, &errAuth.ProviderRejection{RetCode: "404", Message: "User not found"}
+	}
+
+	updated := u.profile
+	p := &updated
+	setIfNotEmpty(&p.Username, req.Username)
+	setIfNotEmpty(&p.StaffID, req.StaffID)
+	setIfNotEmpty(&p.FirstName, req.FirstName)
+	setIfNotEmpty(&p.MiddleName, req.MiddleName)
+	setIfNotEmpty(&p.LastName, req.LastName)
+	setIfNotEmpty(&p.Email, req.Email)
+	setIfNotEmpty(&p.PhoneNo, req.PhoneNo)
+	setIfNotEmpty(&p.Birthdate, req.Birthdate)
+	setIfNotEmpty(&p.InstitutionCode, req.InstitutionCode)
+
+	// Moving to another staff ID or institution must not overwrite a user
+	oldKey, newKey := fakeKey(u.profile), fakeKey(updated)
+	if other, ok := f.users[newKey]; ok && other != u {
+		return nil, &errAuth.ProviderRejection{RetCode: "409", Message: "Staff is already registered"}
+	}
+	delete(f.users, oldKey)
+	u.profile = updated
+	f.users[newKey] = u
+
+	return &mdlAuth.UpdateUserAPIResponse{
+		RetCode: "204",
+		Message: "Successful",
+		Data: &mdlAuth.UpdateUserData{
+			Message:   "User updated successfully",
+			IsSuccess: true,
+			Details: &mdlAuth.UpdateUserResult{
+				Username:        p.Username,
+				StaffID:         p.StaffID,
+				FirstName:       p.FirstName,
+				MiddleName:      p.MiddleName,
+				LastName:        p.LastName,
+				Email:           p.Email,
+				PhoneNo:         p.PhoneNo,
+				Birthdate:       p.Birthdate,
+				InstitutionID:   p.InstitutionID,
+				InstitutionCode: p.InstitutionCode,
+				InstitutionName: p.InstitutionName,
+			},
+		},
+	}, nil
+}
+
+func (f *Fake) ValidateToken(_ context.Context, token string) (*mdlAuth.ValidateTokenDetails, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.terminated[token] {
+		return nil, errAuth.ErrTokenTerminated
+	}
+
+	u, ok := f.tokens[token]
+	if !ok {
+		return nil, errAuth.ErrTokenInvalid
+	}
+
+	return &mdlAuth.ValidateTokenDetails{
+		Username:  u.profile.Username,
+		InstiCode: u.profile.InstitutionCode,
+		InstiName: u.profile.InstitutionName,
+	}, nil
+}
+
+// find looks a user up by username or email; callers hold f.mu.
+func (f *Fake) find(identity string) *fakeUser {
+	for _, u := range f.users {
+		if u.profile.Username == identity {
+			return u
+		}
+	}
+	for _, u := range f.users {
+		if u.profile.Email != "" && strings.EqualFold(u.profile.Email, identity) {
+			return u
+		}
+	}
+	return nil
+}
+
+func setIfNotEmpty(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,223 @@
+package prvAuth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	errAuth "go_template_v3/pkg/services/auth/error"
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+)
+
+func retCode(err error) string {
+	var rejection *errAuth.ProviderRejection
+	if errors.As(err, &rejection) {
+		return rejection.RetCode
+	}
+	return ""
+}
+
+func TestFakeRegisteredStaffCanLogIn(t *testing.T) {
+	f := NewFake()
+	ctx := context.Background()
+
+	resp, err := f.RegisterStaff(ctx, &mdlAuth.StaffRegistrationApiRequest{StaffID: "S-002", InstitutionCode: "0001"})
+	if err != nil {
+		t.Fatalf("RegisterStaff: %v", err)
+	}
+	details := resp.Data.Details
+
+	// Staff registered without a username log in with their staff ID and
+	// the temporary password Cagabay would have mailed them
+	if details.Username != "S-002" {
+		t.Fatalf("username = %q, want the staff ID", details.Username)
+	}
+	if _, err := f.Login(ctx, &mdlAuth.LoginRequest{UserIdentity: "S-002", Password: details.Password}); err != nil {
+		t.Fatalf("Login with the temporary password: %v", err)
+	}
+
+	_, err = f.RegisterStaff(ctx, &mdlAuth.StaffRegistrationApiRequest{StaffID: "S-002", InstitutionCode: "0001"})
+	if retCode(err) != "409" {
+		t.Fatalf("second registration: err = %v, want retCode 409", err)
+	}
+}
+
+func TestFakeRegisterStaffPerInstitution(t *testing.T) {
+	f := NewFake()
+	ctx := context.Background()
+
+	// Both default to the username S-002; neither may replace the other
+	for _, institution := range []string{"0001", "0002"} {
+		if _, err := f.RegisterStaff(ctx, &mdlAuth.StaffRegistrationApiRequest{StaffID: "S-002", InstitutionCode: institution}); err != nil {
+			t.Fatalf("RegisterStaff in %s: %v", institution, err)
+		}
+	}
+
+	for _, institution := range []string{"0001", "0002"} {
+		u, ok := f.users[fakeKey(mdlAuth.RegisterStaffResult{StaffID: "S-002", InstitutionCode: institution})]
+		if !ok || u.profile.InstitutionCode != institution {
+			t.Fatalf("staff S-002 of %s is missing", institution)
+		}
+	}
+}
+
+func TestFakeConcurrentRegistration(t *testing.T) {
+	f := NewFake()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var registered atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := f.RegisterStaff(ctx, &mdlAuth.StaffRegistrationApiRequest{StaffID: "S-003", InstitutionCode: "0001"}); err == nil {
+				registered.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := registered.Load(); got != 1 || len(f.users) != 1 {
+		t.Fatalf("%d registrations succeeded for %d users, want exactly one", got, len(f.users))
+	}
+}
+
+func TestFakeLogin(t *testing.T) {
+	f := NewFake()
+	f.AddUser(mdlAuth.RegisterStaffResult{Username: "jdoe", Email: "jdoe@example.com", InstitutionCode: "0001"}, "secret")
+	ctx := context.Background()
+
+	tests := []struct {
+		identity, password string
+		wantErr            string
+	}{
+		{"jdoe", "secret", ""},
+		{"JDoe@Example.com", "secret", ""},
+		// Usernames are exact; only emails ignore case
+		{"JDOE", "secret", "104"},
+		{"jdoe", "Secret", "104"},
+		{"ghost", "secret", "104"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.identity+"/"+tt.password, func(t *testing.T) {
+			_, err := f.Login(ctx, &mdlAuth.LoginRequest{UserIdentity: tt.identity, Password: tt.password})
+			if got := retCode(err); got != tt.wantErr || (tt.wantErr == "" && err != nil) {
+				t.Fatalf("err = %v, want retCode %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFakeLogoutTerminatesEveryToken(t *testing.T) {
+	f := NewFake()
+	f.AddUser(mdlAuth.RegisterStaffResult{Username: "jdoe"}, "secret")
+	f.AddUser(mdlAuth.RegisterStaffResult{Username: "asmith"}, "secret")
+	ctx := context.Background()
+
+	first, second, other := f.IssueToken("jdoe"), f.IssueToken("jdoe"), f.IssueToken("asmith")
+	if _, err := f.Logout(ctx, &mdlAuth.LogoutRequest{UserIdentity: "jdoe"}); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	for _, token := range []string{first, second} {
+		if _, err := f.ValidateToken(ctx, token); !errors.Is(err, errAuth.ErrTokenTerminated) {
+			t.Fatalf("ValidateToken after logout: err = %v, want ErrTokenTerminated", err)
+		}
+	}
+	if _, err := f.ValidateToken(ctx, other); err != nil {
+		t.Fatalf("another user's token was terminated: %v", err)
+	}
+	if _, err := f.Logout(ctx, &mdlAuth.LogoutRequest{UserIdentity: "ghost"}); retCode(err) != "404" {
+		t.Fatalf("Logout of an unknown user: err = %v, want retCode 404", err)
+	}
+}
+
+func TestFakeDeleteUser(t *testing.T) {
+	f := NewFake()
+	f.AddUser(mdlAuth.RegisterStaffResult{Username: "admin"}, "secret")
+	f.AddUser(mdlAuth.RegisterStaffResult{Username: "jdoe", Email: "jdoe@example.com"}, "secret")
+	ctx := context.Background()
+
+	admin, victim := f.IssueToken("admin"), f.IssueToken("jdoe")
+
+	if _, err := f.DeleteUser(ctx, "Bearer bogus", &mdlAuth.DeleteUserRequest{UserIdentity: "jdoe"}); retCode(err) != "401" {
+		t.Fatalf("unknown bearer: err = %v, want retCode 401", err)
+	}
+	if _, err := f.DeleteUser(ctx, "Bearer "+admin, &mdlAuth.DeleteUserRequest{UserIdentity: "JDOE@example.com"}); err != nil {
+		t.Fatalf("DeleteUser by email: %v", err)
+	}
+
+	// The deleted user's outstanding tokens stop validating
+	if _, err := f.ValidateToken(ctx, victim); !errors.Is(err, errAuth.ErrTokenInvalid) {
+		t.Fatalf("ValidateToken after deletion: err = %v, want ErrTokenInvalid", err)
+	}
+	if _, err := f.DeleteUser(ctx, "Bearer "+admin, &mdlAuth.DeleteUserRequest{UserIdentity: "jdoe"}); retCode(err) != "404" {
+		t.Fatalf("second deletion: err = %v, want retCode 404", err)
+	}
+}
+
+func TestFakeUpdateUserRename(t *testing.T) {
+	f := NewFake()
+	f.AddUser(mdlAuth.RegisterStaffResult{Username: "jdoe", StaffID: "S-001"}, "secret")
+	ctx := context.Background()
+	bearer := "Bearer " + f.IssueToken("jdoe")
+
+	resp, err := f.UpdateUser(ctx, bearer, "jdoe", &mdlAuth.UpdateUserRequest{Username: "jdoe2", LastName: "Doe"})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	// Empty fields are left alone
+	if d := resp.Data.Details; d.Username != "jdoe2" || d.LastName != "Doe" || d.StaffID != "S-001" {
+		t.Fatalf("unexpected details %+v", d)
+	}
+
+	if _, err := f.Login(ctx, &mdlAuth.LoginRequest{UserIdentity: "jdoe", Password: "secret"}); retCode(err) != "104" {
+		t.Fatalf("login under the old username: err = %v, want retCode 104", err)
+	}
+	if _, err := f.Login(ctx, &mdlAuth.LoginRequest{UserIdentity: "jdoe2", Password: "secret"}); err != nil {
+		t.Fatalf("login under the new username: %v", err)
+	}
+}
+
+func TestFakeChangePassword(t *testing.T) {
+	f := NewFake()
+	f.AddUser(mdlAuth.RegisterStaffResult{Username: "jdoe"}, "secret")
+	ctx := context.Background()
+
+	if _, err := f.ChangePassword(ctx, &mdlAuth.ChangePasswordRequest{Username: "ghost", NewPassword: "x"}); retCode(err) != "404" {
+		t.Fatalf("unknown user: err = %v, want retCode 404", err)
+	}
+	if _, err := f.ChangePassword(ctx, &mdlAuth.ChangePasswordRequest{Username: "jdoe", NewPassword: "changed"}); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if _, err := f.Login(ctx, &mdlAuth.LoginRequest{UserIdentity: "jdoe", Password: "secret"}); retCode(err) != "104" {
+		t.Fatalf("old password: err = %v, want retCode 104", err)
+	}
+}
+
+func TestCurrentIsBuiltOnFirstUse(t *testing.T) {
+	mu.Lock()
+	saved := current
+	current = nil
+	mu.Unlock()
+	t.Cleanup(func() { Use(saved) })
+
+	// Nothing is built at package init, so main can load the env first
+	first, ok := Current().(*Cagabay)
+	if !ok {
+		t.Fatalf("Current() = %T, want *Cagabay", Current())
+	}
+	if Current() != first {
+		t.Fatalf("Current() built a second provider")
+	}
+
+	fake := NewFake()
+	Use(fake)
+	if Current() != fake {
+		t.Fatalf("Current() did not return the provider passed to Use")
+	}
+}
@@ -0,0 +1,52 @@
+package prvAuth
+
+import (
+	"context"
+	"sync"
+
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+)
+
+// IdentityProvider covers every call this service makes to the external
+// identity service. Implementations return the typed API response on success
+// and one of the errAuth errors (or *errAuth.ProviderRejection) otherwise.
+type IdentityProvider interface {
+	RegisterStaff(ctx context.Context, req *mdlAuth.StaffRegistrationApiRequest) (*mdlAuth.StaffRegistrationAPIResponse, error)
+	Login(ctx context.Context, req *mdlAuth.LoginRequest) (*mdlAuth.LoginAPIResponse, error)
+	Logout(ctx context.Context, req *mdlAuth.LogoutRequest) (*mdlAuth.LogoutAPIResponse, error)
+	ChangePassword(ctx context.Context, req *mdlAuth.ChangePasswordRequest) (*mdlAuth.ChangePasswordAPIResponse, error)
+	DeleteUser(ctx context.Context, authHeader string, req *mdlAuth.DeleteUserRequest) (*mdlAuth.DeleteUserAPIResponse, error)
+	UpdateUser(ctx context.Context, authHeader, username string, req *mdlAuth.UpdateUserRequest) (*mdlAuth.UpdateUserAPIResponse, error)
+	ValidateToken(ctx context.Context, token string) (*mdlAuth.ValidateTokenDetails, error)
+}
+
+var (
+	mu      sync.RWMutex
+	current IdentityProvider
+)
+
+// Current returns the provider used by the auth controllers and middleware.
+// The Cagabay client is built on first use so it reads the breaker settings
+// after main has loaded the env file.
+func Current() IdentityProvider {
+	mu.RLock()
+	p := current
+	mu.RUnlock()
+	if p != nil {
+		return p
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		current = NewCagabay()
+	}
+	return current
+}
+
+// Use swaps the active provider, e.g. for the in-memory fake.
+func Use(p IdentityProvider) {
+	mu.Lock()
+	current = p
+	mu.Unlock()
+}
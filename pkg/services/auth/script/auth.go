@@ -79,7 +79,7 @@ func ChangeTempPassword(data *mdlAuth.ChangePasswordResult) error {
 	).Error
 }
 
-func DeleteUserByIdentity(userIdentity string) error {
+func DeleteUserByIdentity(userIdentity string) ([]string, error) {
 	query := `
 		UPDATE users
 		SET deleted_at = NOW(),
@@ -87,12 +87,15 @@ func DeleteUserByIdentity(userIdentity string) error {
 		    updated_at = NOW()
 		WHERE email = $1
 		   OR username = $1
+		RETURNING username
 	`
 
-	return config.DBConnList[0].Exec(
+	var usernames []string
+	err := config.DBConnList[0].Raw(
 		query,
 		userIdentity,
-	).Error
+	).Scan(&usernames).Error
+	return usernames, err
 }
 
 func UpdateUser(data *mdlAuth.UpdateUserResult) error {
@@ -303,9 +306,5 @@ func GetUserByUsername(username string) (*mdlAuth.UserWithPermissions, error) {
 		return nil, err
 	}
 
-	if user.RoleName == "super_admin" {
-		user.Permissions = []string{"Full Access"}
-	}
-
 	return &user, nil
 }
@@ -1,6 +1,7 @@
 package svcHealthcheck
 
 import (
+	"go_template_v3/pkg/global/httpclient"
 	"net/http"
 
 	"github.com/FDSAP-Git-Org/hephaestus/apilogs"
@@ -14,3 +15,11 @@ func HealthCheck(c fiber.Ctx) error {
 	apilogs.ApplicationLogger(c.Path(), "System", "healthCheck", "Check Health", respcode.SUC_CODE_200_MSG, nil, response)
 	return response
 }
+
+// OutboundMetrics reports per-endpoint counters and breaker state of every
+// outbound HTTP client.
+func OutboundMetrics(c fiber.Ctx) error {
+	response := v1.JSONResponseWithData(c, respcode.SUC_CODE_200, respcode.SUC_CODE_200_MSG, httpclient.Snapshot(), http.StatusOK)
+	apilogs.ApplicationLogger(c.Path(), "System", "outboundMetrics", "Outbound Metrics", respcode.SUC_CODE_200_MSG, nil, response)
+	return response
+}
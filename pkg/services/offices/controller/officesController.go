@@ -3,6 +3,7 @@ package officesController
 import (
 	"errors"
 	"fmt"
+	"go_template_v3/pkg/global/pagination"
 	officesError "go_template_v3/pkg/services/offices/error"
 	officesModel "go_template_v3/pkg/services/offices/model"
 	officesScript "go_template_v3/pkg/services/offices/script"
@@ -30,8 +31,15 @@ func GetBranches(c fiber.Ctx) error {
 		)
 	}
 
+	p, err := pagination.Parse(c, officesScript.BranchSorting)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_400, err.Error(), nil, http.StatusBadRequest,
+		)
+	}
+
 	// Call service
-	branches, err := officesScript.GetBranches(instiCode)
+	branches, total, err := officesScript.GetBranches(instiCode, p)
 	if err != nil {
 		// Handle specific error cases
 		switch {
@@ -42,9 +50,7 @@ func GetBranches(c fiber.Ctx) error {
 
 		case errors.Is(err, officesError.ErrNoBranchesFound):
 			// Return empty array instead of error if no branches found
-			response := officesModel.GetBranchesResponse{
-				Branches: []officesModel.Branch{},
-			}
+			response := pagination.NewPage([]officesModel.Branch{}, p, 0)
 			return v1.JSONResponseWithData(
 				c, respcode.SUC_CODE_200, "No branches found.", response, http.StatusOK,
 			)
@@ -65,9 +71,7 @@ func GetBranches(c fiber.Ctx) error {
 	}
 
 	// Prepare response
-	response := officesModel.GetBranchesResponse{
-		Branches: branches,
-	}
+	response := pagination.NewPage(branches, p, total)
 
 	// Success
 	return v1.JSONResponseWithData(
@@ -92,8 +96,15 @@ func GetUnits(c fiber.Ctx) error {
 		)
 	}
 
+	p, err := pagination.Parse(c, officesScript.UnitSorting)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_400, err.Error(), nil, http.StatusBadRequest,
+		)
+	}
+
 	// Call service
-	units, err := officesScript.GetUnits(branchCode)
+	units, total, err := officesScript.GetUnits(branchCode, p)
 	if err != nil {
 		// Handle specific error cases
 		switch {
@@ -109,9 +120,7 @@ func GetUnits(c fiber.Ctx) error {
 
 		case errors.Is(err, officesError.ErrNoUnitsFound):
 			// Return empty array instead of error
-			response := officesModel.GetUnitsResponse{
-				Units: []officesModel.Unit{},
-			}
+			response := pagination.NewPage([]officesModel.Unit{}, p, 0)
 			return v1.JSONResponseWithData(
 				c, respcode.SUC_CODE_200, "No units found.", response, http.StatusOK,
 			)
@@ -127,9 +136,7 @@ func GetUnits(c fiber.Ctx) error {
 	}
 
 	// Prepare response
-	response := officesModel.GetUnitsResponse{
-		Units: units,
-	}
+	response := pagination.NewPage(units, p, total)
 
 	// Success
 	return v1.JSONResponseWithData(
@@ -8,10 +8,6 @@ type Branch struct {
 	BranchName string `json:"branch_name"`
 }
 
-type GetBranchesResponse struct {
-	Branches []Branch `json:"branches"`
-}
-
 type GetBranchesRequest struct {
 	InstiCode string `json:"insti_code"`
 }
@@ -25,10 +21,6 @@ type Unit struct {
 	UnitName string `json:"unit_name"`
 }
 
-type GetUnitsResponse struct {
-	Units []Unit `json:"units"`
-}
-
 type GetUnitsRequest struct {
 	BranchCode string `json:"branch_code"`
 }
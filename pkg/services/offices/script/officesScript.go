@@ -4,17 +4,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"go_template_v3/pkg/config"
+	"go_template_v3/pkg/global/pagination"
 	officesError "go_template_v3/pkg/services/offices/error"
 	officesModel "go_template_v3/pkg/services/offices/model"
 	"strings"
 )
 
-func GetBranches(instiCode string) ([]officesModel.Branch, error) {
+// BranchSorting lists the sort fields of GetBranches.
+var BranchSorting = pagination.Sorting{
+	Columns: map[string]string{"branch_code": "branch_code", "branch_name": "branch_name"},
+	Default: "branch_code",
+}
+
+// GetBranches returns one page of the institution's branches; q searches
+// branch codes and names.
+func GetBranches(instiCode string, p pagination.Params) ([]officesModel.Branch, int64, error) {
 	db := &config.DBConnList[0]
 
 	// Validate input
 	if instiCode == "" {
-		return nil, officesError.ErrInstiCodeRequired
+		return nil, 0, officesError.ErrInstiCodeRequired
 	}
 
 	// Call the PostgreSQL function
@@ -28,14 +37,14 @@ func GetBranches(instiCode string) ([]officesModel.Branch, error) {
 	if err != nil {
 		// Log the error for debugging
 		fmt.Printf("Get branches database error: %v\n", err)
-		return nil, fmt.Errorf("failed to fetch branches: %w", err)
+		return nil, 0, fmt.Errorf("failed to fetch branches: %w", err)
 	}
 
 	// Parse the JSON text into slice of Branch
 	var branches []officesModel.Branch
 	err = json.Unmarshal([]byte(jsonText), &branches)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse branches response: %w", err)
+		return nil, 0, fmt.Errorf("failed to parse branches response: %w", err)
 	}
 
 	// If no branches found, return empty slice (not error)
@@ -44,15 +53,37 @@ func GetBranches(instiCode string) ([]officesModel.Branch, error) {
 		branches = []officesModel.Branch{}
 	}
 
-	return branches, nil
+	page, total := pagination.Apply(branches, p,
+		func(branch officesModel.Branch, search string) bool {
+			return strings.Contains(strings.ToLower(branch.BranchCode), search) ||
+				strings.Contains(strings.ToLower(branch.BranchName), search)
+		},
+		func(a, b officesModel.Branch, field string) int {
+			if field == "branch_name" {
+				if c := strings.Compare(a.BranchName, b.BranchName); c != 0 {
+					return c
+				}
+			}
+			return strings.Compare(a.BranchCode, b.BranchCode)
+		})
+
+	return page, total, nil
+}
+
+// UnitSorting lists the sort fields of GetUnits.
+var UnitSorting = pagination.Sorting{
+	Columns: map[string]string{"unit_code": "unit_code", "unit_name": "unit_name"},
+	Default: "unit_code",
 }
 
-func GetUnits(branchCode string) ([]officesModel.Unit, error) {
+// GetUnits returns one page of the branch's units; q searches unit codes and
+// names.
+func GetUnits(branchCode string, p pagination.Params) ([]officesModel.Unit, int64, error) {
 	db := &config.DBConnList[0]
 
 	// Validate input
 	if branchCode == "" {
-		return nil, officesError.ErrBranchCodeRequired
+		return nil, 0, officesError.ErrBranchCodeRequired
 	}
 
 	// Call the PostgreSQL function
@@ -69,19 +100,19 @@ func GetUnits(branchCode string) ([]officesModel.Unit, error) {
 
 		// Handle potential foreign key or constraint errors
 		if strings.Contains(errMsg, "violates foreign key constraint") {
-			return nil, fmt.Errorf("invalid branch code: %w", officesError.ErrInvalidInput)
+			return nil, 0, fmt.Errorf("invalid branch code: %w", officesError.ErrInvalidInput)
 		}
 
 		// Log the error for debugging
 		fmt.Printf("Get units database error: %v\n", err)
-		return nil, fmt.Errorf("failed to fetch units: %w", err)
+		return nil, 0, fmt.Errorf("failed to fetch units: %w", err)
 	}
 
 	// Parse the JSON text into slice of Unit
 	var units []officesModel.Unit
 	err = json.Unmarshal([]byte(jsonText), &units)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse units response: %w", err)
+		return nil, 0, fmt.Errorf("failed to parse units response: %w", err)
 	}
 
 	// If no units found, return empty slice (not error)
@@ -89,5 +120,19 @@ func GetUnits(branchCode string) ([]officesModel.Unit, error) {
 		units = []officesModel.Unit{}
 	}
 
-	return units, nil
+	page, total := pagination.Apply(units, p,
+		func(unit officesModel.Unit, search string) bool {
+			return strings.Contains(strings.ToLower(unit.UnitCode), search) ||
+				strings.Contains(strings.ToLower(unit.UnitName), search)
+		},
+		func(a, b officesModel.Unit, field string) int {
+			if field == "unit_name" {
+				if c := strings.Compare(a.UnitName, b.UnitName); c != 0 {
+					return c
+				}
+			}
+			return strings.Compare(a.UnitCode, b.UnitCode)
+		})
+
+	return page, total, nil
 }
@@ -1,9 +1,17 @@
 package ctrRbac
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"log"
+	"time"
+
+	"go_template_v3/pkg/global/pagination"
+	"go_template_v3/pkg/middleware"
+	mdlAuth "go_template_v3/pkg/services/auth/model"
 	errRbac "go_template_v3/pkg/services/rbac/error"
+	hlpRbac "go_template_v3/pkg/services/rbac/helper"
 	mdlRbac "go_template_v3/pkg/services/rbac/model"
 	scpRbac "go_template_v3/pkg/services/rbac/script"
 
@@ -15,7 +23,9 @@ import (
 
 	v1 "github.com/FDSAP-Git-Org/hephaestus/helper/v1"
 	"github.com/FDSAP-Git-Org/hephaestus/respcode"
+	utils_v1 "github.com/FDSAP-Git-Org/hephaestus/utils/v1"
 	"github.com/gofiber/fiber/v3"
+	"gopkg.in/yaml.v3"
 )
 
 // ----------------------------
@@ -47,9 +57,22 @@ func AssignRolePermission(c fiber.Ctx) error {
 		)
 	}
 
+	condition, err := normalizeCondition(req.Condition)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_400, "Invalid permission condition.", err, http.StatusBadRequest,
+		)
+	}
+
 	// Call service
-	assignPermToRole, err := scpRbac.AssignRolePermission(roleID, req.ActionName, req.ResourceName)
+	assignPermToRole, err := scpRbac.AssignRolePermission(roleID, req.ActionName, req.ResourceName, condition)
 	if err != nil {
+		if errors.Is(err, errRbac.ErrSoDViolation) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Separation-of-duties rule violated.", err, http.StatusConflict)
+		}
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(c, respcode.ERR_CODE_404, "Role, action or resource not found or archived.", http.StatusNotFound)
+		}
 		return v1.JSONResponseWithError(c, respcode.ERR_CODE_500, "Failed to assign permission to role.", err, http.StatusInternalServerError)
 	}
 
@@ -57,13 +80,24 @@ func AssignRolePermission(c fiber.Ctx) error {
 		return v1.JSONResponse(c, respcode.ERR_CODE_400, assignPermToRole.Message, http.StatusBadRequest)
 	}
 
+	hlpRbac.BumpVersion(c.Context())
+
 	return v1.JSONResponse(
 		c, respcode.SUC_CODE_200, assignPermToRole.Message, http.StatusOK,
 	)
 }
 func GetAllRolesPermissions(c fiber.Ctx) error {
+	p, err := pagination.Parse(c, scpRbac.RolePermissionsSorting)
+	if err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
+	}
 
-	allPerms, err := scpRbac.GetAllRolePermissionsGrouped()
+	archived, err := includeArchived(c)
+	if err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
+	}
+
+	allPerms, total, err := scpRbac.GetAllRolePermissionsGrouped(p, archived)
 	if err != nil {
 		return v1.JSONResponseWithError(
 			c, respcode.ERR_CODE_500, "Failed to fetch roles and permissions.", err, http.StatusInternalServerError,
@@ -71,7 +105,7 @@ func GetAllRolesPermissions(c fiber.Ctx) error {
 	}
 
 	return v1.JSONResponseWithData(
-		c, respcode.SUC_CODE_200, "Successfully fetched all role permissions!", allPerms, http.StatusOK,
+		c, respcode.SUC_CODE_200, "Successfully fetched all role permissions!", pagination.NewPage(allPerms, p, total), http.StatusOK,
 	)
 }
 
@@ -146,6 +180,8 @@ func RemoveRolePermission(c fiber.Ctx) error {
 	if !removePermResp.Success {
 		return v1.JSONResponse(c, respcode.ERR_CODE_400, removePermResp.Message, http.StatusBadRequest)
 	}
+	hlpRbac.BumpVersion(c.Context())
+
 	cleanMessage := strings.ReplaceAll(removePermResp.Message, `\"`, "")
 
 	return v1.JSONResponse(
@@ -153,106 +189,237 @@ func RemoveRolePermission(c fiber.Ctx) error {
 	)
 }
 
+// ReplaceRolePermissions sets the full list of a role's direct permissions
+// in one transaction and returns what was added, removed or updated.
+func ReplaceRolePermissions(c fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest,
+		)
+	}
+
+	var req mdlRbac.ReplaceRolePermissionsReq
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest,
+		)
+	}
+
+	// Validate and de-duplicate before touching the database
+	seen := map[string]*string{}
+	desired := make([]mdlRbac.PermissionToRoleReq, 0, len(req.Permissions))
+	for _, perm := range req.Permissions {
+		perm.ActionName = strings.TrimSpace(perm.ActionName)
+		perm.ResourceName = strings.TrimSpace(perm.ResourceName)
+		if perm.ActionName == "" || perm.ResourceName == "" {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_400, "Every permission needs an action and a resource.", http.StatusBadRequest,
+			)
+		}
+
+		condition, err := normalizeCondition(perm.Condition)
+		if err != nil {
+			return v1.JSONResponseWithError(
+				c, respcode.ERR_CODE_400, "Invalid permission condition.", err, http.StatusBadRequest,
+			)
+		}
+		perm.Condition = condition
+
+		key := perm.ActionName + ":" + perm.ResourceName
+		if previous, ok := seen[key]; ok {
+			if (previous == nil) != (condition == nil) || (previous != nil && *previous != *condition) {
+				return v1.JSONResponse(
+					c, respcode.ERR_CODE_400, "Permission "+key+" is listed with different conditions.", http.StatusBadRequest,
+				)
+			}
+			continue
+		}
+		seen[key] = condition
+		desired = append(desired, perm)
+	}
+
+	diff, err := scpRbac.ReplaceRolePermissions(roleID, desired)
+	if err != nil {
+		if errors.Is(err, errRbac.ErrSoDViolation) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Separation-of-duties rule violated.", err, http.StatusConflict)
+		}
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponseWithError(
+				c, respcode.ERR_CODE_404, "Role, action or resource not found.", err, http.StatusNotFound,
+			)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to replace role permissions.", err, http.StatusInternalServerError,
+		)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponseWithData(
+		c, respcode.SUC_CODE_200, "Role permissions replaced successfully!", diff, http.StatusOK,
+	)
+}
+
+// SetRolePermissionCondition changes the condition of a permission already
+// assigned to a role. An empty or null condition makes it unconditional.
+func SetRolePermissionCondition(c fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest,
+		)
+	}
+
+	var req mdlRbac.PermissionToRoleReq
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest,
+		)
+	}
+
+	if req.ActionName == "" || req.ResourceName == "" {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Missing required fields.", http.StatusBadRequest,
+		)
+	}
+
+	condition, err := normalizeCondition(req.Condition)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_400, "Invalid permission condition.", err, http.StatusBadRequest,
+		)
+	}
+
+	if err := scpRbac.SetRolePermissionCondition(roleID, req.ActionName, req.ResourceName, condition); err != nil {
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_404, "Permission is not assigned to this role.", http.StatusNotFound,
+			)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to update permission condition.", err, http.StatusInternalServerError,
+		)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponse(
+		c, respcode.SUC_CODE_200, "Permission condition updated successfully!", http.StatusOK,
+	)
+}
+
+// normalizeCondition trims the condition and rejects one that does not parse,
+// so a typo never reaches role_permissions.
+func normalizeCondition(condition *string) (*string, error) {
+	if condition == nil || strings.TrimSpace(*condition) == "" {
+		return nil, nil
+	}
+
+	trimmed := strings.TrimSpace(*condition)
+	if _, err := hlpRbac.CompileCondition(trimmed); err != nil {
+		return nil, err
+	}
+	return &trimmed, nil
+}
+
 // ----------------------------
 // Permissions
 // ----------------------------
 
-// CreatePermission adds a new permission to the database
-func CreatePermission(c fiber.Ctx) error {
-	type CreatePermissionRequest struct {
-		Name string `json:"name"`
+// permissionError maps script errors shared by the permission catalogue handlers.
+func permissionError(c fiber.Ctx, err error, action string) error {
+	switch {
+	case errors.Is(err, errRbac.ErrResourceNotFound):
+		return v1.JSONResponse(c, respcode.ERR_CODE_404, "Permission, action or resource not found.", http.StatusNotFound)
+	case errors.Is(err, errRbac.ErrResourceNameTaken):
+		return v1.JSONResponse(c, respcode.ERR_CODE_409, "Permission already exists.", http.StatusConflict)
+	case errors.Is(err, errRbac.ErrResourceInUse):
+		return v1.JSONResponse(c, respcode.ERR_CODE_409, "Permission is granted to a role, can't be changed.", http.StatusConflict)
 	}
+	return v1.JSONResponseWithError(
+		c, respcode.ERR_CODE_500, "Failed to "+action+".", err, http.StatusInternalServerError,
+	)
+}
 
-	var req CreatePermissionRequest
+// CreatePermission adds an action/resource pair to the catalogue.
+func CreatePermission(c fiber.Ctx) error {
+	var req mdlRbac.PermissionRequest
 	if err := c.Bind().Body(&req); err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest)
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid request body.", http.StatusBadRequest)
 	}
-
-	if req.Name == "" {
-		return v1.JSONResponse(c, respcode.ERR_CODE_401, "Missing permission name.", http.StatusBadRequest)
+	if req.ActionID <= 0 || req.ResourceID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "action_id and resource_id are required.", http.StatusBadRequest)
 	}
 
-	err := scpRbac.CreatePermission(req.Name)
+	permission, err := scpRbac.CreatePermission(req.ActionID, req.ResourceID)
 	if err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_500, "Failed to create permission.", err, http.StatusInternalServerError)
+		return permissionError(c, err, "create permission")
 	}
 
-	return v1.JSONResponse(c, respcode.SUC_CODE_200, "Permission created successfully.", http.StatusOK)
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_201, "Permission created successfully.", permission, http.StatusCreated)
 }
 
-// FetchPermissions retrieves all permissions from the database
+// FetchAllPermissions lists the catalogue with formatted action:resource
+// names and how many roles grant each pair.
 func FetchAllPermissions(c fiber.Ctx) error {
-	users, err := scpRbac.FetchPermissions()
+	p, err := pagination.Parse(c, scpRbac.PermissionSorting)
 	if err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_502, "Failed to fetch permissions.", err, http.StatusInternalServerError)
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
 	}
 
-	if len(users) == 0 {
-		return v1.JSONResponse(c, respcode.SUC_CODE_204, "No permission found.", http.StatusOK)
+	permissions, total, err := scpRbac.FetchPermissions(p)
+	if err != nil {
+		return v1.JSONResponseWithError(c, respcode.ERR_CODE_500, "Failed to fetch permissions.", err, http.StatusInternalServerError)
 	}
 
-	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Permissions fetched successfully.", users, http.StatusOK)
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Permissions fetched successfully.", pagination.NewPage(permissions, p, total), http.StatusOK)
 }
 
-// UpdatePermission updates an existing permission in the database
+// UpdatePermission points an unused permission at another action/resource pair.
 func UpdatePermission(c fiber.Ctx) error {
-	permissionIDStr := c.Params("permission_id")
-	permissionID, err := strconv.Atoi(permissionIDStr)
-	if err != nil || permissionID == 0 {
-		return v1.JSONResponse(
-			c, respcode.ERR_CODE_401, "Invalid or missing permission_id parameter", http.StatusBadRequest,
-		)
+	permissionID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || permissionID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid permission ID.", http.StatusBadRequest)
 	}
 
-	type UpdatePermissionRequest struct {
-		PermissionName string `json:"permission_name"`
-	}
-
-	var req UpdatePermissionRequest
+	var req mdlRbac.PermissionRequest
 	if err := c.Bind().Body(&req); err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest)
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid request body.", http.StatusBadRequest)
 	}
-
-	if req.PermissionName == "" {
-		return v1.JSONResponse(c, respcode.ERR_CODE_401, "Missing permission name.", http.StatusBadRequest)
+	if req.ActionID <= 0 || req.ResourceID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "action_id and resource_id are required.", http.StatusBadRequest)
 	}
 
-	err = scpRbac.UpdatePermission(permissionID, req.PermissionName)
+	permission, err := scpRbac.UpdatePermission(permissionID, req.ActionID, req.ResourceID)
 	if err != nil {
-		return v1.JSONResponseWithError(c, respcode.ERR_CODE_500, "Failed to update permission.", err, http.StatusInternalServerError)
+		return permissionError(c, err, "update permission")
 	}
 
-	return v1.JSONResponse(c, respcode.SUC_CODE_200, "Permission updated successfully.", http.StatusOK)
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Permission updated successfully.", permission, http.StatusOK)
 }
 
-// DeletePermission removes a permission by its ID
+// DeletePermission removes a permission that no role grants.
 func DeletePermission(c fiber.Ctx) error {
-	permissionIDStr := c.Params("permission_id")
-	permissionID, err := strconv.Atoi(permissionIDStr)
-	if err != nil || permissionID == 0 {
-		return v1.JSONResponse(
-			c, respcode.ERR_CODE_401, "Invalid or missing permission_id parameter.", http.StatusBadRequest,
-		)
+	permissionID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || permissionID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid permission ID.", http.StatusBadRequest)
 	}
 
-	// Run delete script
-	err = scpRbac.DeletePermission(permissionID)
-	if err != nil {
-		return v1.JSONResponseWithError(
-			c, respcode.ERR_CODE_500, "Failed to delete permission.", err, http.StatusInternalServerError,
-		)
+	if err := scpRbac.DeletePermission(permissionID); err != nil {
+		return permissionError(c, err, "delete permission")
 	}
 
-	return v1.JSONResponse(
-		c, respcode.SUC_CODE_200, "Permission deleted successfully.", http.StatusOK,
-	)
+	return v1.JSONResponse(c, respcode.SUC_CODE_200, "Permission deleted successfully.", http.StatusOK)
 }
 
 // ----------------------------
 //  USER
 // ----------------------------
 
-// AssignRoleToUser assigns a role to a user
+// AssignUserRole sets a user's primary role, replacing the previous one. An
+// optional body with valid_from and valid_until makes it temporary. Use
+// AddUserRole to grant a role on top of the existing ones.
 func AssignUserRole(c fiber.Ctx) error {
 	staffID := c.Params("staffId")
 	roleIDStr := c.Params("roleId")
@@ -267,7 +434,25 @@ func AssignUserRole(c fiber.Ctx) error {
 		return v1.JSONResponse(c, respcode.ERR_CODE_401, "Missing staff_id or role_id.", http.StatusBadRequest)
 	}
 
-	if err := scpRbac.AssignUserRole(staffID, roleID); err != nil {
+	var req mdlRbac.PrimaryRoleRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest)
+		}
+	}
+
+	if msg := roleWindowError(req.ValidFrom, req.ValidUntil); msg != "" {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, msg, http.StatusBadRequest)
+	}
+
+	allowSuperuser := middleware.Authorize(c, hlpRbac.Permission("update:superuser"), nil).Allowed
+	if err := scpRbac.AssignUserRole(staffID, roleID, req.ValidFrom, req.ValidUntil, allowSuperuser); err != nil {
+		if errors.Is(err, errRbac.ErrSuperuserDenied) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_105_CD, respcode.ERR_CODE_105_CD_MSG, err, http.StatusForbidden)
+		}
+		if errors.Is(err, errRbac.ErrSoDViolation) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Separation-of-duties rule violated.", err, http.StatusConflict)
+		}
 		if errors.Is(err, errRbac.ErrResourceNotFound) {
 			return v1.JSONResponse(
 				c, respcode.ERR_CODE_404, "User not found.", http.StatusNotFound,
@@ -276,32 +461,155 @@ func AssignUserRole(c fiber.Ctx) error {
 		return v1.JSONResponseWithError(c, respcode.ERR_CODE_500, "Failed to assign role to user.", err, http.StatusInternalServerError)
 	}
 
+	hlpRbac.BumpVersion(c.Context())
+
 	return v1.JSONResponse(c, respcode.SUC_CODE_200, "Role assigned to user successfully.", http.StatusOK)
 }
 
+// GetUserRoles lists every role held by a user.
+func GetUserRoles(c fiber.Ctx) error {
+	staffID := c.Params("staffId")
+	if staffID == "" {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Missing staff_id.", http.StatusBadRequest)
+	}
+
+	roles, err := scpRbac.GetUserRoles(staffID)
+	if err != nil {
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(c, respcode.ERR_CODE_404, "User not found.", http.StatusNotFound)
+		}
+		return v1.JSONResponseWithError(c, respcode.ERR_CODE_500, "Failed to fetch user roles.", err, http.StatusInternalServerError)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "User roles fetched successfully", roles, http.StatusOK)
+}
+
+// AddUserRole gives a user an additional role next to the roles they already
+// hold. valid_from and valid_until make it temporary, e.g. for contractors and
+// relievers.
+func AddUserRole(c fiber.Ctx) error {
+	staffID := c.Params("staffId")
+
+	var req mdlRbac.UserRoleRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponseWithError(c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest)
+	}
+
+	if staffID == "" || req.RoleID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Missing staff_id or role_id.", http.StatusBadRequest)
+	}
+
+	if req.InstitutionCode != nil {
+		if code := strings.TrimSpace(*req.InstitutionCode); code != "" {
+			req.InstitutionCode = &code
+		} else {
+			req.InstitutionCode = nil
+		}
+	}
+
+	if msg := roleWindowError(req.ValidFrom, req.ValidUntil); msg != "" {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, msg, http.StatusBadRequest)
+	}
+
+	allowSuperuser := middleware.Authorize(c, hlpRbac.Permission("update:superuser"), nil).Allowed
+	if err := scpRbac.AddUserRole(staffID, req.RoleID, req.InstitutionCode, req.ValidFrom, req.ValidUntil, allowSuperuser); err != nil {
+		if errors.Is(err, errRbac.ErrSuperuserDenied) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_105_CD, respcode.ERR_CODE_105_CD_MSG, err, http.StatusForbidden)
+		}
+		if errors.Is(err, errRbac.ErrSoDViolation) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Separation-of-duties rule violated.", err, http.StatusConflict)
+		}
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(c, respcode.ERR_CODE_404, "User or role not found.", http.StatusNotFound)
+		}
+		if errors.Is(err, errRbac.ErrRoleAssigned) {
+			return v1.JSONResponse(c, respcode.ERR_CODE_409, "User already has this role.", http.StatusConflict)
+		}
+		return v1.JSONResponseWithError(c, respcode.ERR_CODE_500, "Failed to add role to user.", err, http.StatusInternalServerError)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponse(c, respcode.SUC_CODE_201, "Role added to user successfully.", http.StatusOK)
+}
+
+// roleWindowError describes what is wrong with an assignment's validity
+// window, or returns "" when it is usable.
+func roleWindowError(validFrom, validUntil *time.Time) string {
+	if validFrom != nil && validUntil != nil && !validUntil.After(*validFrom) {
+		return "valid_until must be after valid_from."
+	}
+	if validUntil != nil && !validUntil.After(time.Now()) {
+		return "valid_until must be in the future."
+	}
+	return ""
+}
+
+// RemoveUserRole takes one role away from a user. Pass ?institution_code= to
+// remove an institution-scoped assignment instead of the global one.
+func RemoveUserRole(c fiber.Ctx) error {
+	staffID := c.Params("staffId")
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || staffID == "" || roleID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Missing staff_id or role_id.", http.StatusBadRequest)
+	}
+
+	var institutionCode *string
+	if code := strings.TrimSpace(c.Query("institution_code")); code != "" {
+		institutionCode = &code
+	}
+
+	if err := scpRbac.RemoveUserRole(staffID, roleID, institutionCode); err != nil {
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(c, respcode.ERR_CODE_404, "User does not have this role.", http.StatusNotFound)
+		}
+		return v1.JSONResponseWithError(c, respcode.ERR_CODE_500, "Failed to remove role from user.", err, http.StatusInternalServerError)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponse(c, respcode.SUC_CODE_200, "Role removed from user successfully.", http.StatusOK)
+}
+
 func FetchAllUserRoles(c fiber.Ctx) error {
+	p, err := pagination.Parse(c, scpRbac.RoleSorting)
+	if err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
+	}
+
+	archived, err := includeArchived(c)
+	if err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
+	}
 
-	userRoles, err := scpRbac.FetchAllUserRoles()
+	userRoles, total, err := scpRbac.FetchAllUserRoles(p, archived)
 
 	if err != nil {
 		return v1.JSONResponseWithError(c, respcode.ERR_CODE_502, "Failed to fetch user roles.", err, http.StatusInternalServerError)
 	}
 
-	// Only check for nil pointer here since userRoles is a pointer type.
-	if userRoles == nil {
-		return v1.JSONResponse(c, respcode.SUC_CODE_204, "No user roles found!", http.StatusOK)
-	}
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "User roles fetched successfully", pagination.NewPage(userRoles, p, total), http.StatusOK)
+}
 
-	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "User roles fetched successfully", userRoles, http.StatusOK)
+// includeArchived reads ?include_archived=true on list endpoints.
+func includeArchived(c fiber.Ctx) (bool, error) {
+	raw := c.Query("include_archived")
+	if raw == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.New("include_archived must be true or false")
+	}
+	return value, nil
 }
 
 // ----------------------------
-//
-//	ACTION
-//
+//  ROLES
 // ----------------------------
-// CreateAction - Create a new action
-func CreateAction(c fiber.Ctx) error {
+
+// CreateRole - Create a new role
+func CreateRole(c fiber.Ctx) error {
 	var req mdlRbac.RbacItemRequest
 
 	if err := c.Bind().Body(&req); err != nil {
@@ -310,52 +618,63 @@ func CreateAction(c fiber.Ctx) error {
 		)
 	}
 
+	req.Name = strings.TrimSpace(req.Name)
 	if req.Name == "" {
 		return v1.JSONResponse(
-			c, respcode.ERR_CODE_400, "Action name is required.", http.StatusBadRequest,
+			c, respcode.ERR_CODE_400, "Role name is required.", http.StatusBadRequest,
 		)
 	}
 
-	err := scpRbac.CreateAction(req.Name, req.Description)
+	role, err := scpRbac.CreateRole(req.Name, req.Description)
 	if err != nil {
 		if errors.Is(err, errRbac.ErrResourceNameTaken) {
 			return v1.JSONResponse(
-				c, respcode.ERR_CODE_409, "Action name already exists.", http.StatusConflict,
+				c, respcode.ERR_CODE_409, "Role name already exists.", http.StatusConflict,
 			)
 		}
 		return v1.JSONResponseWithError(
-			c, respcode.ERR_CODE_500, "Failed to create action.", err, http.StatusInternalServerError,
+			c, respcode.ERR_CODE_500, "Failed to create role.", err, http.StatusInternalServerError,
 		)
 	}
 
-	return v1.JSONResponse(
-		c, respcode.SUC_CODE_201, "Action created successfully!", http.StatusOK,
+	return v1.JSONResponseWithData(
+		c, respcode.SUC_CODE_201, "Role created successfully!", role, http.StatusOK,
 	)
 }
 
-// GetActions - Get all actions
-func GetActions(c fiber.Ctx) error {
-	fmt.Println("GetActions Called")
-	actions, err := scpRbac.GetActions()
+// GetRole - Get a single role
+func GetRole(c fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest,
+		)
+	}
+
+	role, err := scpRbac.GetRoleByID(roleID)
 	if err != nil {
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_404, "Role not found.", http.StatusNotFound,
+			)
+		}
 		return v1.JSONResponseWithError(
-			c, respcode.ERR_CODE_500, "Failed to fetch actions.", err, http.StatusInternalServerError,
+			c, respcode.ERR_CODE_500, "Failed to fetch role.", err, http.StatusInternalServerError,
 		)
 	}
 
 	return v1.JSONResponseWithData(
-		c, respcode.SUC_CODE_200, "Actions fetched successfully!",
-		map[string]interface{}{"actions": actions}, http.StatusOK,
+		c, respcode.SUC_CODE_200, "Role fetched successfully!", role, http.StatusOK,
 	)
 }
 
-// UpdateAction - Update an action
-func UpdateAction(c fiber.Ctx) error {
+// UpdateRole - Rename or describe a role
+func UpdateRole(c fiber.Ctx) error {
 	var req mdlRbac.RbacItemRequest
-	actionID, err := strconv.Atoi(c.Params("id"))
-	if err != nil {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
 		return v1.JSONResponse(
-			c, respcode.ERR_CODE_400, "Invalid action ID.", http.StatusBadRequest,
+			c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest,
 		)
 	}
 
@@ -365,84 +684,377 @@ func UpdateAction(c fiber.Ctx) error {
 		)
 	}
 
+	req.Name = strings.TrimSpace(req.Name)
 	if req.Name == "" {
 		return v1.JSONResponse(
-			c, respcode.ERR_CODE_400, "Action name is required.", http.StatusBadRequest,
+			c, respcode.ERR_CODE_400, "Role name is required.", http.StatusBadRequest,
 		)
 	}
 
-	err = scpRbac.UpdateAction(actionID, req.Name, req.Description)
+	err = scpRbac.UpdateRole(roleID, req.Name, req.Description)
 	if err != nil {
 		if errors.Is(err, errRbac.ErrResourceNotFound) {
 			return v1.JSONResponse(
-				c, respcode.ERR_CODE_404, "Action not found.", http.StatusNotFound,
+				c, respcode.ERR_CODE_404, "Role not found.", http.StatusNotFound,
+			)
+		}
+		if errors.Is(err, errRbac.ErrResourceNameTaken) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_409, "Role name already exists.", http.StatusConflict,
 			)
 		}
 		return v1.JSONResponseWithError(
-			c, respcode.ERR_CODE_500, "Failed to update action.", err, http.StatusInternalServerError,
+			c, respcode.ERR_CODE_500, "Failed to update role.", err, http.StatusInternalServerError,
 		)
 	}
 
+	hlpRbac.BumpVersion(c.Context())
+
 	return v1.JSONResponse(
-		c, respcode.SUC_CODE_200, "Action updated successfully!", http.StatusOK,
+		c, respcode.SUC_CODE_200, "Role updated successfully!", http.StatusOK,
 	)
 }
 
-// DeleteAction - Delete an action
-func DeleteAction(c fiber.Ctx) error {
-	actionID, err := strconv.Atoi(c.Params("id"))
-	if err != nil {
+// DeleteRole - Archive a role; its grants and assignments stop taking effect
+func DeleteRole(c fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
 		return v1.JSONResponse(
-			c, respcode.ERR_CODE_400, "Invalid action ID.", http.StatusBadRequest,
+			c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest,
 		)
 	}
 
-	err = scpRbac.DeleteAction(actionID)
+	err = scpRbac.DeleteRole(roleID)
 	if err != nil {
 		if errors.Is(err, errRbac.ErrResourceNotFound) {
 			return v1.JSONResponse(
-				c, respcode.ERR_CODE_404, "Action not found.", http.StatusNotFound,
-			)
-		}
-		if errors.Is(err, errRbac.ErrResourceInUse) {
-			return v1.JSONResponse(
-				c, respcode.ERR_CODE_409, "Action is in use, can't be deleted.", http.StatusConflict,
+				c, respcode.ERR_CODE_404, "Role not found.", http.StatusNotFound,
 			)
 		}
 		return v1.JSONResponseWithError(
-			c, respcode.ERR_CODE_500, "Failed to delete action.", err, http.StatusInternalServerError,
+			c, respcode.ERR_CODE_500, "Failed to archive role.", err, http.StatusInternalServerError,
 		)
 	}
 
+	hlpRbac.BumpVersion(c.Context())
+
 	return v1.JSONResponse(
-		c, respcode.SUC_CODE_200, "Action deleted successfully!", http.StatusOK,
+		c, respcode.SUC_CODE_200, "Role archived successfully!", http.StatusOK,
 	)
 }
 
-// ----------------------------
-//
-//	RESOURCES
-//
-// ----------------------------
-func CreateResource(c fiber.Ctx) error {
-	var req mdlRbac.RbacItemRequest
-
-	// Parse request body
-	if err := c.Bind().Body(&req); err != nil {
-		return v1.JSONResponse(
-			c, respcode.ERR_CODE_400, "Invalid request body.", http.StatusBadRequest,
-		)
-	}
-
-	// Validate fields
-	if req.Name == "" {
+// RestoreRole - Bring back an archived role with its previous grants
+func RestoreRole(c fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
 		return v1.JSONResponse(
-			c, respcode.ERR_CODE_400, "Resource name is required.", http.StatusBadRequest,
+			c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest,
 		)
 	}
 
-	// Call service
-	err := scpRbac.CreateResource(req.Name, req.Description)
+	err = scpRbac.RestoreRole(roleID)
+	if err != nil {
+		if errors.Is(err, errRbac.ErrSoDViolation) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Separation-of-duties rule violated.", err, http.StatusConflict)
+		}
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_404, "Archived role not found.", http.StatusNotFound,
+			)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to restore role.", err, http.StatusInternalServerError,
+		)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponse(
+		c, respcode.SUC_CODE_200, "Role restored successfully!", http.StatusOK,
+	)
+}
+
+// SetRoleParent makes a role inherit every permission of its parent role.
+func SetRoleParent(c fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest,
+		)
+	}
+
+	var req mdlRbac.RoleParentRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest,
+		)
+	}
+
+	if req.ParentRoleID != nil && *req.ParentRoleID == roleID {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_409, "A role cannot inherit from itself.", http.StatusConflict,
+		)
+	}
+
+	if err := scpRbac.SetRoleParent(roleID, req.ParentRoleID); err != nil {
+		if errors.Is(err, errRbac.ErrSoDViolation) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Separation-of-duties rule violated.", err, http.StatusConflict)
+		}
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_404, "Role or parent role not found.", http.StatusNotFound,
+			)
+		}
+		if errors.Is(err, errRbac.ErrRoleCycle) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_409, "Parent role already inherits from this role.", http.StatusConflict,
+			)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to update role hierarchy.", err, http.StatusInternalServerError,
+		)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponse(
+		c, respcode.SUC_CODE_200, "Role hierarchy updated successfully!", http.StatusOK,
+	)
+}
+
+// SetRoleSuperuser turns the superuser flag of a role on or off. Users of a
+// superuser role pass every permission check; each such bypass is audited.
+// Guarded by update:superuser rather than update:role.
+func SetRoleSuperuser(c fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest,
+		)
+	}
+
+	var req mdlRbac.RoleSuperuserRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest,
+		)
+	}
+
+	if req.IsSuperuser == nil {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "is_superuser is required.", http.StatusBadRequest,
+		)
+	}
+
+	actor, _ := c.Locals("username").(string)
+	if err := scpRbac.SetRoleSuperuser(roleID, *req.IsSuperuser, actor); err != nil {
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_404, "Role not found.", http.StatusNotFound,
+			)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to update role.", err, http.StatusInternalServerError,
+		)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponse(
+		c, respcode.SUC_CODE_200, "Role updated successfully!", http.StatusOK,
+	)
+}
+
+// ----------------------------
+//
+//	ACTION
+//
+// ----------------------------
+// CreateAction - Create a new action
+func CreateAction(c fiber.Ctx) error {
+	var req mdlRbac.RbacItemRequest
+
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Invalid request body.", http.StatusBadRequest,
+		)
+	}
+
+	if req.Name == "" {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Action name is required.", http.StatusBadRequest,
+		)
+	}
+
+	err := scpRbac.CreateAction(req.Name, req.Description)
+	if err != nil {
+		if errors.Is(err, errRbac.ErrResourceNameTaken) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_409, "Action name already exists.", http.StatusConflict,
+			)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to create action.", err, http.StatusInternalServerError,
+		)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponse(
+		c, respcode.SUC_CODE_201, "Action created successfully!", http.StatusOK,
+	)
+}
+
+// GetActions - Get all actions
+func GetActions(c fiber.Ctx) error {
+	fmt.Println("GetActions Called")
+	p, err := pagination.Parse(c, scpRbac.ActionSorting)
+	if err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
+	}
+
+	archived, err := includeArchived(c)
+	if err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
+	}
+
+	actions, total, err := scpRbac.GetActions(p, archived)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to fetch actions.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponseWithData(
+		c, respcode.SUC_CODE_200, "Actions fetched successfully!",
+		pagination.NewPage(actions, p, total), http.StatusOK,
+	)
+}
+
+// UpdateAction - Update an action
+func UpdateAction(c fiber.Ctx) error {
+	var req mdlRbac.RbacItemRequest
+	actionID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Invalid action ID.", http.StatusBadRequest,
+		)
+	}
+
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Invalid request body.", http.StatusBadRequest,
+		)
+	}
+
+	if req.Name == "" {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Action name is required.", http.StatusBadRequest,
+		)
+	}
+
+	err = scpRbac.UpdateAction(actionID, req.Name, req.Description)
+	if err != nil {
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_404, "Action not found.", http.StatusNotFound,
+			)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to update action.", err, http.StatusInternalServerError,
+		)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponse(
+		c, respcode.SUC_CODE_200, "Action updated successfully!", http.StatusOK,
+	)
+}
+
+// DeleteAction - Archive an action; grants on it stop taking effect
+func DeleteAction(c fiber.Ctx) error {
+	actionID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Invalid action ID.", http.StatusBadRequest,
+		)
+	}
+
+	err = scpRbac.DeleteAction(actionID)
+	if err != nil {
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_404, "Action not found.", http.StatusNotFound,
+			)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to archive action.", err, http.StatusInternalServerError,
+		)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponse(
+		c, respcode.SUC_CODE_200, "Action archived successfully!", http.StatusOK,
+	)
+}
+
+// RestoreAction - Bring back an archived action with its previous grants
+func RestoreAction(c fiber.Ctx) error {
+	actionID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Invalid action ID.", http.StatusBadRequest,
+		)
+	}
+
+	err = scpRbac.RestoreAction(actionID)
+	if err != nil {
+		if errors.Is(err, errRbac.ErrSoDViolation) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Separation-of-duties rule violated.", err, http.StatusConflict)
+		}
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_404, "Archived action not found.", http.StatusNotFound,
+			)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to restore action.", err, http.StatusInternalServerError,
+		)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponse(
+		c, respcode.SUC_CODE_200, "Action restored successfully!", http.StatusOK,
+	)
+}
+
+// ----------------------------
+//
+//	RESOURCES
+//
+// ----------------------------
+func CreateResource(c fiber.Ctx) error {
+	var req mdlRbac.RbacItemRequest
+
+	// Parse request body
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Invalid request body.", http.StatusBadRequest,
+		)
+	}
+
+	// Validate fields
+	if req.Name == "" {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "Resource name is required.", http.StatusBadRequest,
+		)
+	}
+
+	// Call service
+	err := scpRbac.CreateResource(req.Name, req.Description)
 	if err != nil {
 
 		if errors.Is(err, errRbac.ErrResourceNameTaken) {
@@ -455,6 +1067,8 @@ func CreateResource(c fiber.Ctx) error {
 		)
 	}
 
+	hlpRbac.BumpVersion(c.Context())
+
 	// Success
 	return v1.JSONResponse(
 		c, respcode.SUC_CODE_201, "Resource created successfully!", http.StatusOK,
@@ -463,8 +1077,18 @@ func CreateResource(c fiber.Ctx) error {
 
 // GetResources - Get all resources
 func GetResources(c fiber.Ctx) error {
+	p, err := pagination.Parse(c, scpRbac.ResourceSorting)
+	if err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
+	}
+
+	archived, err := includeArchived(c)
+	if err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
+	}
+
 	// Call service
-	resources, err := scpRbac.GetResources()
+	resources, total, err := scpRbac.GetResources(p, archived)
 	if err != nil {
 		return v1.JSONResponseWithError(
 			c, respcode.ERR_CODE_500, "Failed to fetch resources.", err, http.StatusInternalServerError,
@@ -476,9 +1100,7 @@ func GetResources(c fiber.Ctx) error {
 		c,
 		respcode.SUC_CODE_200,
 		"Resources fetched successfully!",
-		map[string]interface{}{
-			"resources": resources,
-		},
+		pagination.NewPage(resources, p, total),
 		http.StatusOK,
 	)
 }
@@ -546,6 +1168,8 @@ func UpdateResource(c fiber.Ctx) error {
 		)
 	}
 
+	hlpRbac.BumpVersion(c.Context())
+
 	// Success
 	return v1.JSONResponse(
 		c,
@@ -555,7 +1179,7 @@ func UpdateResource(c fiber.Ctx) error {
 	)
 }
 
-// DeleteResource - Delete a resource
+// DeleteResource - Archive a resource; grants on it stop taking effect
 func DeleteResource(c fiber.Ctx) error {
 	// Get resource ID from URL parameter
 	resource := c.Params("id")
@@ -577,25 +1201,1174 @@ func DeleteResource(c fiber.Ctx) error {
 				c, respcode.ERR_CODE_404, "Resource not found.", http.StatusNotFound,
 			)
 		}
-		if errors.Is(err, errRbac.ErrResourceInUse) {
-			return v1.JSONResponse(
-				c, respcode.ERR_CODE_409, "Resource is in use, can't be deleted.", http.StatusConflict,
-			)
-		}
 		return v1.JSONResponseWithError(
 			c,
 			respcode.ERR_CODE_500,
-			"Internal server error while deleting resource",
+			"Internal server error while archiving resource",
 			err,
 			http.StatusInternalServerError,
 		)
 	}
 
+	hlpRbac.BumpVersion(c.Context())
+
 	// Success
 	return v1.JSONResponse(
 		c,
 		respcode.SUC_CODE_200,
-		"Resource deleted successfully!",
+		"Resource archived successfully!",
+		http.StatusOK,
+	)
+}
+
+// RestoreResource - Bring back an archived resource with its previous grants
+func RestoreResource(c fiber.Ctx) error {
+	resourceID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return v1.JSONResponse(
+			c,
+			respcode.ERR_CODE_400,
+			"Invalid resource ID.",
+			http.StatusBadRequest,
+		)
+	}
+
+	err = scpRbac.RestoreResource(resourceID)
+	if err != nil {
+		if errors.Is(err, errRbac.ErrSoDViolation) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Separation-of-duties rule violated.", err, http.StatusConflict)
+		}
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_404, "Archived resource not found.", http.StatusNotFound,
+			)
+		}
+		return v1.JSONResponseWithError(
+			c,
+			respcode.ERR_CODE_500,
+			"Internal server error while restoring resource",
+			err,
+			http.StatusInternalServerError,
+		)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponse(
+		c,
+		respcode.SUC_CODE_200,
+		"Resource restored successfully!",
 		http.StatusOK,
 	)
 }
+
+// ----------------------------
+//  ACCESS DECISIONS
+// ----------------------------
+
+// CheckAccess answers "may this user do this?" for services that trust our
+// RBAC data but do not run our middleware. It uses the same evaluation as
+// RequirePermission, including the audited superuser bypass.
+func CheckAccess(c fiber.Ctx) error {
+	var req mdlRbac.AccessCheckRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest,
+		)
+	}
+
+	if strings.TrimSpace(req.Username) == "" {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Missing username.", http.StatusBadRequest)
+	}
+
+	result, err := checkAccess(c, req, map[string]*mdlAuth.UserWithPermissions{}, true)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to check access.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Access checked successfully.", result, http.StatusOK)
+}
+
+// CheckAccessBatch runs up to mdlRbac.MaxAccessChecks checks in one call and
+// returns the results in request order.
+func CheckAccessBatch(c fiber.Ctx) error {
+	var req mdlRbac.AccessCheckBatchRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest,
+		)
+	}
+
+	if len(req.Checks) == 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "No checks given.", http.StatusBadRequest)
+	}
+	if len(req.Checks) > mdlRbac.MaxAccessChecks {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, fmt.Sprintf("At most %d checks per request.", mdlRbac.MaxAccessChecks), http.StatusBadRequest,
+		)
+	}
+
+	users := map[string]*mdlAuth.UserWithPermissions{}
+	results := make([]mdlRbac.AccessCheckResult, 0, len(req.Checks))
+	for i, check := range req.Checks {
+		if strings.TrimSpace(check.Username) == "" {
+			check.Username = req.Username
+		}
+		if strings.TrimSpace(check.InstitutionCode) == "" {
+			check.InstitutionCode = req.InstitutionCode
+		}
+		if strings.TrimSpace(check.Username) == "" {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_400, fmt.Sprintf("Missing username in check %d.", i), http.StatusBadRequest,
+			)
+		}
+
+		result, err := checkAccess(c, check, users, true)
+		if err != nil {
+			return v1.JSONResponseWithError(
+				c, respcode.ERR_CODE_500, "Failed to check access.", err, http.StatusInternalServerError,
+			)
+		}
+		results = append(results, result)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Access checked successfully.", results, http.StatusOK)
+}
+
+// checkAccess evaluates one check. users memoises loaded users so a batch
+// about one user hits the permission cache once. audit records superuser
+// bypasses; explanations, which grant nothing, skip it.
+func checkAccess(c fiber.Ctx, req mdlRbac.AccessCheckRequest, users map[string]*mdlAuth.UserWithPermissions, audit bool) (mdlRbac.AccessCheckResult, error) {
+	username := strings.TrimSpace(req.Username)
+	result := mdlRbac.AccessCheckResult{
+		Username:        username,
+		InstitutionCode: req.InstitutionCode,
+		MatchedGrants:   []string{},
+	}
+
+	requirement, err := checkRequirement(req)
+	if err != nil {
+		result.Requirement = strings.TrimSpace(req.Permission + req.Expression)
+		result.Code = hlpRbac.ReasonInvalidRequirement
+		result.Reason = err.Error()
+		return result, nil
+	}
+	result.Requirement = requirement.String()
+
+	user, ok := users[strings.ToLower(username)]
+	if !ok {
+		user, err = hlpRbac.GetUserPermissions(c.Context(), username)
+		if err != nil {
+			return result, err
+		}
+		users[strings.ToLower(username)] = user
+	}
+	if user == nil || user.Username == "" {
+		result.Code = hlpRbac.ReasonUserNotFound
+		result.Reason = "user " + username + " not found"
+		return result, nil
+	}
+
+	attrs := hlpRbac.SubjectAttributes(user, req.InstitutionCode)
+	for k, v := range hlpRbac.TimeAttributes(time.Now()) {
+		attrs[k] = v
+	}
+	attrs = attrs.Merge("request.", req.Request).Merge("resource.", req.Resource)
+
+	set := hlpRbac.ScopedPermissionSet(user, req.InstitutionCode)
+	decision := hlpRbac.Decide(user, req.InstitutionCode, set, requirement, attrs)
+	if audit && decision.Code == hlpRbac.ReasonSuperuser {
+		auditCheckBypass(c, user, req.InstitutionCode, requirement)
+	}
+
+	result.Allowed = decision.Allowed
+	result.Code = decision.Code
+	result.MatchedGrants = decision.MatchedGrants
+	result.Missing = decision.Missing
+	if len(decision.MatchedGrants) > 0 {
+		result.MatchedGrant = decision.MatchedGrants[0]
+	}
+	result.Reason = decision.Reason
+	return result, nil
+}
+
+func checkRequirement(req mdlRbac.AccessCheckRequest) (hlpRbac.Requirement, error) {
+	permission := strings.TrimSpace(req.Permission)
+	expression := strings.TrimSpace(req.Expression)
+
+	switch {
+	case permission != "" && expression != "":
+		return nil, fmt.Errorf("%w: give either permission or expression, not both", errRbac.ErrInvalidExpression)
+	case permission != "":
+		// Parsed so "view" or "view:role && x" is rejected rather than never matching
+		req, err := hlpRbac.ParseRequirement(permission)
+		if err != nil {
+			return nil, err
+		}
+		if req.String() != permission {
+			return nil, fmt.Errorf("%w: %q is not a single permission", errRbac.ErrInvalidExpression, permission)
+		}
+		return req, nil
+	case expression != "":
+		return hlpRbac.ParseRequirement(expression)
+	default:
+		return nil, fmt.Errorf("%w: missing permission or expression", errRbac.ErrInvalidExpression)
+	}
+}
+
+func auditCheckBypass(c fiber.Ctx, user *mdlAuth.UserWithPermissions, institutionCode string, req hlpRbac.Requirement) {
+	actor := ""
+	if caller, ok := c.Locals("user").(*mdlAuth.UserWithPermissions); ok && caller != nil {
+		actor = caller.Username
+	}
+
+	detail := map[string]interface{}{
+		"role":        user.RoleName,
+		"institution": institutionCode,
+		"requirement": req.String(),
+		"checked_by":  actor,
+		"path":        c.Path(),
+	}
+	if err := scpRbac.RecordAudit(mdlRbac.AuditSuperuserBypass, user.Username, c.Path(), detail); err != nil {
+		log.Printf("Failed to audit superuser bypass for %s: %v", user.Username, err)
+	}
+}
+
+// ExplainPermissions shows why a user is or is not allowed something. Query:
+// username (required), institution_code, and optionally permission or
+// expression to evaluate. It reads the same cached permissions the
+// middleware does, so it explains what the user actually got.
+func ExplainPermissions(c fiber.Ctx) error {
+	username := strings.TrimSpace(c.Query("username"))
+	if username == "" {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Missing username.", http.StatusBadRequest)
+	}
+	institutionCode := strings.TrimSpace(c.Query("institution_code"))
+
+	user, err := hlpRbac.GetUserPermissions(c.Context(), username)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to load user permissions.", err, http.StatusInternalServerError,
+		)
+	}
+	if user == nil || user.Username == "" {
+		return v1.JSONResponse(c, respcode.ERR_CODE_404, "User not found.", http.StatusNotFound)
+	}
+
+	explanation := mdlRbac.PermissionExplanation{
+		Username:        user.Username,
+		StaffID:         user.StaffID,
+		InstitutionCode: institutionCode,
+		Roles:           []mdlRbac.ExplainedRole{},
+		IsSuperuser:     hlpRbac.IsSuperuserIn(user, institutionCode),
+		Permissions:     []mdlRbac.EffectivePermission{},
+	}
+
+	// Effective grants of every role that applies, with their source
+	seen := map[int]bool{}
+	for _, role := range user.Roles {
+		inScope := hlpRbac.RoleApplies(role, institutionCode)
+		explanation.Roles = append(explanation.Roles, mdlRbac.ExplainedRole{
+			ID:              role.ID,
+			Name:            role.Name,
+			IsSuperuser:     role.IsSuperuser,
+			InstitutionCode: role.InstitutionCode,
+			ValidFrom:       role.ValidFrom,
+			ValidUntil:      role.ValidUntil,
+			InScope:         inScope,
+		})
+		if !inScope || seen[role.ID] {
+			continue
+		}
+		seen[role.ID] = true
+
+		if role.IsSuperuser {
+			explanation.Permissions = append(explanation.Permissions, mdlRbac.EffectivePermission{
+				Permission: hlpRbac.Wildcard + ":" + hlpRbac.Wildcard,
+				Source:     mdlRbac.SourceSuperuser,
+				Role:       role.Name,
+			})
+		}
+
+		rolePerms, err := scpRbac.GetRolePermissionsByRole(role.ID)
+		if err != nil {
+			return v1.JSONResponseWithError(
+				c, respcode.ERR_CODE_500, "Failed to fetch role permissions.", err, http.StatusInternalServerError,
+			)
+		}
+		for _, perm := range rolePerms.Permissions {
+			source := mdlRbac.SourceDirect
+			if perm.Inherited {
+				source = mdlRbac.SourceInherited
+			}
+			explanation.Permissions = append(explanation.Permissions, mdlRbac.EffectivePermission{
+				Permission:    perm.Formatted,
+				Condition:     perm.Condition,
+				Source:        source,
+				Role:          role.Name,
+				InheritedFrom: perm.InheritedFrom,
+			})
+		}
+	}
+
+	if c.Query("permission") == "" && c.Query("expression") == "" {
+		return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Permissions explained successfully.", explanation, http.StatusOK)
+	}
+
+	check := mdlRbac.AccessCheckRequest{
+		Username:        username,
+		InstitutionCode: institutionCode,
+		Permission:      c.Query("permission"),
+		Expression:      c.Query("expression"),
+	}
+	users := map[string]*mdlAuth.UserWithPermissions{strings.ToLower(username): user}
+	result, err := checkAccess(c, check, users, false)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to check access.", err, http.StatusInternalServerError,
+		)
+	}
+	explanation.Check = &result
+
+	// Closest missing grants, even under a superuser bypass, so support can
+	// see what a regular role would need
+	set := hlpRbac.ScopedPermissionSet(user, institutionCode)
+	for _, permission := range result.Missing {
+		missing := mdlRbac.MissingGrant{
+			Permission:    permission,
+			NearestHeld:   set.Nearest(permission),
+			GrantingRoles: []string{},
+		}
+		if missing.NearestHeld == nil {
+			missing.NearestHeld = []string{}
+		}
+
+		action, resource, _ := strings.Cut(permission, ":")
+		roles, err := scpRbac.RolesGranting(action, resource)
+		if err != nil {
+			return v1.JSONResponseWithError(
+				c, respcode.ERR_CODE_500, "Failed to fetch granting roles.", err, http.StatusInternalServerError,
+			)
+		}
+		for _, role := range roles {
+			missing.GrantingRoles = append(missing.GrantingRoles, role.Name)
+		}
+		explanation.Missing = append(explanation.Missing, missing)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Permissions explained successfully.", explanation, http.StatusOK)
+}
+
+// ----------------------------
+//  ACCESS REQUESTS
+// ----------------------------
+
+const defaultAccessRequestMaxMinutes = 8 * 60
+
+// accessRequestMaxMinutes reads ACCESS_REQUEST_MAX_MINUTES, the longest
+// duration a request may ask for.
+func accessRequestMaxMinutes() int {
+	if minutes, err := strconv.Atoi(utils_v1.GetEnv("ACCESS_REQUEST_MAX_MINUTES")); err == nil && minutes > 0 {
+		return minutes
+	}
+	return defaultAccessRequestMaxMinutes
+}
+
+func currentUser(c fiber.Ctx) *mdlAuth.UserWithPermissions {
+	user, _ := c.Locals("user").(*mdlAuth.UserWithPermissions)
+	return user
+}
+
+// CreateAccessRequest lets the authenticated user ask for a role or a single
+// permission for a stated reason and a fixed duration.
+func CreateAccessRequest(c fiber.Ctx) error {
+	user := currentUser(c)
+	if user == nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_111, respcode.ERR_CODE_111_MSG, http.StatusUnauthorized)
+	}
+
+	var req mdlRbac.AccessRequestCreate
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest,
+		)
+	}
+
+	req.ActionName = strings.TrimSpace(req.ActionName)
+	req.ResourceName = strings.TrimSpace(req.ResourceName)
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.InstitutionCode != nil {
+		if code := strings.TrimSpace(*req.InstitutionCode); code != "" {
+			req.InstitutionCode = &code
+		} else {
+			req.InstitutionCode = nil
+		}
+	}
+
+	wantsRole := req.RoleID != nil
+	wantsPermission := req.ActionName != "" || req.ResourceName != ""
+	switch {
+	case wantsRole == wantsPermission:
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Request either a role_id or an action and resource.", http.StatusBadRequest)
+	case wantsRole && *req.RoleID <= 0:
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest)
+	case wantsPermission && (req.ActionName == "" || req.ResourceName == ""):
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "A permission needs both an action and a resource.", http.StatusBadRequest)
+	case req.Reason == "":
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "A reason is required.", http.StatusBadRequest)
+	}
+
+	if maxMinutes := accessRequestMaxMinutes(); req.DurationMinutes <= 0 || req.DurationMinutes > maxMinutes {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, fmt.Sprintf("duration_minutes must be between 1 and %d.", maxMinutes), http.StatusBadRequest,
+		)
+	}
+
+	request, err := scpRbac.CreateAccessRequest(int(user.ID), user.Username, req)
+	if err != nil {
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(c, respcode.ERR_CODE_404, "Role, action or resource not found.", http.StatusNotFound)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to create access request.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_201, "Access request created successfully.", request, http.StatusCreated)
+}
+
+// ListAccessRequests lists requests a page at a time, optionally filtered by ?status=.
+func ListAccessRequests(c fiber.Ctx) error {
+	p, err := pagination.Parse(c, scpRbac.AccessRequestSorting)
+	if err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
+	}
+
+	requests, total, err := scpRbac.ListAccessRequests(strings.TrimSpace(c.Query("status")), 0, p)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to fetch access requests.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Access requests fetched successfully.", pagination.NewPage(requests, p, total), http.StatusOK)
+}
+
+// ListMyAccessRequests lists the authenticated user's own requests.
+func ListMyAccessRequests(c fiber.Ctx) error {
+	user := currentUser(c)
+	if user == nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_111, respcode.ERR_CODE_111_MSG, http.StatusUnauthorized)
+	}
+
+	p, err := pagination.Parse(c, scpRbac.AccessRequestSorting)
+	if err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
+	}
+
+	requests, total, err := scpRbac.ListAccessRequests(strings.TrimSpace(c.Query("status")), int(user.ID), p)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to fetch access requests.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Access requests fetched successfully.", pagination.NewPage(requests, p, total), http.StatusOK)
+}
+
+// ApproveAccessRequest turns a pending request into a time-limited grant.
+func ApproveAccessRequest(c fiber.Ctx) error {
+	return decideAccessRequest(c, true)
+}
+
+// DenyAccessRequest closes a pending request without granting anything.
+func DenyAccessRequest(c fiber.Ctx) error {
+	return decideAccessRequest(c, false)
+}
+
+func decideAccessRequest(c fiber.Ctx, approve bool) error {
+	user := currentUser(c)
+	if user == nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_111, respcode.ERR_CODE_111_MSG, http.StatusUnauthorized)
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid access request ID.", http.StatusBadRequest)
+	}
+
+	var req mdlRbac.AccessRequestDecision
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return v1.JSONResponseWithError(
+				c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest,
+			)
+		}
+	}
+
+	allowSuperuser := middleware.Authorize(c, hlpRbac.Permission("update:superuser"), nil).Allowed
+	request, err := scpRbac.DecideAccessRequest(id, int(user.ID), user.Username, approve, strings.TrimSpace(req.Note), allowSuperuser)
+	if err != nil {
+		return accessRequestError(c, err)
+	}
+
+	message := "Access request denied."
+	if approve {
+		hlpRbac.BumpVersion(c.Context())
+		message = "Access request approved."
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, message, request, http.StatusOK)
+}
+
+// CancelAccessRequest lets requesters withdraw their own pending request.
+func CancelAccessRequest(c fiber.Ctx) error {
+	user := currentUser(c)
+	if user == nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_111, respcode.ERR_CODE_111_MSG, http.StatusUnauthorized)
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid access request ID.", http.StatusBadRequest)
+	}
+
+	request, err := scpRbac.CancelAccessRequest(id, int(user.ID), user.Username)
+	if err != nil {
+		return accessRequestError(c, err)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Access request cancelled.", request, http.StatusOK)
+}
+
+// RevokeAccessRequest ends an approved request before it expires.
+func RevokeAccessRequest(c fiber.Ctx) error {
+	user := currentUser(c)
+	if user == nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_111, respcode.ERR_CODE_111_MSG, http.StatusUnauthorized)
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid access request ID.", http.StatusBadRequest)
+	}
+
+	var req mdlRbac.AccessRequestDecision
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return v1.JSONResponseWithError(
+				c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest,
+			)
+		}
+	}
+
+	request, err := scpRbac.RevokeAccessRequest(id, user.Username, strings.TrimSpace(req.Note))
+	if err != nil {
+		return accessRequestError(c, err)
+	}
+
+	hlpRbac.BumpVersion(c.Context())
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Access request revoked.", request, http.StatusOK)
+}
+
+func accessRequestError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errRbac.ErrResourceNotFound):
+		return v1.JSONResponse(c, respcode.ERR_CODE_404, "Access request not found.", http.StatusNotFound)
+	case errors.Is(err, errRbac.ErrRequestStatus):
+		return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Access request cannot be changed in its current status.", err, http.StatusConflict)
+	case errors.Is(err, errRbac.ErrSoDViolation):
+		return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Separation-of-duties rule violated.", err, http.StatusConflict)
+	case errors.Is(err, errRbac.ErrSelfApproval):
+		return v1.JSONResponse(c, respcode.ERR_CODE_105_CD, "You cannot decide your own access request.", http.StatusForbidden)
+	case errors.Is(err, errRbac.ErrSuperuserDenied):
+		return v1.JSONResponseWithError(c, respcode.ERR_CODE_105_CD, respcode.ERR_CODE_105_CD_MSG, err, http.StatusForbidden)
+	default:
+		return v1.JSONResponseWithError(c, respcode.ERR_CODE_500, "Failed to update access request.", err, http.StatusInternalServerError)
+	}
+}
+
+// ----------------------------
+//  SEPARATION OF DUTIES
+// ----------------------------
+
+// CreateSoDRule declares a set of mutually exclusive roles and/or
+// permissions. Existing holders are not touched; they show up in
+// GetSoDViolations.
+func CreateSoDRule(c fiber.Ctx) error {
+	var req mdlRbac.SoDRuleRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_301, "Invalid request body.", err, http.StatusBadRequest,
+		)
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Rule name is required.", http.StatusBadRequest)
+	}
+
+	permissions := make([]string, 0, len(req.Permissions))
+	for _, permission := range req.Permissions {
+		permission = strings.TrimSpace(permission)
+		if action, resource, ok := strings.Cut(permission, ":"); !ok || action == "" || resource == "" {
+			return v1.JSONResponse(
+				c, respcode.ERR_CODE_400, "Permission "+permission+" must look like action:resource.", http.StatusBadRequest,
+			)
+		}
+		permissions = append(permissions, permission)
+	}
+	req.Permissions = permissions
+
+	if len(req.RoleIDs)+len(req.Permissions) < 2 {
+		return v1.JSONResponse(
+			c, respcode.ERR_CODE_400, "A rule needs at least two roles or permissions.", http.StatusBadRequest,
+		)
+	}
+
+	rule, err := scpRbac.CreateSoDRule(req)
+	if err != nil {
+		if errors.Is(err, errRbac.ErrResourceNameTaken) {
+			return v1.JSONResponse(c, respcode.ERR_CODE_409, "Rule name is already in use.", http.StatusConflict)
+		}
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_404, "Role or permission not found.", err, http.StatusNotFound)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to create SoD rule.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_201, "SoD rule created successfully.", rule, http.StatusCreated)
+}
+
+// GetSoDRules lists separation-of-duties rules a page at a time.
+func GetSoDRules(c fiber.Ctx) error {
+	p, err := pagination.Parse(c, scpRbac.SoDRuleSorting)
+	if err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, err.Error(), http.StatusBadRequest)
+	}
+
+	rules, total, err := scpRbac.GetSoDRules(p)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to fetch SoD rules.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "SoD rules fetched successfully.", pagination.NewPage(rules, p, total), http.StatusOK)
+}
+
+// DeleteSoDRule drops a rule.
+func DeleteSoDRule(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid rule ID.", http.StatusBadRequest)
+	}
+
+	if err := scpRbac.DeleteSoDRule(id); err != nil {
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(c, respcode.ERR_CODE_404, "SoD rule not found.", http.StatusNotFound)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to delete SoD rule.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponse(c, respcode.SUC_CODE_200, "SoD rule deleted successfully.", http.StatusOK)
+}
+
+// GetSoDViolations reports every user and role currently breaking a rule.
+func GetSoDViolations(c fiber.Ctx) error {
+	violations, err := scpRbac.GetSoDViolations()
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to fetch SoD violations.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "SoD violations fetched successfully.", violations, http.StatusOK)
+}
+
+// ----------------------------
+//  POLICY EXPORT / IMPORT
+// ----------------------------
+
+// ExportPolicy downloads the whole RBAC configuration as a versioned
+// document, JSON by default or YAML with ?format=yaml. The document is
+// returned as is, not wrapped, so it can be fed straight to ImportPolicy.
+func ExportPolicy(c fiber.Ctx) error {
+	doc, err := scpRbac.ExportPolicy()
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to export policy.", err, http.StatusInternalServerError,
+		)
+	}
+
+	if strings.EqualFold(c.Query("format"), "yaml") {
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return v1.JSONResponseWithError(
+				c, respcode.ERR_CODE_500, "Failed to encode policy.", err, http.StatusInternalServerError,
+			)
+		}
+		c.Set(fiber.HeaderContentType, "application/yaml")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="rbac-policy.yaml"`)
+		return c.Status(http.StatusOK).Send(out)
+	}
+
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="rbac-policy.json"`)
+	return c.Status(http.StatusOK).JSON(doc)
+}
+
+// ImportPolicy applies an exported document (JSON or YAML) in one
+// transaction. ?dry_run=true returns the diff without keeping any change;
+// ?prune=true also archives roles, actions and resources the document lacks.
+// Touching a role's superuser flag also needs update:superuser.
+func ImportPolicy(c fiber.Ctx) error {
+	var opts mdlRbac.PolicyImportOptions
+	for name, target := range map[string]*bool{"dry_run": &opts.DryRun, "prune": &opts.Prune} {
+		if raw := c.Query(name); raw != "" {
+			value, err := strconv.ParseBool(raw)
+			if err != nil {
+				return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid "+name+" flag.", http.StatusBadRequest)
+			}
+			*target = value
+		}
+	}
+
+	// YAML is a superset of JSON, so one decoder reads both
+	var doc mdlRbac.PolicyDocument
+	decoder := yaml.NewDecoder(bytes.NewReader(c.Body()))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&doc); err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_301, "Invalid policy document.", err, http.StatusBadRequest,
+		)
+	}
+
+	if err := validatePolicy(&doc); err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_400, "Invalid policy document.", err, http.StatusBadRequest,
+		)
+	}
+
+	// update:policy alone must not be a way around the superuser route
+	opts.AllowSuperuser = middleware.Authorize(c, hlpRbac.Permission("update:superuser"), nil).Allowed
+
+	actor, _ := c.Locals("username").(string)
+	diff, err := scpRbac.ImportPolicy(&doc, opts, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, errRbac.ErrResourceNotFound):
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_404, "Policy refers to something that does not exist.", err, http.StatusNotFound)
+		case errors.Is(err, errRbac.ErrSuperuserDenied):
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_105_CD, respcode.ERR_CODE_105_CD_MSG, err, http.StatusForbidden)
+		case errors.Is(err, errRbac.ErrRoleCycle):
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Policy role hierarchy contains a cycle.", err, http.StatusConflict)
+		case errors.Is(err, errRbac.ErrSoDViolation):
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Separation-of-duties rule violated.", err, http.StatusConflict)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to import policy.", err, http.StatusInternalServerError,
+		)
+	}
+
+	message := "Policy dry run completed."
+	if diff.Applied {
+		if len(diff.Changes) > 0 {
+			hlpRbac.BumpVersion(c.Context())
+		}
+		message = "Policy imported successfully."
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, message, diff, http.StatusOK)
+}
+
+// validatePolicy checks the document before anything touches the database:
+// supported version, unique non-empty names and parseable conditions.
+func validatePolicy(doc *mdlRbac.PolicyDocument) error {
+	if doc.Version != mdlRbac.PolicyVersion {
+		return fmt.Errorf("unsupported policy version %d, expected %d", doc.Version, mdlRbac.PolicyVersion)
+	}
+
+	for kind, items := range map[string][]mdlRbac.PolicyItem{"action": doc.Actions, "resource": doc.Resources} {
+		seen := map[string]bool{}
+		for i := range items {
+			items[i].Name = strings.TrimSpace(items[i].Name)
+			if items[i].Name == "" {
+				return fmt.Errorf("%s %d has no name", kind, i)
+			}
+			if seen[items[i].Name] {
+				return fmt.Errorf("%s %q is listed twice", kind, items[i].Name)
+			}
+			seen[items[i].Name] = true
+		}
+	}
+
+	seenRoles := map[string]bool{}
+	for i := range doc.Roles {
+		role := &doc.Roles[i]
+		role.Name = strings.TrimSpace(role.Name)
+		role.Parent = strings.TrimSpace(role.Parent)
+		if role.Name == "" {
+			return fmt.Errorf("role %d has no name", i)
+		}
+		if seenRoles[role.Name] {
+			return fmt.Errorf("role %q is listed twice", role.Name)
+		}
+		if role.Parent == role.Name {
+			return fmt.Errorf("role %q cannot be its own parent", role.Name)
+		}
+		seenRoles[role.Name] = true
+
+		seenPermissions := map[string]bool{}
+		for j := range role.Permissions {
+			perm := &role.Permissions[j]
+			perm.Action = strings.TrimSpace(perm.Action)
+			perm.Resource = strings.TrimSpace(perm.Resource)
+			key := perm.Action + ":" + perm.Resource
+			if perm.Action == "" || perm.Resource == "" {
+				return fmt.Errorf("role %q has a permission without an action or resource", role.Name)
+			}
+			if seenPermissions[key] {
+				return fmt.Errorf("role %q lists %s twice", role.Name, key)
+			}
+			seenPermissions[key] = true
+
+			condition, err := normalizeCondition(perm.Condition)
+			if err != nil {
+				return fmt.Errorf("role %q, %s: %w", role.Name, key, err)
+			}
+			perm.Condition = condition
+		}
+	}
+
+	return nil
+}
+
+// ----------------------------
+//  ROUTE REGISTRY
+// ----------------------------
+
+// GetRoutes lists every API route with the permissions it requires, plus any
+// requirement that does not match the actions and resources tables.
+func GetRoutes(c fiber.Ctx) error {
+	issues, err := hlpRbac.CheckRoutes()
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to validate routes.", err, http.StatusInternalServerError,
+		)
+	}
+
+	routes := mdlRbac.RouteMap{Routes: hlpRbac.RegisteredRoutes(), Issues: issues}
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Routes fetched successfully.", routes, http.StatusOK)
+}
+
+// ----------------------------
+//  MENUS
+// ----------------------------
+
+// normalizeMenu trims the request and checks the permission expression. It
+// returns a client error message, or "" when the request is valid.
+func normalizeMenu(req *mdlRbac.MenuRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Slug = strings.TrimSpace(req.Slug)
+	if req.Name == "" || req.Slug == "" {
+		return "Menu name and slug are required."
+	}
+
+	for _, field := range []**string{&req.Path, &req.Icon, &req.SystemTag, &req.Permission} {
+		if *field == nil {
+			continue
+		}
+		if trimmed := strings.TrimSpace(**field); trimmed != "" {
+			*field = &trimmed
+		} else {
+			*field = nil
+		}
+	}
+
+	if req.Permission != nil {
+		if _, err := hlpRbac.ParseRequirement(*req.Permission); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// menuError maps script errors shared by the menu handlers.
+func menuError(c fiber.Ctx, err error, action string) error {
+	switch {
+	case errors.Is(err, errRbac.ErrResourceNotFound):
+		return v1.JSONResponse(c, respcode.ERR_CODE_404, "Menu, parent menu or role not found.", http.StatusNotFound)
+	case errors.Is(err, errRbac.ErrResourceNameTaken):
+		return v1.JSONResponse(c, respcode.ERR_CODE_409, "Menu slug already exists.", http.StatusConflict)
+	case errors.Is(err, errRbac.ErrMenuCycle):
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "A menu cannot be nested under itself or its children.", http.StatusBadRequest)
+	case errors.Is(err, errRbac.ErrResourceInUse):
+		return v1.JSONResponse(c, respcode.ERR_CODE_409, "Menu has children, can't be deleted.", http.StatusConflict)
+	}
+	return v1.JSONResponseWithError(
+		c, respcode.ERR_CODE_500, "Failed to "+action+".", err, http.StatusInternalServerError,
+	)
+}
+
+// CreateMenu adds a menu. permission is an optional requirement expression
+// that shows the menu to whoever satisfies it.
+func CreateMenu(c fiber.Ctx) error {
+	var req mdlRbac.MenuRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid request body.", http.StatusBadRequest)
+	}
+	if msg := normalizeMenu(&req); msg != "" {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, msg, http.StatusBadRequest)
+	}
+
+	menu, err := scpRbac.CreateMenu(req)
+	if err != nil {
+		return menuError(c, err, "create menu")
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_201, "Menu created successfully.", menu, http.StatusCreated)
+}
+
+// GetMenus returns the whole menu tree, filtered by ?system_tag=.
+func GetMenus(c fiber.Ctx) error {
+	menus, err := scpRbac.GetMenus(strings.TrimSpace(c.Query("system_tag")))
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to fetch menus.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Menus fetched successfully.", hlpRbac.MenuTree(menus, nil), http.StatusOK)
+}
+
+// UpdateMenu rewrites a menu, including where it sits in the tree.
+func UpdateMenu(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid menu ID.", http.StatusBadRequest)
+	}
+
+	var req mdlRbac.MenuRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid request body.", http.StatusBadRequest)
+	}
+	if msg := normalizeMenu(&req); msg != "" {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, msg, http.StatusBadRequest)
+	}
+
+	menu, err := scpRbac.UpdateMenu(id, req)
+	if err != nil {
+		return menuError(c, err, "update menu")
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Menu updated successfully.", menu, http.StatusOK)
+}
+
+// DeleteMenu removes a menu without children.
+func DeleteMenu(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid menu ID.", http.StatusBadRequest)
+	}
+
+	if err := scpRbac.DeleteMenu(id); err != nil {
+		return menuError(c, err, "delete menu")
+	}
+
+	return v1.JSONResponse(c, respcode.SUC_CODE_200, "Menu deleted successfully.", http.StatusOK)
+}
+
+// GetRoleMenus lists the menus assigned directly to a role.
+func GetRoleMenus(c fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest)
+	}
+
+	menus, err := scpRbac.GetRoleMenus(roleID)
+	if err != nil {
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(c, respcode.ERR_CODE_404, "Role not found.", http.StatusNotFound)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to fetch role menus.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Role menus fetched successfully.", menus, http.StatusOK)
+}
+
+// ReplaceRoleMenus sets the menus assigned to a role to exactly menu_ids.
+func ReplaceRoleMenus(c fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest)
+	}
+
+	var req mdlRbac.RoleMenusRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid request body.", http.StatusBadRequest)
+	}
+	for _, id := range req.MenuIDs {
+		if id <= 0 {
+			return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid menu ID.", http.StatusBadRequest)
+		}
+	}
+
+	if err := scpRbac.ReplaceRoleMenus(roleID, req.MenuIDs); err != nil {
+		return menuError(c, err, "assign role menus")
+	}
+
+	return v1.JSONResponse(c, respcode.SUC_CODE_200, "Role menus updated successfully.", http.StatusOK)
+}
+
+// GetMyMenus returns the menu tree of the authenticated user for the token's
+// institution, filtered by ?system_tag=. A menu shows when it is assigned to
+// one of the user's roles in force (or a role they inherit from) or when the
+// user satisfies its permission expression; superusers see every menu.
+func GetMyMenus(c fiber.Ctx) error {
+	user := currentUser(c)
+	if user == nil {
+		return v1.JSONResponse(c, respcode.ERR_CODE_111, respcode.ERR_CODE_111_MSG, http.StatusUnauthorized)
+	}
+
+	menus, err := scpRbac.GetMenus(strings.TrimSpace(c.Query("system_tag")))
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to fetch menus.", err, http.StatusInternalServerError,
+		)
+	}
+
+	institutionCode, _ := c.Locals("institution_code").(string)
+	if hlpRbac.IsSuperuserIn(user, institutionCode) {
+		return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Menus fetched successfully.", hlpRbac.MenuTree(menus, nil), http.StatusOK)
+	}
+
+	var roleIDs []int
+	for _, role := range user.Roles {
+		if hlpRbac.RoleApplies(role, institutionCode) {
+			roleIDs = append(roleIDs, role.ID)
+		}
+	}
+	assigned, err := scpRbac.MenuIDsForRoles(roleIDs)
+	if err != nil {
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to fetch menus.", err, http.StatusInternalServerError,
+		)
+	}
+
+	set := hlpRbac.ScopedPermissionSet(user, institutionCode)
+	attrs := hlpRbac.SubjectAttributes(user, institutionCode)
+	for k, v := range hlpRbac.TimeAttributes(time.Now()) {
+		attrs[k] = v
+	}
+
+	tree := hlpRbac.MenuTree(menus, func(menu mdlRbac.Menu) bool {
+		if assigned[menu.MenuID] {
+			return true
+		}
+		if menu.Permission == nil {
+			return false
+		}
+		req, err := hlpRbac.ParseRequirement(*menu.Permission)
+		if err != nil {
+			log.Printf("Hiding menu %s with invalid permission: %v", menu.Slug, err)
+			return false
+		}
+		return hlpRbac.Evaluate(req, set, attrs).Allowed
+	})
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Menus fetched successfully.", tree, http.StatusOK)
+}
+
+// ----------------------------
+//  IMPACT PREVIEWS
+// ----------------------------
+
+// impactResponse reports a previewed change, or the reason it cannot be made.
+func impactResponse(c fiber.Ctx, change string, snapshot *scpRbac.ImpactSnapshot, err error) error {
+	if err != nil {
+		if errors.Is(err, errRbac.ErrResourceNotFound) {
+			return v1.JSONResponse(c, respcode.ERR_CODE_404, "Preview target not found.", http.StatusNotFound)
+		}
+		if errors.Is(err, errRbac.ErrSoDViolation) {
+			return v1.JSONResponseWithError(c, respcode.ERR_CODE_409, "Separation-of-duties rule violated.", err, http.StatusConflict)
+		}
+		return v1.JSONResponseWithError(
+			c, respcode.ERR_CODE_500, "Failed to preview impact.", err, http.StatusInternalServerError,
+		)
+	}
+
+	return v1.JSONResponseWithData(c, respcode.SUC_CODE_200, "Impact previewed successfully.", hlpRbac.Impact(change, snapshot), http.StatusOK)
+}
+
+// PreviewDeleteAction shows who would lose what if the action were archived.
+func PreviewDeleteAction(c fiber.Ctx) error {
+	actionID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || actionID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid action ID.", http.StatusBadRequest)
+	}
+
+	snapshot, err := scpRbac.PreviewDeleteAction(actionID)
+	return impactResponse(c, fmt.Sprintf("delete action %d", actionID), snapshot, err)
+}
+
+// PreviewDeleteResource shows who would lose what if the resource were archived.
+func PreviewDeleteResource(c fiber.Ctx) error {
+	resourceID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || resourceID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid resource ID.", http.StatusBadRequest)
+	}
+
+	snapshot, err := scpRbac.PreviewDeleteResource(resourceID)
+	return impactResponse(c, fmt.Sprintf("delete resource %d", resourceID), snapshot, err)
+}
+
+// PreviewDeleteRole shows who would lose what if the role were archived.
+func PreviewDeleteRole(c fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest)
+	}
+
+	snapshot, err := scpRbac.PreviewDeleteRole(roleID)
+	return impactResponse(c, fmt.Sprintf("delete role %d", roleID), snapshot, err)
+}
+
+// PreviewRemoveRolePermission shows who would lose what if
+// ?action=&resource= were removed from the role.
+func PreviewRemoveRolePermission(c fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid role ID.", http.StatusBadRequest)
+	}
+
+	actionName := strings.TrimSpace(c.Query("action"))
+	resourceName := strings.TrimSpace(c.Query("resource"))
+	if actionName == "" || resourceName == "" {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Missing action or resource.", http.StatusBadRequest)
+	}
+
+	snapshot, err := scpRbac.PreviewRemoveRolePermission(roleID, actionName, resourceName)
+	return impactResponse(c, fmt.Sprintf("remove %s:%s from role %d", actionName, resourceName, roleID), snapshot, err)
+}
+
+// PreviewAssignUserRole shows what the user would lose if their primary role
+// were replaced by the given role.
+func PreviewAssignUserRole(c fiber.Ctx) error {
+	staffID := c.Params("staffId")
+	roleID, err := strconv.Atoi(c.Params("roleId"))
+	if err != nil || roleID <= 0 || staffID == "" {
+		return v1.JSONResponse(c, respcode.ERR_CODE_400, "Invalid staff ID or role ID.", http.StatusBadRequest)
+	}
+
+	snapshot, err := scpRbac.PreviewAssignUserRole(staffID, roleID)
+	return impactResponse(c, fmt.Sprintf("assign role %d to staff %s", roleID, staffID), snapshot, err)
+}
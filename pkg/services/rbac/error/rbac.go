@@ -6,4 +6,13 @@ var (
 	ErrResourceNotFound  = errors.New("resource not found")
 	ErrResourceInUse     = errors.New("resource is in use")
 	ErrResourceNameTaken = errors.New("resource name is already in use")
+	ErrRoleCycle         = errors.New("role hierarchy would contain a cycle")
+	ErrRoleAssigned      = errors.New("role is already assigned")
+	ErrRequestStatus     = errors.New("request status does not allow this change")
+	ErrSelfApproval      = errors.New("requesters cannot decide their own request")
+	ErrSoDViolation      = errors.New("separation-of-duties rule violated")
+	ErrMenuCycle         = errors.New("menu tree would contain a cycle")
+	ErrSuperuserDenied   = errors.New("granting or changing a superuser role requires update:superuser")
 )
+
+var ErrInvalidExpression = errors.New("invalid expression")
@@ -0,0 +1,495 @@
+package hlpRbac
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	errRbac "go_template_v3/pkg/services/rbac/error"
+)
+
+// ============================================
+// GRANT CONDITIONS
+// ============================================
+//
+// A condition is a boolean expression stored on a role_permissions row, e.g.
+//
+//	resource.branch == subject.branch
+//	request.hour >= 8 && request.hour < 17
+//	subject.institution in ['9869', '2546'] && !(resource.owner == '')
+//
+// Operators: || && ! == != < <= > >= in, parentheses and [a, b] lists.
+// Literals: 'text', "text", numbers, true, false, null. Identifiers are
+// attribute names looked up in Attributes; unknown attributes are null, and
+// any comparison involving null is false, == and != included, so a missing
+// attribute never satisfies a condition. A condition that reads resource.*
+// attributes is not met at all when the caller supplied no resource.
+
+// resourcePrefix marks the attributes of the record being acted on.
+const resourcePrefix = "resource."
+
+// Attributes are the values a condition can read, keyed by dotted name such
+// as "subject.institution", "request.hour" or "resource.owner".
+type Attributes map[string]interface{}
+
+// Merge returns a copy of a with every key of b added under prefix.
+func (a Attributes) Merge(prefix string, b map[string]interface{}) Attributes {
+	out := make(Attributes, len(a)+len(b))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		out[prefix+k] = v
+	}
+	return out
+}
+
+// hasResource reports whether any resource.* attribute was supplied.
+func (a Attributes) hasResource() bool {
+	for k := range a {
+		if strings.HasPrefix(k, resourcePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Condition is a compiled condition expression.
+type Condition struct {
+	source string
+	root   condNode
+	// readsResource is set when the expression names a resource.* attribute
+	readsResource bool
+}
+
+func (c *Condition) String() string { return c.source }
+
+// Eval reports whether the condition holds for the attributes. Route-level
+// checks carry no resource, so a condition about the resource cannot hold
+// there, even one such as !(resource.owner == subject.username).
+func (c *Condition) Eval(attrs Attributes) bool {
+	if c.readsResource && !attrs.hasResource() {
+		return false
+	}
+	return truthy(c.root.eval(attrs))
+}
+
+var compiledConditions sync.Map // source -> *Condition
+
+// CompileCondition parses a condition, reusing earlier compilations.
+func CompileCondition(source string) (*Condition, error) {
+	source = strings.TrimSpace(source)
+	if cached, ok := compiledConditions.Load(source); ok {
+		return cached.(*Condition), nil
+	}
+
+	tokens, err := tokenizeCondition(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &condParser{source: source, tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+
+	cond := &Condition{source: source, root: root}
+	for _, tok := range tokens {
+		if tok.kind == tokIdent && strings.HasPrefix(tok.text, resourcePrefix) {
+			cond.readsResource = true
+		}
+	}
+	compiledConditions.Store(source, cond)
+	return cond, nil
+}
+
+// ----------------------------
+// Evaluation
+// ----------------------------
+
+type condNode interface {
+	eval(attrs Attributes) interface{}
+}
+
+type literalNode struct{ value interface{} }
+
+func (n literalNode) eval(Attributes) interface{} { return n.value }
+
+type attrNode struct{ name string }
+
+func (n attrNode) eval(attrs Attributes) interface{} { return normalize(attrs[n.name]) }
+
+type listNode struct{ items []condNode }
+
+func (n listNode) eval(attrs Attributes) interface{} {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		values[i] = item.eval(attrs)
+	}
+	return values
+}
+
+type notNode struct{ inner condNode }
+
+func (n notNode) eval(attrs Attributes) interface{} { return !truthy(n.inner.eval(attrs)) }
+
+type logicalNode struct {
+	op          string
+	left, right condNode
+}
+
+func (n logicalNode) eval(attrs Attributes) interface{} {
+	left := truthy(n.left.eval(attrs))
+	if n.op == "&&" {
+		return left && truthy(n.right.eval(attrs))
+	}
+	return left || truthy(n.right.eval(attrs))
+}
+
+type compareNode struct {
+	op          string
+	left, right condNode
+}
+
+func (n compareNode) eval(attrs Attributes) interface{} {
+	left, right := n.left.eval(attrs), n.right.eval(attrs)
+
+	switch n.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return left != nil && right != nil && !equal(left, right)
+	case "in":
+		list, ok := right.([]interface{})
+		if !ok || left == nil {
+			return false
+		}
+		for _, item := range list {
+			if equal(left, item) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if left == nil || right == nil {
+		return false
+	}
+
+	var cmp int
+	lf, lok := left.(float64)
+	rf, rok := right.(float64)
+	switch {
+	case lok && rok:
+		cmp = compareFloat(lf, rf)
+	default:
+		cmp = strings.Compare(fmt.Sprint(left), fmt.Sprint(right))
+	}
+
+	switch n.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default: // ">="
+		return cmp >= 0
+	}
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// equal compares two non-null values; null is never equal to anything, not
+// even null.
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if af, ok := a.(float64); ok {
+		if bf, ok := b.(float64); ok {
+			return af == bf
+		}
+	}
+	if ab, ok := a.(bool); ok {
+		if bb, ok := b.(bool); ok {
+			return ab == bb
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func truthy(v interface{}) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
+
+// normalize maps attribute values onto the few types the evaluator knows:
+// nil, bool, float64, string and []interface{}.
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil, bool, float64, string:
+		return value
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case int32:
+		return float64(value)
+	case float32:
+		return float64(value)
+	case *string:
+		if value == nil {
+			return nil
+		}
+		return *value
+	case []string:
+		out := make([]interface{}, len(value))
+		for i, s := range value {
+			out[i] = s
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, item := range value {
+			out[i] = normalize(item)
+		}
+		return out
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// ----------------------------
+// Parsing
+// ----------------------------
+
+type condTokenKind int
+
+const (
+	tokIdent condTokenKind = iota
+	tokString
+	tokNumber
+	tokOp
+)
+
+type condToken struct {
+	kind condTokenKind
+	text string
+	pos  int
+}
+
+func tokenizeCondition(source string) ([]condToken, error) {
+	var tokens []condToken
+	for i := 0; i < len(source); {
+		ch := rune(source[i])
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+
+		case ch == '\'' || ch == '"':
+			end := strings.IndexRune(source[i+1:], ch)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated string at position %d in %q", errRbac.ErrInvalidExpression, i, source)
+			}
+			tokens = append(tokens, condToken{kind: tokString, text: source[i+1 : i+1+end], pos: i})
+			i += end + 2
+
+		case unicode.IsDigit(ch) || (ch == '-' && i+1 < len(source) && unicode.IsDigit(rune(source[i+1]))):
+			start := i
+			i++
+			for i < len(source) && (unicode.IsDigit(rune(source[i])) || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, condToken{kind: tokNumber, text: source[start:i], pos: start})
+
+		case unicode.IsLetter(ch) || ch == '_':
+			start := i
+			for i < len(source) && (unicode.IsLetter(rune(source[i])) || unicode.IsDigit(rune(source[i])) || source[i] == '_' || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, condToken{kind: tokIdent, text: source[start:i], pos: start})
+
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","} {
+				if strings.HasPrefix(source[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("%w: unexpected %q at position %d in %q", errRbac.ErrInvalidExpression, ch, i, source)
+			}
+			tokens = append(tokens, condToken{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+type condParser struct {
+	source string
+	tokens []condToken
+	pos    int
+}
+
+func (p *condParser) errorf(format string, args ...interface{}) error {
+	at := len(p.source)
+	if p.pos < len(p.tokens) {
+		at = p.tokens[p.pos].pos
+	}
+	return fmt.Errorf("%w: %s at position %d in %q", errRbac.ErrInvalidExpression, fmt.Sprintf(format, args...), at, p.source)
+}
+
+func (p *condParser) peekOp(ops ...string) string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	tok := p.tokens[p.pos]
+	for _, op := range ops {
+		if (tok.kind == tokOp && tok.text == op) || (op == "in" && tok.kind == tokIdent && tok.text == "in") {
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *condParser) parseOr() (condNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOp("||") != "" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseAnd() (condNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOp("&&") != "" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *condParser) parseUnary() (condNode, error) {
+	if p.peekOp("!") != "" {
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner: inner}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *condParser) parseComparison() (condNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if op := p.peekOp("==", "!=", "<=", ">=", "<", ">", "in"); op != "" {
+		p.pos++
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *condParser) parseOperand() (condNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, p.errorf("expected a value")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.kind {
+	case tokString:
+		return literalNode{value: tok.text}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			p.pos--
+			return nil, p.errorf("invalid number %q", tok.text)
+		}
+		return literalNode{value: n}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+		return attrNode{name: tok.text}, nil
+	}
+
+	switch tok.text {
+	case "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peekOp(")") == "" {
+			return nil, p.errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return inner, nil
+
+	case "[":
+		var items []condNode
+		for p.peekOp("]") == "" {
+			item, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if p.peekOp(",") == "" {
+				break
+			}
+			p.pos++
+		}
+		if p.peekOp("]") == "" {
+			return nil, p.errorf("missing closing bracket")
+		}
+		p.pos++
+		return listNode{items: items}, nil
+	}
+
+	p.pos--
+	return nil, p.errorf("unexpected %q", tok.text)
+}
@@ -0,0 +1,236 @@
+package hlpRbac
+
+import (
+	"errors"
+	"testing"
+
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+	errRbac "go_template_v3/pkg/services/rbac/error"
+)
+
+// evalCondition compiles and evaluates expr, failing the test on a parse error.
+func evalCondition(t *testing.T, expr string, attrs Attributes) bool {
+	t.Helper()
+	cond, err := CompileCondition(expr)
+	if err != nil {
+		t.Fatalf("CompileCondition(%q): %v", expr, err)
+	}
+	return cond.Eval(attrs)
+}
+
+func TestConditionPrecedence(t *testing.T) {
+	attrs := Attributes{"t": true, "f": false}
+
+	for expr, want := range map[string]bool{
+		"t || f && f":   true, // && binds tighter than ||
+		"(t || f) && f": false,
+		"!f && f":       false, // ! binds tighter than &&
+		"!(f && f)":     true,
+		"!!t":           true,
+		"f || f || t":   true,
+	} {
+		if got := evalCondition(t, expr, attrs); got != want {
+			t.Errorf("%s = %v, want %v", expr, got, want)
+		}
+	}
+}
+
+func TestConditionAttributeTypes(t *testing.T) {
+	branch := "MNL"
+	attrs := Attributes{
+		"subject.institution": "9869",
+		"subject.branch":      &branch,
+		"subject.roles":       []string{"teller", "auditor"},
+		"request.hour":        9,
+		"request.weekday":     int64(3),
+		"resource.amount":     1500.5,
+		"resource.tags":       []interface{}{"vip", 7},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"subject.branch == 'MNL'", true},
+		{`subject.branch == "MNL"`, true},
+		{"request.hour >= 8 && request.hour < 17", true},
+		{"request.weekday == 3", true},
+		{"resource.amount > 1000 && resource.amount > -1", true},
+		// Numbers compare numerically, even where the text would not
+		{"request.hour < 10", true},
+		// Mixed types fall back to comparing text
+		{"request.hour == '9'", true},
+		{"subject.institution < 'A'", true},
+		{"'auditor' in subject.roles", true},
+		{"7 in resource.tags", true},
+		{"request.hour in [8, 9, 10]", true},
+		{"subject.institution in []", false},
+		{"'9869' in subject.institution", false},
+		// A bare attribute is only true when it is the boolean true
+		{"subject.institution", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if got := evalCondition(t, tt.expr, attrs); got != tt.want {
+				t.Fatalf("Eval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionNulls(t *testing.T) {
+	attrs := Attributes{
+		"resource.owner": "",
+		"resource.nil":   (*string)(nil),
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// Null is not equal to anything, not even null
+		{"missing == null", false},
+		{"missing != null", false},
+		{"resource.nil == null", false},
+		{"resource.owner == null", false},
+		{"resource.owner == ''", true},
+		{"missing == 'x'", false},
+		{"missing != 'x'", false},
+		{"resource.nil != 'x'", false},
+		// Ordering against null is false both ways, so negating it is true
+		{"missing < 5", false},
+		{"missing >= 5", false},
+		{"!(missing < 5)", true},
+		{"missing in [null]", false},
+		{"'x' in [missing]", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if got := evalCondition(t, tt.expr, attrs); got != tt.want {
+				t.Fatalf("Eval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionMissingAttributes(t *testing.T) {
+	// What RequirePermission supplies: subject and request, no resource and
+	// no subject.branch unless a middleware set it
+	routeLevel := Attributes{
+		"subject.username":    "jdoe",
+		"subject.institution": "9869",
+		"request.method":      "PUT",
+	}
+	withResource := routeLevel.Merge("resource.", map[string]interface{}{"branch": "MNL"})
+
+	tests := []struct {
+		expr  string
+		attrs Attributes
+		want  bool
+	}{
+		{"resource.branch == subject.branch", routeLevel, false},
+		{"resource.branch != subject.branch", routeLevel, false},
+		// The resource is there but the subject's branch is not
+		{"resource.branch == subject.branch", withResource, false},
+		{"resource.branch != subject.branch", withResource, false},
+		// A negated resource check must not turn a missing resource into a pass
+		{"!(resource.owner == subject.username)", routeLevel, false},
+		{"!(resource.owner == subject.username)", withResource, true},
+		{"resource.owner == null || subject.institution == '9869'", routeLevel, false},
+		// Conditions that never mention the resource still apply at route level
+		{"subject.institution == '9869' && request.method == 'PUT'", routeLevel, true},
+		{"subject.branch != 'CEB'", routeLevel, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if got := evalCondition(t, tt.expr, tt.attrs); got != tt.want {
+				t.Fatalf("Eval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileConditionErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"a ==",
+		"(a == 1",
+		"a in [1, 2",
+		"a == 'open",
+		"a == 1.2.3",
+		"a # b",
+		"a b",
+		"&& a",
+		"a == )",
+	} {
+		if _, err := CompileCondition(expr); !errors.Is(err, errRbac.ErrInvalidExpression) {
+			t.Errorf("CompileCondition(%q) err = %v, want ErrInvalidExpression", expr, err)
+		}
+	}
+}
+
+func TestCompileConditionCaches(t *testing.T) {
+	a, err := CompileCondition("request.hour < 17")
+	if err != nil {
+		t.Fatalf("CompileCondition: %v", err)
+	}
+	if b, _ := CompileCondition("  request.hour < 17 "); a != b {
+		t.Fatalf("equal sources compiled twice")
+	}
+}
+
+func TestPermissionSetConditionalMatch(t *testing.T) {
+	sameBranch, err := CompileCondition("resource.branch == subject.branch")
+	if err != nil {
+		t.Fatalf("CompileCondition: %v", err)
+	}
+	set := NewPermissionSet([]string{"view:loan"})
+	set.Add("update:loan", sameBranch)
+	set.Add("*:loan", nil)
+
+	// An unconditional wildcard wins over a conditional exact grant
+	if grant, cond, ok := set.Match("update:loan", nil); !ok || grant != "*:loan" || cond != nil {
+		t.Fatalf("Match = %q, %v, %v; want the unconditional *:loan", grant, cond, ok)
+	}
+
+	set = NewPermissionSet(nil)
+	set.Add("update:loan", sameBranch)
+	attrs := Attributes{"subject.branch": "MNL", "resource.branch": "MNL"}
+
+	if set.Has("update:loan") {
+		t.Fatalf("Has counted a conditional grant as unconditional")
+	}
+	if _, cond, ok := set.Match("update:loan", attrs); !ok || cond != sameBranch {
+		t.Fatalf("Match did not return the condition that held")
+	}
+	if _, _, ok := set.Match("update:loan", attrs.Merge("resource.", map[string]interface{}{"branch": "CEB"})); ok {
+		t.Fatalf("Match ignored a failing condition")
+	}
+	if got := set.Conditional("update:loan"); len(got) != 1 || got[0] != "update:loan if resource.branch == subject.branch" {
+		t.Fatalf("Conditional = %v", got)
+	}
+}
+
+func TestScopedPermissionSetConditions(t *testing.T) {
+	sameBranch, broken, blank := "resource.branch == subject.branch", "resource.branch ==", "  "
+	user := &mdlAuth.UserWithPermissions{Grants: []mdlAuth.PermissionGrant{
+		{Permission: "view:loan", Condition: &sameBranch},
+		{Permission: "update:loan", Condition: &broken},
+		{Permission: "delete:loan", Condition: &blank},
+	}}
+	set := ScopedPermissionSet(user, "9869")
+
+	if set.Has("view:loan") || len(set.Conditional("view:loan")) != 1 {
+		t.Fatalf("conditional grant: Has = %v, Conditional = %v", set.Has("view:loan"), set.Conditional("view:loan"))
+	}
+	// A condition that no longer parses drops the grant instead of widening it
+	if _, _, ok := set.Match("update:loan", Attributes{}); ok || len(set.Conditional("update:loan")) != 0 {
+		t.Fatalf("grant with an invalid condition was kept")
+	}
+	if !set.Has("delete:loan") {
+		t.Fatalf("blank condition was not treated as unconditional")
+	}
+}
@@ -0,0 +1,138 @@
+package hlpRbac
+
+import (
+	"sort"
+	"time"
+
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+	mdlRbac "go_template_v3/pkg/services/rbac/model"
+	scpRbac "go_template_v3/pkg/services/rbac/script"
+)
+
+// ============================================
+// IMPACT ANALYSIS
+// ============================================
+
+// Impact turns a previewed change into the users who would lose permissions,
+// superuser status or registered routes, one entry per user and institution.
+// Conditional grants count as held, so a route is only reported lost when no
+// condition could grant it any more.
+func Impact(change string, snapshot *scpRbac.ImpactSnapshot) mdlRbac.ImpactReport {
+	report := mdlRbac.ImpactReport{
+		Change: change,
+		Roles:  snapshot.Roles,
+		Users:  []mdlRbac.ImpactUser{},
+		Routes: []mdlRbac.ImpactRoute{},
+	}
+
+	routes, requirements := guardedRoutes()
+	lostRoutes := map[int]bool{}
+
+	for i, before := range snapshot.Before {
+		after := snapshot.After[i]
+		for _, institution := range impactInstitutions(before, after) {
+			code := ""
+			if institution != nil {
+				code = *institution
+			}
+			beforeSet := reachablePermissions(before, code)
+			afterSet := reachablePermissions(after, code)
+
+			user := mdlRbac.ImpactUser{
+				StaffID:         before.StaffID,
+				Username:        before.Username,
+				InstitutionCode: institution,
+				LostPermissions: []string{},
+				LostSuperuser:   IsSuperuserIn(before, code) && !IsSuperuserIn(after, code),
+				LostRoutes:      []mdlRbac.ImpactRoute{},
+			}
+			for grant := range beforeSet {
+				if _, ok := afterSet[grant]; !ok {
+					user.LostPermissions = append(user.LostPermissions, grant)
+				}
+			}
+			sort.Strings(user.LostPermissions)
+
+			for j, req := range requirements {
+				if Decide(before, code, beforeSet, req, nil).Allowed && !Decide(after, code, afterSet, req, nil).Allowed {
+					user.LostRoutes = append(user.LostRoutes, routes[j])
+					lostRoutes[j] = true
+				}
+			}
+
+			if len(user.LostPermissions) > 0 || user.LostSuperuser || len(user.LostRoutes) > 0 {
+				report.Users = append(report.Users, user)
+			}
+		}
+	}
+
+	for j, route := range routes {
+		if lostRoutes[j] {
+			report.Routes = append(report.Routes, route)
+		}
+	}
+	return report
+}
+
+// guardedRoutes returns the registered routes that require permissions,
+// with their parsed requirements.
+func guardedRoutes() ([]mdlRbac.ImpactRoute, []Requirement) {
+	var routes []mdlRbac.ImpactRoute
+	var requirements []Requirement
+	for _, route := range RegisteredRoutes() {
+		if route.Access != mdlRbac.RouteAccessPermission {
+			continue
+		}
+		// Registered requirements were parsed once already
+		req, err := ParseRequirement(route.Requirement)
+		if err != nil {
+			continue
+		}
+		routes = append(routes, mdlRbac.ImpactRoute{Method: route.Method, Path: route.Path, Requirement: route.Requirement})
+		requirements = append(requirements, req)
+	}
+	return routes, requirements
+}
+
+// impactInstitutions lists the institutions the users' assignments are
+// scoped to, plus nil for every other institution.
+func impactInstitutions(users ...*mdlAuth.UserWithPermissions) []*string {
+	seen := map[string]bool{}
+	for _, user := range users {
+		for _, role := range user.Roles {
+			if role.InstitutionCode != nil {
+				seen[*role.InstitutionCode] = true
+			}
+		}
+		for _, grant := range user.Grants {
+			if grant.InstitutionCode != nil {
+				seen[*grant.InstitutionCode] = true
+			}
+		}
+	}
+
+	codes := make([]string, 0, len(seen))
+	for code := range seen {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	institutions := []*string{nil}
+	for i := range codes {
+		institutions = append(institutions, &codes[i])
+	}
+	return institutions
+}
+
+// reachablePermissions is ScopedPermissionSet with every condition dropped:
+// the grants that could apply to the institution right now.
+func reachablePermissions(user *mdlAuth.UserWithPermissions, institutionCode string) PermissionSet {
+	now := time.Now()
+	set := make(PermissionSet, len(user.Grants))
+	for _, grant := range user.Grants {
+		if inScope(grant.InstitutionCode, institutionCode) && inWindow(grant.ValidFrom, grant.ValidUntil, now) {
+			set.Add(grant.Permission, nil)
+		}
+	}
+	return set
+}
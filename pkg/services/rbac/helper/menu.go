@@ -0,0 +1,65 @@
+package hlpRbac
+
+import (
+	mdlRbac "go_template_v3/pkg/services/rbac/model"
+)
+
+// ============================================
+// MENU TREE
+// ============================================
+
+// MenuTree nests a flat, display-ordered list of menus under their parents.
+// When visible is not nil only the menus it accepts are kept, together with
+// their ancestors so every visible menu is reachable from a root. A menu
+// whose parent is not in the list becomes a root.
+func MenuTree(menus []mdlRbac.Menu, visible func(mdlRbac.Menu) bool) []mdlRbac.Menu {
+	byID := make(map[int]mdlRbac.Menu, len(menus))
+	for _, menu := range menus {
+		byID[menu.MenuID] = menu
+	}
+
+	keep := make(map[int]bool, len(menus))
+	for _, menu := range menus {
+		if visible != nil && !visible(menu) {
+			continue
+		}
+		for id := menu.MenuID; !keep[id]; {
+			keep[id] = true
+			parent, ok := byID[id]
+			if !ok || parent.ParentID == nil {
+				break
+			}
+			if _, ok := byID[*parent.ParentID]; !ok {
+				break
+			}
+			id = *parent.ParentID
+		}
+	}
+
+	children := map[int][]mdlRbac.Menu{}
+	var roots []mdlRbac.Menu
+	for _, menu := range menus {
+		if !keep[menu.MenuID] {
+			continue
+		}
+		if menu.ParentID != nil && keep[*menu.ParentID] {
+			children[*menu.ParentID] = append(children[*menu.ParentID], menu)
+		} else {
+			roots = append(roots, menu)
+		}
+	}
+
+	var attach func([]mdlRbac.Menu) []mdlRbac.Menu
+	attach = func(level []mdlRbac.Menu) []mdlRbac.Menu {
+		for i := range level {
+			level[i].Children = attach(children[level[i].MenuID])
+		}
+		return level
+	}
+
+	tree := attach(roots)
+	if tree == nil {
+		tree = []mdlRbac.Menu{}
+	}
+	return tree
+}
@@ -0,0 +1,92 @@
+package hlpRbac
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	mdlRbac "go_template_v3/pkg/services/rbac/model"
+)
+
+// menu builds a menu; parent 0 means a root.
+func menu(id, parent int, slug string) mdlRbac.Menu {
+	m := mdlRbac.Menu{MenuID: id, Slug: slug}
+	if parent != 0 {
+		m.ParentID = &parent
+	}
+	return m
+}
+
+// outline renders a tree as "admin(roles(role-detail) users) reports".
+func outline(menus []mdlRbac.Menu) string {
+	parts := make([]string, len(menus))
+	for i, m := range menus {
+		parts[i] = m.Slug
+		if len(m.Children) > 0 {
+			parts[i] += "(" + outline(m.Children) + ")"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func slugs(names ...string) func(mdlRbac.Menu) bool {
+	return func(m mdlRbac.Menu) bool {
+		for _, name := range names {
+			if m.Slug == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Display order as the query returns it: parents are not necessarily first.
+var sampleMenus = []mdlRbac.Menu{
+	menu(3, 2, "role-detail"),
+	menu(1, 0, "admin"),
+	menu(2, 1, "roles"),
+	menu(4, 1, "users"),
+	menu(5, 0, "reports"),
+}
+
+func TestMenuTreeKeepsDisplayOrder(t *testing.T) {
+	got := outline(MenuTree(append([]mdlRbac.Menu(nil), sampleMenus...), nil))
+	if want := "admin(roles(role-detail) users) reports"; got != want {
+		t.Fatalf("MenuTree = %q, want %q", got, want)
+	}
+}
+
+func TestMenuTreeVisibility(t *testing.T) {
+	tests := []struct {
+		visible []string
+		want    string
+	}{
+		// A visible leaf pulls in its hidden ancestors so it stays reachable
+		{[]string{"role-detail"}, "admin(roles(role-detail))"},
+		// A visible parent does not reveal its hidden children
+		{[]string{"admin", "reports"}, "admin reports"},
+		{[]string{"users", "reports"}, "admin(users) reports"},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.visible), func(t *testing.T) {
+			tree := MenuTree(append([]mdlRbac.Menu(nil), sampleMenus...), slugs(tt.visible...))
+			if tree == nil {
+				t.Fatalf("MenuTree returned nil; it must encode as []")
+			}
+			if got := outline(tree); got != tt.want {
+				t.Fatalf("MenuTree = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMenuTreeOrphans(t *testing.T) {
+	// The parent is archived or outside the user's menus
+	menus := []mdlRbac.Menu{menu(6, 99, "orphan"), menu(7, 6, "orphan-child")}
+
+	if got := outline(MenuTree(menus, slugs("orphan-child"))); got != "orphan(orphan-child)" {
+		t.Fatalf("MenuTree = %q, want the orphan as a root", got)
+	}
+}
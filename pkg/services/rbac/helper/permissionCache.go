@@ -0,0 +1,137 @@
+package hlpRbac
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go_template_v3/pkg/global/cache"
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+	scpAuth "go_template_v3/pkg/services/auth/script"
+
+	utils_v1 "github.com/FDSAP-Git-Org/hephaestus/utils/v1"
+)
+
+// ============================================
+// EFFECTIVE PERMISSION CACHE
+// ============================================
+
+const (
+	defaultPermissionCacheTTL = 5 * time.Minute
+	rbacVersionKey            = "rbac:version"
+	permissionKeyPrefix       = "rbac:perm:"
+)
+
+var (
+	permissionStore     cache.Store
+	permissionStoreOnce sync.Once
+)
+
+// PermissionCacheTTL reads PERMISSION_CACHE_TTL (in seconds). A value of 0
+// disables the cache.
+func PermissionCacheTTL() time.Duration {
+	raw := utils_v1.GetEnv("PERMISSION_CACHE_TTL")
+	if raw == "" {
+		return defaultPermissionCacheTTL
+	}
+	secs, err := strconv.Atoi(raw)
+	if err != nil || secs < 0 {
+		return defaultPermissionCacheTTL
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// store lazily builds the backend picked by PERMISSION_CACHE_BACKEND (memory
+// or redis). Use redis when running more than one instance, otherwise a
+// version bump on one instance is not seen by the others.
+func store() cache.Store {
+	permissionStoreOnce.Do(func() {
+		permissionStore = cache.NewStore(utils_v1.GetEnv("PERMISSION_CACHE_BACKEND"))
+	})
+	return permissionStore
+}
+
+// Version returns the current global RBAC version. Every cached entry is
+// keyed by the version it was loaded under, so bumping it orphans them all.
+func Version(ctx context.Context) (int64, error) {
+	raw, ok, err := store().Get(ctx, rbacVersionKey)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.ParseInt(string(raw), 10, 64)
+}
+
+// BumpVersion invalidates every cached permission set. Call it after any
+// RBAC mutation has committed.
+func BumpVersion(ctx context.Context) {
+	if _, err := store().Incr(ctx, rbacVersionKey); err != nil {
+		log.Printf("RBAC version bump failed: %v", err)
+	}
+}
+
+func permissionKey(version int64, username string) string {
+	return permissionKeyPrefix + strconv.FormatInt(version, 10) + ":" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// GetUserPermissions returns the user with their effective permissions,
+// loading them from the database on a cache miss.
+func GetUserPermissions(ctx context.Context, username string) (*mdlAuth.UserWithPermissions, error) {
+	ttl := PermissionCacheTTL()
+	if ttl == 0 {
+		return scpAuth.GetUserByUsername(username)
+	}
+
+	version, err := Version(ctx)
+	if err != nil {
+		// Without a trustworthy version a cached entry may be stale
+		log.Printf("RBAC version read failed: %v", err)
+		return scpAuth.GetUserByUsername(username)
+	}
+
+	key := permissionKey(version, username)
+	if raw, ok, err := store().Get(ctx, key); err == nil && ok {
+		var user mdlAuth.UserWithPermissions
+		if err := json.Unmarshal(raw, &user); err == nil {
+			return &user, nil
+		}
+	} else if err != nil {
+		log.Printf("Permission cache read failed: %v", err)
+	}
+
+	user, err := scpAuth.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+
+	if raw, err := json.Marshal(user); err == nil {
+		if err := store().Set(ctx, key, raw, ttl); err != nil {
+			log.Printf("Permission cache write failed: %v", err)
+		}
+	}
+
+	return user, nil
+}
+
+// EvictUserPermissions drops the cached permission set of one user under the
+// current version, e.g. after the user is deleted.
+func EvictUserPermissions(ctx context.Context, usernames ...string) {
+	version, err := Version(ctx)
+	if err != nil {
+		log.Printf("RBAC version read failed: %v", err)
+		return
+	}
+
+	keys := make([]string, 0, len(usernames))
+	for _, username := range usernames {
+		if username != "" {
+			keys = append(keys, permissionKey(version, username))
+		}
+	}
+	if err := store().Delete(ctx, keys...); err != nil {
+		log.Printf("Permission cache eviction failed: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package hlpRbac
+
+import (
+	"sort"
+	"strings"
+)
+
+// ============================================
+// PERMISSION SET
+// ============================================
+
+// Wildcard matches any action or resource in a grant such as "*:role".
+const Wildcard = "*"
+
+// PermissionSet holds a user's grants for constant-time lookups. Grants are
+// "action:resource" strings; either side may be the wildcard. Each grant maps
+// to its conditions, where a nil condition means the grant is unconditional.
+type PermissionSet map[string][]*Condition
+
+// NewPermissionSet builds a set of unconditional grants.
+func NewPermissionSet(grants []string) PermissionSet {
+	set := make(PermissionSet, len(grants))
+	for _, grant := range grants {
+		set.Add(grant, nil)
+	}
+	return set
+}
+
+// Add records a grant, optionally limited by a condition.
+func (s PermissionSet) Add(grant string, cond *Condition) {
+	key := strings.ToLower(strings.TrimSpace(grant))
+	s[key] = append(s[key], cond)
+}
+
+// Has reports whether the set grants the permission without any condition.
+func (s PermissionSet) Has(permission string) bool {
+	_, _, ok := s.Match(permission, nil)
+	return ok
+}
+
+// Match looks for a grant covering the "action:resource" permission, directly
+// or through "*:resource", "action:*" or "*:*". Unconditional grants win;
+// otherwise the first grant whose condition holds for attrs is returned.
+func (s PermissionSet) Match(permission string, attrs Attributes) (string, *Condition, bool) {
+	candidates := grantCandidates(permission)
+
+	for _, grant := range candidates {
+		for _, cond := range s[grant] {
+			if cond == nil {
+				return grant, nil, true
+			}
+		}
+	}
+
+	if attrs == nil {
+		return "", nil, false
+	}
+	for _, grant := range candidates {
+		for _, cond := range s[grant] {
+			if cond != nil && cond.Eval(attrs) {
+				return grant, cond, true
+			}
+		}
+	}
+	return "", nil, false
+}
+
+// Conditional lists the grants that would cover the permission if their
+// condition held.
+func (s PermissionSet) Conditional(permission string) []string {
+	var out []string
+	for _, grant := range grantCandidates(permission) {
+		for _, cond := range s[grant] {
+			if cond != nil {
+				out = append(out, grant+" if "+cond.String())
+			}
+		}
+	}
+	return out
+}
+
+// Nearest lists the grants in the set that come closest to the permission
+// without covering it: grants on the same resource first, then grants of the
+// same action, each group sorted.
+func (s PermissionSet) Nearest(permission string) []string {
+	action, resource, _ := strings.Cut(strings.ToLower(strings.TrimSpace(permission)), ":")
+
+	var sameResource, sameAction []string
+	for grant := range s {
+		grantAction, grantResource, _ := strings.Cut(grant, ":")
+		switch {
+		case grantResource == resource:
+			sameResource = append(sameResource, grant)
+		case grantAction == action:
+			sameAction = append(sameAction, grant)
+		}
+	}
+	sort.Strings(sameResource)
+	sort.Strings(sameAction)
+	return append(sameResource, sameAction...)
+}
+
+func grantCandidates(permission string) []string {
+	permission = strings.ToLower(strings.TrimSpace(permission))
+	action, resource, ok := strings.Cut(permission, ":")
+	if !ok {
+		return []string{permission}
+	}
+	return []string{
+		permission,
+		Wildcard + ":" + resource,
+		action + ":" + Wildcard,
+		Wildcard + ":" + Wildcard,
+	}
+}
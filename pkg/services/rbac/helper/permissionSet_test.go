@@ -0,0 +1,59 @@
+package hlpRbac
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPermissionSetWildcards(t *testing.T) {
+	tests := []struct {
+		grant      string
+		permission string
+		want       bool
+	}{
+		{" View:Role ", "VIEW:role", true},
+		{"*:role", "delete:role", true},
+		{"view:*", "view:exam", true},
+		{"*:*", "delete:exam", true},
+		{"*:exam", "view:role", false},
+		{"update:*", "view:role", false},
+		// A wildcard in the request is literal; only the same grant covers it
+		{"view:role", "*:role", false},
+		{"*:role", "*:role", true},
+		{"*:*", "view", false},
+		// The wildcard matches a whole side, never a prefix
+		{"view*:role", "viewer:role", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.grant+" grants "+tt.permission, func(t *testing.T) {
+			if got := NewPermissionSet([]string{tt.grant}).Has(tt.permission); got != tt.want {
+				t.Fatalf("Has = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermissionSetNearest(t *testing.T) {
+	set := NewPermissionSet([]string{"view:role", "update:role", "view:exam", "delete:menu", "*:office"})
+
+	tests := []struct {
+		permission string
+		want       []string
+	}{
+		// Same resource first, then same action, each sorted
+		{"delete:role", []string{"update:role", "view:role", "delete:menu"}},
+		{" DELETE:Role ", []string{"update:role", "view:role", "delete:menu"}},
+		// A wildcard grant on the resource is the closest of all
+		{"view:office", []string{"*:office", "view:exam", "view:role"}},
+		{"approve:loan", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.permission, func(t *testing.T) {
+			if got := set.Nearest(tt.permission); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Nearest = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
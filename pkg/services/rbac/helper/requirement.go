@@ -0,0 +1,320 @@
+package hlpRbac
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+	errRbac "go_template_v3/pkg/services/rbac/error"
+)
+
+// ============================================
+// PERMISSION REQUIREMENTS
+// ============================================
+
+// Requirement is a condition over a PermissionSet, built from single
+// permissions combined with all/any/not.
+type Requirement interface {
+	eval(e *evaluation) bool
+	String() string
+}
+
+// Reason codes reported in Decision.Code
+const (
+	ReasonGranted            = "GRANTED"
+	ReasonGrantedConditional = "GRANTED_CONDITIONAL"
+	ReasonSuperuser          = "SUPERUSER_BYPASS"
+	ReasonMissingPermission  = "MISSING_PERMISSION"
+	ReasonConditionNotMet    = "CONDITION_NOT_MET"
+	ReasonDenied             = "DENIED"
+	ReasonUserNotFound       = "USER_NOT_FOUND"
+	ReasonInvalidRequirement = "INVALID_REQUIREMENT"
+)
+
+// Decision is the outcome of evaluating a requirement for one user.
+type Decision struct {
+	Allowed       bool     `json:"allowed"`
+	Code          string   `json:"code"`
+	MatchedGrants []string `json:"matched_grants"`
+	Missing       []string `json:"missing,omitempty"`
+	Reason        string   `json:"reason"`
+}
+
+// Evaluate checks req against the set, evaluating conditional grants with attrs.
+func Evaluate(req Requirement, set PermissionSet, attrs Attributes) Decision {
+	e := &evaluation{set: set, attrs: attrs}
+	allowed := req.eval(e)
+
+	decision := Decision{Allowed: allowed, MatchedGrants: e.matched}
+	if !allowed {
+		decision.Missing = e.failed
+	}
+	if decision.MatchedGrants == nil {
+		decision.MatchedGrants = []string{}
+	}
+
+	switch {
+	case allowed && e.conditional:
+		decision.Code = ReasonGrantedConditional
+		decision.Reason = "granted by " + strings.Join(e.matched, ", ")
+	case allowed && len(e.matched) > 0:
+		decision.Code = ReasonGranted
+		decision.Reason = "granted by " + strings.Join(e.matched, ", ")
+	case allowed:
+		decision.Code = ReasonGranted
+		decision.Reason = "requirement " + req.String() + " holds"
+	case len(e.unmetConditions) > 0:
+		decision.Code = ReasonConditionNotMet
+		decision.Reason = "condition not met: " + strings.Join(e.unmetConditions, "; ")
+	case len(e.missing) > 0:
+		decision.Code = ReasonMissingPermission
+		decision.Reason = "missing " + strings.Join(e.missing, ", ")
+	default:
+		decision.Code = ReasonDenied
+		decision.Reason = "requirement " + req.String() + " does not hold"
+	}
+	return decision
+}
+
+// Decide evaluates req against the user's grants at an institution (set is
+// ScopedPermissionSet(user, institutionCode)) and lets superuser roles through
+// when the grants alone would deny. Callers must audit decisions with Code
+// ReasonSuperuser.
+func Decide(user *mdlAuth.UserWithPermissions, institutionCode string, set PermissionSet, req Requirement, attrs Attributes) Decision {
+	decision := Evaluate(req, set, attrs)
+	if decision.Allowed || !IsSuperuserIn(user, institutionCode) {
+		return decision
+	}
+
+	return Decision{
+		Allowed:       true,
+		Code:          ReasonSuperuser,
+		MatchedGrants: []string{},
+		Missing:       decision.Missing,
+		Reason:        "superuser bypass (" + decision.Reason + ")",
+	}
+}
+
+// evaluation records which grants satisfied or failed each permission.
+type evaluation struct {
+	set             PermissionSet
+	attrs           Attributes
+	matched         []string
+	conditional     bool
+	missing         []string
+	unmetConditions []string
+	failed          []string // permissions not granted, with or without a condition
+}
+
+func (e *evaluation) has(permission string) bool {
+	grant, cond, ok := e.set.Match(permission, e.attrs)
+	if ok {
+		if cond != nil {
+			grant += " if " + cond.String()
+			e.conditional = true
+		}
+		e.matched = append(e.matched, grant)
+		return true
+	}
+
+	e.failed = append(e.failed, permission)
+	if conditional := e.set.Conditional(permission); len(conditional) > 0 {
+		e.unmetConditions = append(e.unmetConditions, conditional...)
+	} else {
+		e.missing = append(e.missing, permission)
+	}
+	return false
+}
+
+type permissionReq string
+
+func (r permissionReq) eval(e *evaluation) bool { return e.has(string(r)) }
+func (r permissionReq) String() string          { return string(r) }
+
+type allReq []Requirement
+
+func (r allReq) eval(e *evaluation) bool {
+	for _, req := range r {
+		if !req.eval(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r allReq) String() string { return joinRequirements(r, " && ") }
+
+type anyReq []Requirement
+
+func (r anyReq) eval(e *evaluation) bool {
+	for _, req := range r {
+		if req.eval(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r anyReq) String() string { return joinRequirements(r, " || ") }
+
+type notReq struct{ inner Requirement }
+
+// eval runs the inner requirement on a scratch evaluation so its matches are
+// not reported as the grants that allowed the request.
+func (r notReq) eval(e *evaluation) bool {
+	return !r.inner.eval(&evaluation{set: e.set, attrs: e.attrs})
+}
+
+func (r notReq) String() string { return "!" + r.inner.String() }
+
+func joinRequirements(reqs []Requirement, sep string) string {
+	parts := make([]string, len(reqs))
+	for i, req := range reqs {
+		parts[i] = req.String()
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+// Permission requires a single "action:resource" grant.
+func Permission(permission string) Requirement { return permissionReq(permission) }
+
+// All requires every listed permission.
+func All(permissions ...string) Requirement {
+	reqs := make(allReq, len(permissions))
+	for i, p := range permissions {
+		reqs[i] = permissionReq(p)
+	}
+	return reqs
+}
+
+// Any requires at least one of the listed permissions.
+func Any(permissions ...string) Requirement {
+	reqs := make(anyReq, len(permissions))
+	for i, p := range permissions {
+		reqs[i] = permissionReq(p)
+	}
+	return reqs
+}
+
+// ParseRequirement parses an expression such as
+// "view:role && (update:role || *:role) && !delete:exam".
+// Supported operators are &&, ||, ! and parentheses, with the usual precedence.
+func ParseRequirement(expr string) (Requirement, error) {
+	p := &exprParser{input: expr}
+	req, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected %q", p.input[p.pos:])
+	}
+	return req, nil
+}
+
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at position %d in %q", errRbac.ErrInvalidExpression, fmt.Sprintf(format, args...), p.pos, p.input)
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *exprParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (Requirement, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	reqs := anyReq{left}
+	for p.consume("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, right)
+	}
+	if len(reqs) == 1 {
+		return left, nil
+	}
+	return reqs, nil
+}
+
+func (p *exprParser) parseAnd() (Requirement, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	reqs := allReq{left}
+	for p.consume("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, right)
+	}
+	if len(reqs) == 1 {
+		return left, nil
+	}
+	return reqs, nil
+}
+
+func (p *exprParser) parseUnary() (Requirement, error) {
+	if p.consume("!") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notReq{inner: inner}, nil
+	}
+
+	if p.consume("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("missing closing parenthesis")
+		}
+		return inner, nil
+	}
+
+	return p.parsePermission()
+}
+
+func (p *exprParser) parsePermission() (Requirement, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && isPermissionChar(rune(p.input[p.pos])) {
+		p.pos++
+	}
+
+	permission := p.input[start:p.pos]
+	if permission == "" {
+		return nil, p.errorf("expected a permission")
+	}
+	if action, resource, ok := strings.Cut(permission, ":"); !ok || action == "" || resource == "" {
+		return nil, p.errorf("permission %q must look like action:resource", permission)
+	}
+	return permissionReq(permission), nil
+}
+
+func isPermissionChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.:*", r)
+}
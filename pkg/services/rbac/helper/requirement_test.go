@@ -0,0 +1,224 @@
+package hlpRbac
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+	errRbac "go_template_v3/pkg/services/rbac/error"
+)
+
+func TestParseRequirementPrecedence(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"  view:role  ", "view:role"},
+		// && binds tighter than ||, ! tighter than both
+		{"a:x || b:x && c:x", "(a:x || (b:x && c:x))"},
+		{"(a:x || b:x) && c:x", "((a:x || b:x) && c:x)"},
+		{"!a:x && b:x", "(!a:x && b:x)"},
+		{"!(a:x && b:x)", "!(a:x && b:x)"},
+		{"!!a:x", "!!a:x"},
+		// Chains flatten instead of nesting
+		{"a:x && b:x && c:x", "(a:x && b:x && c:x)"},
+		{"((a:x))", "a:x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			req, err := ParseRequirement(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseRequirement: %v", err)
+			}
+			if got := req.String(); got != tt.want {
+				t.Fatalf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRequirementErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"   ",
+		"view",
+		"view:",
+		":role",
+		"view:role &&",
+		"|| view:role",
+		"(view:role",
+		"view:role)",
+		"()",
+		"view:role & update:role",
+		"view:role update:role",
+		"view role",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := ParseRequirement(expr); !errors.Is(err, errRbac.ErrInvalidExpression) {
+				t.Fatalf("err = %v, want ErrInvalidExpression", err)
+			}
+		})
+	}
+}
+
+func TestEvaluateRequirement(t *testing.T) {
+	set := NewPermissionSet([]string{"view:role", "*:exam"})
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"view:role && delete:exam", true},
+		{"view:role && update:role", false},
+		{"update:role || delete:exam", true},
+		// Negation sees wildcard grants too
+		{"view:role && !delete:exam", false},
+		{"!update:role", true},
+		{"!(update:role || view:role)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			req, err := ParseRequirement(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseRequirement: %v", err)
+			}
+			if got := Evaluate(req, set, nil).Allowed; got != tt.want {
+				t.Fatalf("Allowed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !Evaluate(Any("update:role", "view:role"), set, nil).Allowed || Evaluate(All("view:role", "update:role"), set, nil).Allowed {
+		t.Fatalf("Any/All disagree with the parsed expressions")
+	}
+	// Nothing is required of an empty All; an empty Any can never hold
+	if !Evaluate(All(), nil, nil).Allowed || Evaluate(Any(), set, nil).Allowed {
+		t.Fatalf("empty All/Any misbehave")
+	}
+}
+
+func TestDecisionCodes(t *testing.T) {
+	sameInstitution, err := CompileCondition("resource.institution == subject.institution")
+	if err != nil {
+		t.Fatalf("CompileCondition: %v", err)
+	}
+	set := NewPermissionSet([]string{"view:loan"})
+	set.Add("update:loan", sameInstitution)
+
+	user := &mdlAuth.UserWithPermissions{Username: "jdoe"}
+	subject := SubjectAttributes(user, "9869")
+
+	tests := []struct {
+		expr     string
+		resource map[string]interface{}
+		wantCode string
+	}{
+		{"view:loan", nil, ReasonGranted},
+		{"!delete:loan", nil, ReasonGranted},
+		{"update:loan", map[string]interface{}{"institution": "9869"}, ReasonGrantedConditional},
+		// One conditional grant is enough to mark the whole decision
+		{"view:loan && update:loan", map[string]interface{}{"institution": "9869"}, ReasonGrantedConditional},
+		{"update:loan", map[string]interface{}{"institution": "2546"}, ReasonConditionNotMet},
+		// An unmet condition explains the denial better than a missing grant
+		{"update:loan && delete:loan", map[string]interface{}{"institution": "2546"}, ReasonConditionNotMet},
+		{"delete:loan", nil, ReasonMissingPermission},
+		{"!view:loan", nil, ReasonDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			req, err := ParseRequirement(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseRequirement: %v", err)
+			}
+			got := Decide(user, "9869", set, req, subject.Merge("resource.", tt.resource))
+			if got.Code != tt.wantCode || got.Allowed != (tt.wantCode == ReasonGranted || tt.wantCode == ReasonGrantedConditional) {
+				t.Fatalf("Decide = %v/%s (%s), want %s", got.Allowed, got.Code, got.Reason, tt.wantCode)
+			}
+			if got.MatchedGrants == nil {
+				t.Fatalf("MatchedGrants is nil; it must encode as []")
+			}
+		})
+	}
+}
+
+func TestDecideSuperuserBypass(t *testing.T) {
+	admin := &mdlAuth.UserWithPermissions{
+		Roles:  []mdlAuth.UserRole{{Name: "admin", IsSuperuser: true, InstitutionCode: strPtr("9869")}},
+		Grants: []mdlAuth.PermissionGrant{{Permission: "view:role"}},
+	}
+
+	tests := []struct {
+		expr        string
+		institution string
+		wantAllowed bool
+		wantCode    string
+	}{
+		// Held permissions are reported as granted, not as a bypass
+		{"view:role", "9869", true, ReasonGranted},
+		{"delete:role", "9869", true, ReasonSuperuser},
+		{"!view:role", "9869", true, ReasonSuperuser},
+		// The bypass is limited to the role's institution
+		{"delete:role", "2546", false, ReasonMissingPermission},
+		{"delete:role", "", false, ReasonMissingPermission},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr+"@"+tt.institution, func(t *testing.T) {
+			req, err := ParseRequirement(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseRequirement: %v", err)
+			}
+			got := Decide(admin, tt.institution, ScopedPermissionSet(admin, tt.institution), req, nil)
+			if got.Allowed != tt.wantAllowed || got.Code != tt.wantCode {
+				t.Fatalf("Decide = %v/%s, want %v/%s", got.Allowed, got.Code, tt.wantAllowed, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestDecisionMissing(t *testing.T) {
+	set := NewPermissionSet([]string{"view:role"})
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"view:role && update:role && delete:role", []string{"update:role"}},
+		{"update:role || delete:role", []string{"update:role", "delete:role"}},
+		// A held permission under ! is what failed, but it is not missing
+		{"!view:role", nil},
+		{"view:role", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			req, err := ParseRequirement(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseRequirement: %v", err)
+			}
+			if got := Evaluate(req, set, nil).Missing; !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Missing = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecideConditionWithoutResource(t *testing.T) {
+	sameBranch, err := CompileCondition("resource.branch == subject.branch")
+	if err != nil {
+		t.Fatalf("CompileCondition: %v", err)
+	}
+	set := NewPermissionSet(nil)
+	set.Add("update:loan", sameBranch)
+	user := &mdlAuth.UserWithPermissions{Username: "jdoe"}
+
+	// A route-level check: attributes, but neither a resource nor a branch
+	got := Decide(user, "9869", set, Permission("update:loan"), SubjectAttributes(user, "9869"))
+	if got.Allowed || got.Code != ReasonConditionNotMet {
+		t.Fatalf("Decide = %v/%s, want a denial with %s", got.Allowed, got.Code, ReasonConditionNotMet)
+	}
+}
@@ -0,0 +1,82 @@
+package hlpRbac
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	scpRbac "go_template_v3/pkg/services/rbac/script"
+
+	utils_v1 "github.com/FDSAP-Git-Org/hephaestus/utils/v1"
+)
+
+// ============================================
+// ROLE ASSIGNMENT EXPIRY
+// ============================================
+
+const defaultRoleExpirySweepInterval = time.Minute
+
+var roleExpiryOnce sync.Once
+
+// roleExpirySweepInterval reads ROLE_EXPIRY_SWEEP_INTERVAL (in seconds). A
+// value of 0 disables the sweeper; expired assignments are still ignored at
+// request time, they just stay in user_roles.
+func roleExpirySweepInterval() time.Duration {
+	raw := utils_v1.GetEnv("ROLE_EXPIRY_SWEEP_INTERVAL")
+	if raw == "" {
+		return defaultRoleExpirySweepInterval
+	}
+	secs, err := strconv.Atoi(raw)
+	if err != nil || secs < 0 {
+		return defaultRoleExpirySweepInterval
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// StartRoleExpirySweeper launches the background job that removes expired
+// time-bound role assignments and closes expired access requests. Safe to
+// call more than once.
+func StartRoleExpirySweeper() {
+	roleExpiryOnce.Do(func() {
+		interval := roleExpirySweepInterval()
+		if interval == 0 {
+			fmt.Println("ROLE EXPIRY SWEEPER: DISABLED")
+			return
+		}
+
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				SweepExpiredGrants(context.Background())
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+// SweepExpiredGrants revokes every expired role assignment and access
+// request once, auditing each one. The middleware already ignores them; the
+// version bump just keeps cached permission sets in line with the tables.
+func SweepExpiredGrants(ctx context.Context) {
+	expiredRoles, err := scpRbac.ExpireUserRoles()
+	if err != nil {
+		log.Printf("Role expiry sweep failed: %v", err)
+	}
+
+	expiredRequests, err := scpRbac.ExpireAccessRequests()
+	if err != nil {
+		log.Printf("Access request expiry sweep failed: %v", err)
+	}
+
+	if len(expiredRoles) == 0 && len(expiredRequests) == 0 {
+		return
+	}
+
+	BumpVersion(ctx)
+
+	log.Printf("Revoked %d expired role assignment(s) and %d access request(s)", len(expiredRoles), len(expiredRequests))
+}
@@ -0,0 +1,169 @@
+package hlpRbac
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	mdlRbac "go_template_v3/pkg/services/rbac/model"
+	scpRbac "go_template_v3/pkg/services/rbac/script"
+
+	utils_v1 "github.com/FDSAP-Git-Org/hephaestus/utils/v1"
+)
+
+// ============================================
+// ROUTE PERMISSION REGISTRY
+// ============================================
+
+// Route validation modes, read from ROUTE_VALIDATION_MODE
+const (
+	RouteValidationOff  = "off"
+	RouteValidationWarn = "warn"
+	RouteValidationFail = "fail"
+)
+
+var (
+	routeRegistryMu sync.RWMutex
+	routeRegistry   []mdlRbac.RoutePermission
+)
+
+// RegisterRoute records a route and the requirement guarding it. req is nil
+// for routes that only need a valid token; access tells public routes apart.
+func RegisterRoute(method, path, access string, req Requirement) {
+	route := mdlRbac.RoutePermission{
+		Method:      method,
+		Path:        path,
+		Access:      access,
+		Permissions: []string{},
+	}
+	if req != nil {
+		route.Requirement = req.String()
+		route.Permissions = RequirementPermissions(req)
+	}
+
+	routeRegistryMu.Lock()
+	defer routeRegistryMu.Unlock()
+	routeRegistry = append(routeRegistry, route)
+}
+
+// RegisteredRoutes returns every registered route in registration order.
+func RegisteredRoutes() []mdlRbac.RoutePermission {
+	routeRegistryMu.RLock()
+	defer routeRegistryMu.RUnlock()
+	return append([]mdlRbac.RoutePermission(nil), routeRegistry...)
+}
+
+// RequirementPermissions lists the distinct permissions a requirement
+// mentions, negated ones included.
+func RequirementPermissions(req Requirement) []string {
+	seen := map[string]bool{}
+	var walk func(Requirement)
+	walk = func(r Requirement) {
+		switch r := r.(type) {
+		case permissionReq:
+			seen[string(r)] = true
+		case allReq:
+			for _, inner := range r {
+				walk(inner)
+			}
+		case anyReq:
+			for _, inner := range r {
+				walk(inner)
+			}
+		case notReq:
+			walk(r.inner)
+		}
+	}
+	walk(req)
+
+	permissions := make([]string, 0, len(seen))
+	for p := range seen {
+		permissions = append(permissions, p)
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// RouteValidationMode reads ROUTE_VALIDATION_MODE; anything unknown means warn.
+func RouteValidationMode() string {
+	switch mode := strings.ToLower(utils_v1.GetEnv("ROUTE_VALIDATION_MODE")); mode {
+	case RouteValidationOff, RouteValidationFail:
+		return mode
+	default:
+		return RouteValidationWarn
+	}
+}
+
+// CheckRoutes compares the registry with the actions and resources tables.
+// A route requirement must name existing rows and must not use wildcards,
+// which belong on grants, and each method and path is registered once.
+func CheckRoutes() ([]mdlRbac.RouteIssue, error) {
+	actions, resources, err := scpRbac.ActionAndResourceNames()
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []mdlRbac.RouteIssue{}
+	seen := map[string]bool{}
+	for _, route := range RegisteredRoutes() {
+		key := route.Method + " " + route.Path
+		if seen[key] {
+			issues = append(issues, mdlRbac.RouteIssue{
+				Method: route.Method, Path: route.Path, Problem: "route registered more than once",
+			})
+		}
+		seen[key] = true
+
+		for _, permission := range route.Permissions {
+			action, resource, _ := strings.Cut(permission, ":")
+			var problems []string
+			if action == "*" || resource == "*" {
+				problems = append(problems, "wildcard in route requirement")
+			}
+			if action != "*" && !actions[action] {
+				problems = append(problems, fmt.Sprintf("action %q does not exist", action))
+			}
+			if resource != "*" && !resources[resource] {
+				problems = append(problems, fmt.Sprintf("resource %q does not exist", resource))
+			}
+			for _, problem := range problems {
+				issues = append(issues, mdlRbac.RouteIssue{
+					Method: route.Method, Path: route.Path, Permission: permission, Problem: problem,
+				})
+			}
+		}
+	}
+
+	return issues, nil
+}
+
+// ValidateRoutes runs CheckRoutes at startup. In warn mode every issue is
+// logged and the service starts anyway; in fail mode any issue, or a failed
+// lookup, is returned so the caller can refuse to start.
+func ValidateRoutes() error {
+	mode := RouteValidationMode()
+	fmt.Println("ROUTE VALIDATION:", strings.ToUpper(mode))
+	if mode == RouteValidationOff {
+		return nil
+	}
+
+	issues, err := CheckRoutes()
+	if err != nil {
+		if mode == RouteValidationFail {
+			return fmt.Errorf("route validation failed: %w", err)
+		}
+		log.Printf("Route validation skipped: %v", err)
+		return nil
+	}
+
+	for _, issue := range issues {
+		log.Printf("Route %s %s: %s %s", issue.Method, issue.Path, issue.Permission, issue.Problem)
+	}
+	if len(issues) > 0 && mode == RouteValidationFail {
+		return fmt.Errorf("route validation failed: %d issue(s)", len(issues))
+	}
+
+	return nil
+}
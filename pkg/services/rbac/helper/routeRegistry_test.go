@@ -0,0 +1,70 @@
+package hlpRbac
+
+import (
+	"reflect"
+	"testing"
+
+	mdlRbac "go_template_v3/pkg/services/rbac/model"
+)
+
+func TestRequirementPermissions(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		// Negated permissions are listed too; validation must see them
+		{"(view:role || *:role) && !delete:exam", []string{"*:role", "delete:exam", "view:role"}},
+		{"view:role || view:role && !view:role", []string{"view:role"}},
+		{"!!approve:access_request", []string{"approve:access_request"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			req, err := ParseRequirement(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseRequirement: %v", err)
+			}
+			if got := RequirementPermissions(req); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("RequirementPermissions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := RequirementPermissions(All()); got == nil || len(got) != 0 {
+		t.Fatalf("RequirementPermissions(All()) = %#v, want an empty slice", got)
+	}
+}
+
+func TestRegisterRoute(t *testing.T) {
+	routeRegistryMu.Lock()
+	saved := routeRegistry
+	routeRegistry = nil
+	routeRegistryMu.Unlock()
+	t.Cleanup(func() {
+		routeRegistryMu.Lock()
+		routeRegistry = saved
+		routeRegistryMu.Unlock()
+	})
+
+	RegisterRoute("GET", "/health", mdlRbac.RouteAccessPublic, nil)
+	RegisterRoute("PUT", "/roles/:roleId", mdlRbac.RouteAccessPermission, All("view:role", "update:role"))
+
+	// Routes without a requirement still encode their permissions as []
+	want := []mdlRbac.RoutePermission{
+		{Method: "GET", Path: "/health", Access: mdlRbac.RouteAccessPublic, Permissions: []string{}},
+		{
+			Method: "PUT", Path: "/roles/:roleId", Access: mdlRbac.RouteAccessPermission,
+			Requirement: "(view:role && update:role)", Permissions: []string{"update:role", "view:role"},
+		},
+	}
+	got := RegisteredRoutes()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("RegisteredRoutes = %+v, want %+v", got, want)
+	}
+
+	// Callers get a copy, not the registry itself
+	got[0].Path = "/changed"
+	if RegisteredRoutes()[0].Path != "/health" {
+		t.Fatalf("RegisteredRoutes exposed the registry")
+	}
+}
@@ -0,0 +1,100 @@
+package hlpRbac
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+)
+
+// ============================================
+// INSTITUTION SCOPE
+// ============================================
+
+// inScope reports whether an assignment scoped to scope applies to a token
+// issued for institutionCode. Unscoped assignments apply everywhere.
+func inScope(scope *string, institutionCode string) bool {
+	return scope == nil || strings.EqualFold(*scope, institutionCode)
+}
+
+// inWindow reports whether an assignment valid from..until (nil is
+// open-ended) is in force at now. The cached permission set keeps the window
+// so an assignment lapses on time, not when the cache entry expires.
+func inWindow(from, until *time.Time, now time.Time) bool {
+	return (from == nil || !now.Before(*from)) && (until == nil || now.Before(*until))
+}
+
+// RoleApplies reports whether a role assignment is in force for a token
+// issued for institutionCode right now.
+func RoleApplies(role mdlAuth.UserRole, institutionCode string) bool {
+	return inScope(role.InstitutionCode, institutionCode) && inWindow(role.ValidFrom, role.ValidUntil, time.Now())
+}
+
+// ScopedPermissionSet returns the user's grants that apply to the institution
+// right now. A grant whose condition no longer parses is dropped, never widened.
+func ScopedPermissionSet(user *mdlAuth.UserWithPermissions, institutionCode string) PermissionSet {
+	now := time.Now()
+	set := make(PermissionSet, len(user.Grants))
+	for _, grant := range user.Grants {
+		if !inScope(grant.InstitutionCode, institutionCode) || !inWindow(grant.ValidFrom, grant.ValidUntil, now) {
+			continue
+		}
+
+		var cond *Condition
+		if grant.Condition != nil && strings.TrimSpace(*grant.Condition) != "" {
+			compiled, err := CompileCondition(*grant.Condition)
+			if err != nil {
+				log.Printf("Ignoring grant %s with invalid condition: %v", grant.Permission, err)
+				continue
+			}
+			cond = compiled
+		}
+		set.Add(grant.Permission, cond)
+	}
+	return set
+}
+
+// ScopedRoles returns the names of the user's roles that apply to the
+// institution right now.
+func ScopedRoles(user *mdlAuth.UserWithPermissions, institutionCode string) []string {
+	var names []string
+	for _, role := range user.Roles {
+		if RoleApplies(role, institutionCode) {
+			names = append(names, role.Name)
+		}
+	}
+	return names
+}
+
+// IsSuperuserIn reports whether the user holds a superuser role that applies
+// to the institution right now.
+func IsSuperuserIn(user *mdlAuth.UserWithPermissions, institutionCode string) bool {
+	for _, role := range user.Roles {
+		if role.IsSuperuser && RoleApplies(role, institutionCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// SubjectAttributes exposes the user to grant conditions as subject.username,
+// subject.staff_id, subject.institution and subject.roles.
+func SubjectAttributes(user *mdlAuth.UserWithPermissions, institutionCode string) Attributes {
+	return Attributes{
+		"subject.username":    user.Username,
+		"subject.staff_id":    user.StaffID,
+		"subject.institution": institutionCode,
+		"subject.roles":       ScopedRoles(user, institutionCode),
+	}
+}
+
+// TimeAttributes exposes the clock as request.hour, request.weekday
+// (0 = Sunday) and request.date.
+func TimeAttributes(now time.Time) Attributes {
+	return Attributes{
+		"request.hour":    now.Hour(),
+		"request.weekday": int(now.Weekday()),
+		"request.date":    now.Format("2006-01-02"),
+	}
+}
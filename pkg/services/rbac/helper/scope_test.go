@@ -0,0 +1,131 @@
+package hlpRbac
+
+import (
+	"testing"
+	"time"
+
+	mdlAuth "go_template_v3/pkg/services/auth/model"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestInScope(t *testing.T) {
+	tests := []struct {
+		name        string
+		scope       *string
+		institution string
+		want        bool
+	}{
+		{"unscoped applies everywhere", nil, "9869", true},
+		{"unscoped applies to tokens without an institution", nil, "", true},
+		{"institution codes ignore case", strPtr("abc"), "ABC", true},
+		{"other institution", strPtr("9869"), "2546", false},
+		// A token without an institution must not pick up scoped roles
+		{"scoped needs an institution", strPtr("9869"), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inScope(tt.scope, tt.institution); got != tt.want {
+				t.Fatalf("inScope = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopedPermissionSet(t *testing.T) {
+	// The same permission through a global and a scoped role
+	user := &mdlAuth.UserWithPermissions{Grants: []mdlAuth.PermissionGrant{
+		{Permission: "view:role"},
+		{Permission: "view:role", InstitutionCode: strPtr("9869")},
+		{Permission: "update:role", InstitutionCode: strPtr("9869")},
+		{Permission: "*:exam", InstitutionCode: strPtr("2546")},
+	}}
+
+	at9869 := ScopedPermissionSet(user, "9869")
+	if !at9869.Has("view:role") || !at9869.Has("update:role") || at9869.Has("delete:exam") {
+		t.Fatalf("grants at 9869 = %v", at9869)
+	}
+
+	at2546 := ScopedPermissionSet(user, "2546")
+	if !at2546.Has("view:role") || at2546.Has("update:role") || !at2546.Has("delete:exam") {
+		t.Fatalf("grants at 2546 = %v", at2546)
+	}
+
+	if unscoped := ScopedPermissionSet(user, ""); len(unscoped) != 1 || !unscoped.Has("view:role") {
+		t.Fatalf("grants without an institution = %v, want only view:role", unscoped)
+	}
+}
+
+func TestIsSuperuserIn(t *testing.T) {
+	user := &mdlAuth.UserWithPermissions{Roles: []mdlAuth.UserRole{
+		{Name: "teller"},
+		{Name: "admin", IsSuperuser: true, InstitutionCode: strPtr("9869")},
+	}}
+
+	if !IsSuperuserIn(user, "9869") {
+		t.Fatalf("scoped superuser not recognised in its institution")
+	}
+	if IsSuperuserIn(user, "2546") || IsSuperuserIn(user, "") {
+		t.Fatalf("scoped superuser leaked into another institution")
+	}
+}
+
+func timePtr(t time.Time) *time.Time { return &t }
+
+func TestInWindow(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier, later := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		from, until *time.Time
+		want        bool
+	}{
+		{"open-ended", nil, nil, true},
+		{"not started", timePtr(later), nil, false},
+		// The window is half-open: in force from its start, gone at its end
+		{"starts now", timePtr(now), nil, true},
+		{"expires now", nil, timePtr(now), false},
+		{"expired", timePtr(earlier.Add(-time.Hour)), timePtr(earlier), false},
+		{"inside the window", timePtr(earlier), timePtr(later), true},
+		{"inverted window", timePtr(later), timePtr(earlier), false},
+		// Windows written in another zone compare by instant
+		{"other time zone", timePtr(now.In(time.FixedZone("PHT", 8*3600))), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inWindow(tt.from, tt.until, now); got != tt.want {
+				t.Fatalf("inWindow = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiredAssignmentsLapseWithoutTheSweeper(t *testing.T) {
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	user := &mdlAuth.UserWithPermissions{
+		Roles: []mdlAuth.UserRole{
+			{Name: "teller"},
+			{Name: "admin", IsSuperuser: true, ValidUntil: timePtr(past)},
+			{Name: "auditor", ValidFrom: timePtr(future)},
+		},
+		Grants: []mdlAuth.PermissionGrant{
+			{Permission: "view:loan"},
+			{Permission: "delete:loan", ValidUntil: timePtr(past)},
+			{Permission: "approve:loan", ValidFrom: timePtr(future)},
+		},
+	}
+
+	set := ScopedPermissionSet(user, "9869")
+	if !set.Has("view:loan") || set.Has("delete:loan") || set.Has("approve:loan") {
+		t.Fatalf("grants = %v, want only view:loan", set)
+	}
+	if roles := ScopedRoles(user, "9869"); len(roles) != 1 || roles[0] != "teller" {
+		t.Fatalf("ScopedRoles = %v, want [teller]", roles)
+	}
+	if IsSuperuserIn(user, "9869") {
+		t.Fatalf("expired superuser role still bypasses")
+	}
+}
@@ -1,15 +1,70 @@
 package mdlRbac
 
+import "time"
+
 type Role struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID           int     `json:"id"`
+	Name         string  `json:"name"`
+	Description  string  `json:"description"`
+	IsSuperuser  bool    `json:"is_superuser"`
+	ParentRoleID *int    `json:"parent_role_id"`
+	CreatedAt    string  `json:"created_at"`
+	UpdatedAt    string  `json:"updated_at"`
+	ArchivedAt   *string `json:"archived_at,omitempty"`
+}
+
+type RoleParentRequest struct {
+	ParentRoleID *int `json:"parent_role_id"` // null detaches the role
+}
+
+type RoleSuperuserRequest struct {
+	IsSuperuser *bool `json:"is_superuser"`
 }
 
+// Events written to rbac_audit_logs
+const (
+	AuditSuperuserBypass  = "superuser_bypass"
+	AuditSuperuserGranted = "superuser_granted"
+	AuditSuperuserRevoked = "superuser_revoked"
+	AuditRoleExpired      = "role_assignment_expired"
+	AuditAccessRequested  = "access_requested"
+	AuditAccessApproved   = "access_request_approved"
+	AuditAccessDenied     = "access_request_denied"
+	AuditAccessCancelled  = "access_request_cancelled"
+	AuditAccessRevoked    = "access_request_revoked"
+	AuditAccessExpired    = "access_request_expired"
+	AuditPolicyImported   = "policy_imported"
+)
+
+// MaxAccessChecks caps the number of checks in one batch request.
+const MaxAccessChecks = 100
+
+// Menu is a navigation entry. Permission is a requirement expression; users
+// who satisfy it see the menu without a role_menus assignment.
 type Menu struct {
-	MenuID    int     `json:"menu_id"`
-	Name      string  `json:"name"`
-	Slug      string  `json:"slug"`
-	SystemTag *string `json:"system_tag,omitempty"`
+	MenuID     int     `json:"menu_id"`
+	ParentID   *int    `json:"parent_id"`
+	Name       string  `json:"name"`
+	Slug       string  `json:"slug"`
+	Path       *string `json:"path,omitempty"`
+	Icon       *string `json:"icon,omitempty"`
+	SortOrder  int     `json:"sort_order"`
+	SystemTag  *string `json:"system_tag,omitempty"`
+	Permission *string `json:"permission,omitempty"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+	Children   []Menu  `json:"children,omitempty" gorm:"-"`
+}
+
+type MenuRequest struct {
+	ParentID   *int    `json:"parent_id"`
+	Name       string  `json:"name"`
+	Slug       string  `json:"slug"`
+	Path       *string `json:"path"`
+	Icon       *string `json:"icon"`
+	SortOrder  int     `json:"sort_order"`
+	SystemTag  *string `json:"system_tag"`
+	Permission *string `json:"permission"`
 }
 
 type RoleMenu struct {
@@ -18,9 +73,58 @@ type RoleMenu struct {
 	MenuID int `json:"menu_id"`
 }
 
-type Permissions struct {
-	PermissionsID int    `json:"permissions_id"`
-	Name          string `json:"name"`
+// RoleMenusRequest replaces every menu assigned to a role.
+type RoleMenusRequest struct {
+	MenuIDs []int `json:"menu_ids"`
+}
+
+// Permission is one action/resource pair of the catalogue. Name is the
+// formatted "action:resource"; RoleCount is how many roles grant it directly.
+type Permission struct {
+	ID         int    `json:"id"`
+	ActionID   int    `json:"action_id"`
+	Action     string `json:"action"`
+	ResourceID int    `json:"resource_id"`
+	Resource   string `json:"resource"`
+	Name       string `json:"name"`
+	RoleCount  int    `json:"role_count"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+type PermissionRequest struct {
+	ActionID   int `json:"action_id"`
+	ResourceID int `json:"resource_id"`
+}
+
+type UserRoleRequest struct {
+	RoleID          int        `json:"role_id"`
+	InstitutionCode *string    `json:"institution_code"` // omit for every institution
+	ValidFrom       *time.Time `json:"valid_from"`       // omit to start now
+	ValidUntil      *time.Time `json:"valid_until"`      // omit to never expire
+}
+
+// PrimaryRoleRequest is the optional body of AssignUserRole.
+type PrimaryRoleRequest struct {
+	ValidFrom  *time.Time `json:"valid_from"`  // omit to start now
+	ValidUntil *time.Time `json:"valid_until"` // omit to never expire
+}
+
+type UserRoleItem struct {
+	Role
+	InstitutionCode *string    `json:"institution_code"`
+	ValidFrom       *time.Time `json:"valid_from"`
+	ValidUntil      *time.Time `json:"valid_until"`
+}
+
+// ExpiredUserRole is a time-bound assignment removed by the expiry sweeper.
+type ExpiredUserRole struct {
+	Username        string    `json:"username"`
+	StaffID         string    `json:"staff_id"`
+	RoleID          int       `json:"role_id"`
+	RoleName        string    `json:"role_name"`
+	InstitutionCode *string   `json:"institution_code"`
+	ValidUntil      time.Time `json:"valid_until"`
 }
 
 type AssignRoleRequest struct {
@@ -39,29 +143,332 @@ type PermissionResult struct {
 
 type RoleWithPermissions struct {
 	Role        string           `json:"role"`
+	ParentRole  *string          `json:"parent_role"`
 	Permissions []PermissionItem `json:"permissions"`
 }
 
 type PermissionItem struct {
-	Resource  string `json:"resource"`
-	Action    string `json:"action"`
-	Formatted string `json:"formatted"`
+	Resource      string  `json:"resource"`
+	Action        string  `json:"action"`
+	Formatted     string  `json:"formatted"`
+	Inherited     bool    `json:"inherited"`
+	InheritedFrom string  `json:"inherited_from,omitempty"`
+	Condition     *string `json:"condition"`
 }
 
 type PermissionToRoleReq struct {
-	ActionName   string `json:"action"`
-	ResourceName string `json:"resource"`
+	ActionName   string  `json:"action"`
+	ResourceName string  `json:"resource"`
+	Condition    *string `json:"condition"` // optional; null or empty clears it
+}
+
+type ReplaceRolePermissionsReq struct {
+	Permissions []PermissionToRoleReq `json:"permissions"`
+}
+
+// RolePermissionDiff is what ReplaceRolePermissions changed on a role's
+// direct grants. Updated lists grants whose condition changed.
+type RolePermissionDiff struct {
+	Added   []PermissionItem `json:"added"`
+	Removed []PermissionItem `json:"removed"`
+	Updated []PermissionItem `json:"updated"`
 }
 
 type RBACItemResponse struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+	ArchivedAt  *string `json:"archived_at,omitempty"`
 }
 
 type RbacItemRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 }
+
+// AccessCheckRequest asks whether a user may do something. Give either a
+// single "action:resource" permission or a requirement expression. Resource
+// and Request are exposed to grant conditions as resource.<key> and
+// request.<key>; request.hour, request.weekday and request.date default to
+// the server clock.
+type AccessCheckRequest struct {
+	Username        string                 `json:"username"`
+	InstitutionCode string                 `json:"institution_code"`
+	Permission      string                 `json:"permission"`
+	Expression      string                 `json:"expression"`
+	Resource        map[string]interface{} `json:"resource"`
+	Request         map[string]interface{} `json:"request"`
+}
+
+// AccessCheckBatchRequest runs several checks at once. Checks without a
+// username or institution_code inherit the batch-level ones.
+type AccessCheckBatchRequest struct {
+	Username        string               `json:"username"`
+	InstitutionCode string               `json:"institution_code"`
+	Checks          []AccessCheckRequest `json:"checks"`
+}
+
+type AccessCheckResult struct {
+	Username        string   `json:"username"`
+	InstitutionCode string   `json:"institution_code"`
+	Requirement     string   `json:"requirement"`
+	Allowed         bool     `json:"allowed"`
+	Code            string   `json:"code"`
+	MatchedGrant    string   `json:"matched_grant,omitempty"`
+	MatchedGrants   []string `json:"matched_grants"`
+	Missing         []string `json:"missing,omitempty"`
+	Reason          string   `json:"reason"`
+}
+
+// PermissionExplanation shows support why a user is or is not allowed
+// something: the roles that apply at the institution, every effective grant
+// with where it comes from and, for a denied permission, what is missing.
+type PermissionExplanation struct {
+	Username        string                `json:"username"`
+	StaffID         string                `json:"staff_id"`
+	InstitutionCode string                `json:"institution_code"`
+	Roles           []ExplainedRole       `json:"roles"`
+	IsSuperuser     bool                  `json:"is_superuser"`
+	Permissions     []EffectivePermission `json:"permissions"`
+	Check           *AccessCheckResult    `json:"check,omitempty"`
+	Missing         []MissingGrant        `json:"missing,omitempty"`
+}
+
+type ExplainedRole struct {
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	IsSuperuser     bool       `json:"is_superuser"`
+	InstitutionCode *string    `json:"institution_code"`
+	ValidFrom       *time.Time `json:"valid_from"`
+	ValidUntil      *time.Time `json:"valid_until"`
+	InScope         bool       `json:"in_scope"` // applies to the requested institution right now
+}
+
+// Sources of an effective permission
+const (
+	SourceDirect    = "direct"
+	SourceInherited = "inherited"
+	SourceSuperuser = "superuser_bypass"
+)
+
+type EffectivePermission struct {
+	Permission    string  `json:"permission"`
+	Condition     *string `json:"condition"`
+	Source        string  `json:"source"`
+	Role          string  `json:"role"`
+	InheritedFrom string  `json:"inherited_from,omitempty"`
+}
+
+// MissingGrant names a grant the user lacks, the nearest grants they do
+// hold and the roles that would give it to them.
+type MissingGrant struct {
+	Permission    string   `json:"permission"`
+	NearestHeld   []string `json:"nearest_held"`
+	GrantingRoles []string `json:"granting_roles"`
+}
+
+// Access request statuses
+const (
+	AccessRequestPending   = "pending"
+	AccessRequestApproved  = "approved"
+	AccessRequestDenied    = "denied"
+	AccessRequestCancelled = "cancelled"
+	AccessRequestRevoked   = "revoked"
+	AccessRequestExpired   = "expired"
+)
+
+// AccessRequestCreate asks for either a role or a single action:resource
+// permission for DurationMinutes once approved.
+type AccessRequestCreate struct {
+	RoleID          *int    `json:"role_id"`
+	ActionName      string  `json:"action"`
+	ResourceName    string  `json:"resource"`
+	InstitutionCode *string `json:"institution_code"` // omit for every institution
+	Reason          string  `json:"reason"`
+	DurationMinutes int     `json:"duration_minutes"`
+}
+
+type AccessRequestDecision struct {
+	Note string `json:"note"`
+}
+
+type AccessRequest struct {
+	ID              int        `json:"id"`
+	RequesterID     int        `json:"requester_id"`
+	Requester       string     `json:"requester"`
+	RoleID          *int       `json:"role_id"`
+	RoleName        *string    `json:"role_name"`
+	Action          *string    `json:"action"`
+	Resource        *string    `json:"resource"`
+	InstitutionCode *string    `json:"institution_code"`
+	Reason          string     `json:"reason"`
+	DurationMinutes int        `json:"duration_minutes"`
+	Status          string     `json:"status"`
+	DecidedBy       *string    `json:"decided_by"`
+	DecidedAt       *time.Time `json:"decided_at"`
+	DecisionNote    *string    `json:"decision_note"`
+	ValidFrom       *time.Time `json:"valid_from"`
+	ValidUntil      *time.Time `json:"valid_until"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// SoDRule is a set of mutually exclusive roles and permissions: nobody may
+// hold more than one of them.
+type SoDRule struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description"`
+	Roles       []string  `json:"roles"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type SoDRuleRequest struct {
+	Name        string   `json:"name"`
+	Description *string  `json:"description"`
+	RoleIDs     []int    `json:"role_ids"`
+	Permissions []string `json:"permissions"` // "action:resource"
+}
+
+// SoDViolation is a user or role that holds several members of one rule.
+type SoDViolation struct {
+	SubjectType string `json:"subject_type"` // "user" or "role"
+	SubjectID   int    `json:"subject_id"`
+	Subject     string `json:"subject"`
+	RuleID      int    `json:"rule_id"`
+	RuleName    string `json:"rule_name"`
+	Members     string `json:"members"`
+}
+
+// PolicyVersion is the schema version of exported policy documents.
+const PolicyVersion = 1
+
+// PolicyDocument is the whole RBAC configuration, matched by name so it can
+// move between environments whose IDs differ.
+type PolicyDocument struct {
+	Version    int          `json:"version" yaml:"version"`
+	ExportedAt string       `json:"exported_at,omitempty" yaml:"exported_at,omitempty"`
+	Actions    []PolicyItem `json:"actions" yaml:"actions"`
+	Resources  []PolicyItem `json:"resources" yaml:"resources"`
+	Roles      []PolicyRole `json:"roles" yaml:"roles"`
+}
+
+type PolicyItem struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+type PolicyRole struct {
+	Name        string             `json:"name" yaml:"name"`
+	Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Parent      string             `json:"parent,omitempty" yaml:"parent,omitempty"`
+	IsSuperuser bool               `json:"is_superuser,omitempty" yaml:"is_superuser,omitempty"`
+	Permissions []PolicyPermission `json:"permissions" yaml:"permissions"`
+}
+
+type PolicyPermission struct {
+	Action    string  `json:"action" yaml:"action"`
+	Resource  string  `json:"resource" yaml:"resource"`
+	Condition *string `json:"condition,omitempty" yaml:"condition,omitempty"`
+}
+
+// PolicyImportOptions: DryRun runs the import and rolls it back; Prune also
+// archives roles, actions and resources missing from the document.
+// AllowSuperuser is set when the actor holds update:superuser; without it
+// any import that creates, flips or restores a superuser role is refused.
+type PolicyImportOptions struct {
+	DryRun         bool
+	Prune          bool
+	AllowSuperuser bool
+}
+
+// Policy change operations
+const (
+	PolicyCreate  = "create"
+	PolicyUpdate  = "update"
+	PolicyDelete  = "delete"
+	PolicyArchive = "archive"
+	PolicyRestore = "restore"
+)
+
+// PolicyChange is one difference between the document and the database.
+type PolicyChange struct {
+	Kind string `json:"kind"` // action, resource, role, role_parent or role_permission
+	Op   string `json:"op"`
+	Name string `json:"name"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+type PolicyDiff struct {
+	DryRun  bool           `json:"dry_run"`
+	Applied bool           `json:"applied"`
+	Changes []PolicyChange `json:"changes"`
+}
+
+// Access levels of a registered route
+const (
+	RouteAccessPublic        = "public"
+	RouteAccessAuthenticated = "authenticated"
+	RouteAccessPermission    = "permission"
+)
+
+// RoutePermission is one API route with the requirement guarding it.
+type RoutePermission struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Access      string   `json:"access"`
+	Requirement string   `json:"requirement,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// RouteIssue is a route requirement that does not line up with the database.
+type RouteIssue struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Permission string `json:"permission,omitempty"`
+	Problem    string `json:"problem"`
+}
+
+type RouteMap struct {
+	Routes []RoutePermission `json:"routes"`
+	Issues []RouteIssue      `json:"issues"`
+}
+
+// ImpactRole is a role a previewed change weakens: it loses effective
+// permissions, or users stop holding it.
+type ImpactRole struct {
+	ID              int      `json:"id"`
+	Name            string   `json:"name"`
+	LostPermissions []string `json:"lost_permissions"`
+}
+
+// ImpactRoute is an API route guarded by a permission requirement.
+type ImpactRoute struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Requirement string `json:"requirement"`
+}
+
+// ImpactUser is one user at one institution who would lose access. A nil
+// InstitutionCode stands for every institution the user has no assignment of
+// its own in.
+type ImpactUser struct {
+	StaffID         string        `json:"staff_id"`
+	Username        string        `json:"username"`
+	InstitutionCode *string       `json:"institution_code"`
+	LostPermissions []string      `json:"lost_permissions"`
+	LostSuperuser   bool          `json:"lost_superuser,omitempty"`
+	LostRoutes      []ImpactRoute `json:"lost_routes"`
+}
+
+// ImpactReport previews what a change would take away; nothing is changed.
+type ImpactReport struct {
+	Change string        `json:"change"`
+	Roles  []ImpactRole  `json:"roles"`
+	Users  []ImpactUser  `json:"users"`
+	Routes []ImpactRoute `json:"routes"` // lost by at least one user
+}
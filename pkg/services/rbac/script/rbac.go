@@ -2,30 +2,87 @@ package scpRbac
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go_template_v3/pkg/config"
+	"go_template_v3/pkg/global/pagination"
+	mdlAuth "go_template_v3/pkg/services/auth/model"
 	errRbac "go_template_v3/pkg/services/rbac/error"
 	mdlRbac "go_template_v3/pkg/services/rbac/model"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
+// listPage runs query (without ORDER BY) one page at a time and returns the
+// number of rows it matches in total.
+func listPage(dest interface{}, query string, args []interface{}, p pagination.Params, tiebreak string) (int64, error) {
+	db := &config.DBConnList[0]
+
+	var total int64
+	if err := db.Raw(`SELECT COUNT(*) FROM (`+query+`) counted`, args...).Scan(&total).Error; err != nil {
+		return 0, err
+	}
+
+	paged := query + ` ORDER BY ` + p.OrderBy(tiebreak) + ` LIMIT ? OFFSET ?`
+	if err := db.Raw(paged, append(append([]interface{}{}, args...), p.PageSize, p.Offset())...).Scan(dest).Error; err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
 // ----------------------------
 // Role Permissions
 // ----------------------------
 
-// AssignPermissionToRole assigns a permission to a role
-func AssignRolePermission(roleID int, actionName string, resourceName string) (*mdlRbac.PermissionResult, error) {
+// AssignPermissionToRole assigns a permission to a role. A non-nil condition
+// is saved on the grant in the same transaction.
+func AssignRolePermission(roleID int, actionName string, resourceName string, condition *string) (*mdlRbac.PermissionResult, error) {
 	db := config.DBConnList[0] // remove the pointer & (not needed)
 
 	query := `SELECT * FROM assign_role_permission($1, $2, $3);`
 
 	var result mdlRbac.PermissionResult
 
-	err := db.Raw(query, roleID, actionName, resourceName).Scan(&result).Error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var archived bool
+		check := `
+			SELECT EXISTS (SELECT 1 FROM roles WHERE id = ? AND archived_at IS NOT NULL)
+				OR EXISTS (SELECT 1 FROM actions WHERE name = ? AND archived_at IS NOT NULL)
+				OR EXISTS (SELECT 1 FROM resources WHERE name = ? AND archived_at IS NOT NULL)
+		`
+		if err := tx.Raw(check, roleID, actionName, resourceName).Scan(&archived).Error; err != nil {
+			return fmt.Errorf("failed to check archived items: %v", err)
+		}
+		if archived {
+			return errRbac.ErrResourceNotFound
+		}
+
+		guard, err := guardRoleSoD(tx, roleID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Raw(query, roleID, actionName, resourceName).Scan(&result).Error; err != nil {
+			return fmt.Errorf("error executing assign_permission_from_role: %v", err)
+		}
+
+		if result.Success && condition != nil {
+			if err := setRolePermissionCondition(tx, roleID, actionName, resourceName, condition); err != nil {
+				return err
+			}
+		}
+
+		return guard.check()
+	})
 
 	if err != nil {
-		return nil, fmt.Errorf("error executing assign_permission_from_role: %v", err)
+		return nil, err
 	}
 
 	fmt.Printf("AssignRolePermission => success=%v, msg=%s\n", result.Success, result.Message)
@@ -34,23 +91,36 @@ func AssignRolePermission(roleID int, actionName string, resourceName string) (*
 	return &result, nil
 }
 
-func GetAllRolePermissionsGrouped() ([]mdlRbac.RoleWithPermissions, error) {
-	db := config.DBConnList[0]
+// RolePermissionsSorting lists the sort fields of GetAllRolePermissionsGrouped.
+var RolePermissionsSorting = pagination.Sorting{
+	Columns: map[string]string{"role": "r.name", "parent_role": "parent.name"},
+	Default: "role",
+}
 
-	query := `SELECT get_all_roles_permissions_json()::text`
+// GetAllRolePermissionsGrouped returns one page of roles with their effective
+// grants, inherited ones included; q searches role names. Archived roles are
+// left out unless archived is set.
+func GetAllRolePermissionsGrouped(p pagination.Params, archived bool) ([]mdlRbac.RoleWithPermissions, int64, error) {
+	query := `
+		SELECT get_role_permissions_json(r.id)::text AS permissions
+		FROM roles r
+		LEFT JOIN roles parent ON parent.id = r.parent_role_id
+		WHERE (?::text = '' OR r.name ILIKE ?) AND (? OR r.archived_at IS NULL)
+	`
 
-	var jsonStr string
-	if err := db.Raw(query).Scan(&jsonStr).Error; err != nil {
-		return nil, fmt.Errorf("error executing get_all_roles_permissions: %v", err)
+	var rows []struct{ Permissions string }
+	total, err := listPage(&rows, query, []interface{}{p.Search, p.Like(), archived}, p, "r.id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("error executing get_role_permissions: %v", err)
 	}
 
-	// Direct unmarshal to slice
-	var result []mdlRbac.RoleWithPermissions
-	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-		return nil, fmt.Errorf("error parsing response: %v", err)
+	result := make([]mdlRbac.RoleWithPermissions, len(rows))
+	for i, row := range rows {
+		if err := json.Unmarshal([]byte(row.Permissions), &result[i]); err != nil {
+			return nil, 0, fmt.Errorf("error parsing response: %v", err)
+		}
 	}
-
-	return result, nil
+	return result, total, nil
 }
 
 // AssignPermissionToRole assigns a permission to a role
@@ -77,6 +147,28 @@ func GetRolePermissionsByRole(roleId int) (*mdlRbac.RoleWithPermissions, error)
 	return &result, nil
 }
 
+// RolesGranting lists the roles whose effective permissions (own or
+// inherited, wildcards included) cover action:resource.
+func RolesGranting(actionName, resourceName string) ([]mdlRbac.Role, error) {
+	db := &config.DBConnList[0]
+
+	query := `
+		SELECT r.id, r.name, r.description, r.is_superuser, r.parent_role_id, r.created_at, r.updated_at
+		FROM roles r
+		WHERE EXISTS (
+			SELECT 1 FROM role_effective_permissions(r.id) p
+			WHERE p.action_name IN (?, '*') AND p.resource_name IN (?, '*')
+		)
+		ORDER BY r.name`
+
+	roles := []mdlRbac.Role{}
+	if err := db.Raw(query, actionName, resourceName).Scan(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch roles granting %s:%s: %v", actionName, resourceName, err)
+	}
+
+	return roles, nil
+}
+
 // RemovePermissionFromRole removes a permission from a role
 func RemoveRolePermission(roleID int, actionName string, resourceName string) (*mdlRbac.PermissionResult, error) {
 	db := &config.DBConnList[0]
@@ -100,282 +192,958 @@ func RemoveRolePermission(roleID int, actionName string, resourceName string) (*
 	return &result, nil
 }
 
-// ----------------------------
-// Permissions
-// ----------------------------
-
-// AddPermission adds a new permission to the database
-func CreatePermission(name string) error {
+// ReplaceRolePermissions makes the role's direct grants exactly the given
+// set in one transaction and reports the difference. Inherited grants are
+// not touched. Unknown actions or resources abort the whole change.
+func ReplaceRolePermissions(roleID int, desired []mdlRbac.PermissionToRoleReq) (*mdlRbac.RolePermissionDiff, error) {
 	db := &config.DBConnList[0]
+	diff := &mdlRbac.RolePermissionDiff{
+		Added:   []mdlRbac.PermissionItem{},
+		Removed: []mdlRbac.PermissionItem{},
+		Updated: []mdlRbac.PermissionItem{},
+	}
 
-	query := `
-		INSERT INTO public.permissions (name, created_at, updated_at)
-		VALUES (?, NOW(), NOW());
-	`
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Archived roles keep their grants untouched until restored
+		var lockedID int
+		if err := tx.Raw(`SELECT id FROM roles WHERE id = ? AND archived_at IS NULL FOR UPDATE`, roleID).Scan(&lockedID).Error; err != nil {
+			return fmt.Errorf("failed to lock role: %v", err)
+		}
+		if lockedID == 0 {
+			return fmt.Errorf("%w: role %d", errRbac.ErrResourceNotFound, roleID)
+		}
 
-	if err := db.Exec(query, name).Error; err != nil {
-		return fmt.Errorf("failed to add permission: %v", err)
-	}
+		guard, err := guardRoleSoD(tx, roleID)
+		if err != nil {
+			return err
+		}
 
-	fmt.Printf("✅ Added permission '%s'\n", name)
-	return nil
-}
+		type grantRow struct {
+			RowID        int
+			PermissionID int
+			Action       string
+			Resource     string
+			Condition    *string
+		}
 
-// FetchPermissions retrieves all permissions from the database
-func FetchPermissions() ([]mdlRbac.Permissions, error) {
-	db := &config.DBConnList[0]
-	var permissions []mdlRbac.Permissions
+		var current []grantRow
+		query := `
+			SELECT rp.id AS row_id, rp.permission_id, a.name AS action, res.name AS resource, rp.condition
+			FROM role_permissions rp
+			JOIN permissions p ON rp.permission_id = p.id
+			JOIN actions a ON p.action_id = a.id
+			JOIN resources res ON p.resource_id = res.id
+			WHERE rp.role_id = ?
+		`
+		if err := tx.Raw(query, roleID).Scan(&current).Error; err != nil {
+			return fmt.Errorf("failed to fetch current permissions: %v", err)
+		}
 
-	query := `
-		SELECT 
-			id, name
-		FROM public.permissions;
-	`
+		currentByKey := make(map[string]grantRow, len(current))
+		for _, row := range current {
+			currentByKey[row.Action+":"+row.Resource] = row
+		}
+
+		wanted := make(map[string]bool, len(desired))
+		for _, req := range desired {
+			key := req.ActionName + ":" + req.ResourceName
+			wanted[key] = true
+			item := mdlRbac.PermissionItem{Action: req.ActionName, Resource: req.ResourceName, Formatted: key, Condition: req.Condition}
+
+			if row, ok := currentByKey[key]; ok {
+				if !sameCondition(row.Condition, req.Condition) {
+					if err := tx.Exec(`UPDATE role_permissions SET condition = ?, updated_at = NOW() WHERE id = ?`, req.Condition, row.RowID).Error; err != nil {
+						return fmt.Errorf("failed to update %s: %v", key, err)
+					}
+					diff.Updated = append(diff.Updated, item)
+				}
+				continue
+			}
+
+			permissionID, err := ensurePermission(tx, req.ActionName, req.ResourceName)
+			if err != nil {
+				return err
+			}
+			insert := `INSERT INTO role_permissions (role_id, permission_id, condition) VALUES (?, ?, ?)`
+			if err := tx.Exec(insert, roleID, permissionID, req.Condition).Error; err != nil {
+				return fmt.Errorf("failed to add %s: %v", key, err)
+			}
+			diff.Added = append(diff.Added, item)
+		}
+
+		for _, row := range current {
+			key := row.Action + ":" + row.Resource
+			if wanted[key] {
+				continue
+			}
+			if err := tx.Exec(`DELETE FROM role_permissions WHERE id = ?`, row.RowID).Error; err != nil {
+				return fmt.Errorf("failed to remove %s: %v", key, err)
+			}
+			diff.Removed = append(diff.Removed, mdlRbac.PermissionItem{
+				Action: row.Action, Resource: row.Resource, Formatted: key, Condition: row.Condition,
+			})
+		}
 
-	if err := db.Raw(query).Scan(&permissions).Error; err != nil {
-		log.Printf("❌ Failed to fetch permissions: %v", err)
+		return guard.check()
+	})
+	if err != nil {
 		return nil, err
 	}
-	fmt.Println("✅ Fetched permissions:", permissions)
-	return permissions, nil
-}
 
-// UpdatePermission updates the name of an existing permission
-func UpdatePermission(permissionID int, name string) error {
-	db := &config.DBConnList[0]
+	log.Printf("Replaced permissions of role %d: +%d -%d ~%d", roleID, len(diff.Added), len(diff.Removed), len(diff.Updated))
+	return diff, nil
+}
 
+// ensurePermission returns the id of the action/resource pair, creating the
+// pair when needed. Archived actions and resources count as missing.
+func ensurePermission(tx *gorm.DB, actionName, resourceName string) (int, error) {
+	var ids struct {
+		ActionID   int
+		ResourceID int
+	}
 	query := `
-		UPDATE public.permissions
-		SET name = ?, updated_at = NOW()
-		WHERE id = ?;
+		SELECT (SELECT id FROM actions WHERE name = ? AND archived_at IS NULL) AS action_id,
+			(SELECT id FROM resources WHERE name = ? AND archived_at IS NULL) AS resource_id
 	`
+	if err := tx.Raw(query, actionName, resourceName).Scan(&ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to resolve %s:%s: %v", actionName, resourceName, err)
+	}
+	if ids.ActionID == 0 {
+		return 0, fmt.Errorf("%w: action %q", errRbac.ErrResourceNotFound, actionName)
+	}
+	if ids.ResourceID == 0 {
+		return 0, fmt.Errorf("%w: resource %q", errRbac.ErrResourceNotFound, resourceName)
+	}
 
-	if err := db.Exec(query, name, permissionID).Error; err != nil {
-		return fmt.Errorf("failed to update permission: %v", err)
+	insert := `
+		INSERT INTO permissions (resource_id, action_id) VALUES (?, ?)
+		ON CONFLICT (resource_id, action_id) DO NOTHING
+	`
+	if err := tx.Exec(insert, ids.ResourceID, ids.ActionID).Error; err != nil {
+		return 0, fmt.Errorf("failed to create permission %s:%s: %v", actionName, resourceName, err)
 	}
 
-	fmt.Printf("✅ Updated permission %d to '%s'\n", permissionID, name)
-	return nil
+	var permissionID int
+	query = `SELECT id FROM permissions WHERE resource_id = ? AND action_id = ?`
+	if err := tx.Raw(query, ids.ResourceID, ids.ActionID).Scan(&permissionID).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch permission %s:%s: %v", actionName, resourceName, err)
+	}
+	return permissionID, nil
 }
 
-// DeletePermission removes a permission by its ID
-func DeletePermission(permissionID int) error {
-	db := &config.DBConnList[0]
+func sameCondition(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
 
+// SetRolePermissionCondition stores the condition of an assigned permission;
+// nil makes the grant unconditional again.
+func SetRolePermissionCondition(roleID int, actionName, resourceName string, condition *string) error {
+	return setRolePermissionCondition(&config.DBConnList[0], roleID, actionName, resourceName, condition)
+}
+
+func setRolePermissionCondition(db *gorm.DB, roleID int, actionName, resourceName string, condition *string) error {
 	query := `
-		DELETE FROM public.permissions
-		WHERE id = ?;
+		UPDATE role_permissions rp
+		SET condition = ?, updated_at = NOW()
+		FROM permissions p
+		JOIN actions a ON p.action_id = a.id
+		JOIN resources res ON p.resource_id = res.id
+		WHERE rp.permission_id = p.id
+			AND rp.role_id = ?
+			AND a.name = ?
+			AND res.name = ?
 	`
+	result := db.Exec(query, condition, roleID, actionName, resourceName)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to update permission condition: %v", result.Error)
+	}
 
-	if err := db.Exec(query, permissionID).Error; err != nil {
-		return fmt.Errorf("failed to delete permission: %v", err)
+	if result.RowsAffected == 0 {
+		return errRbac.ErrResourceNotFound
 	}
 
-	fmt.Printf("🗑️ Deleted permission %d\n", permissionID)
 	return nil
 }
 
 // ----------------------------
-// USER
+// Permissions
 // ----------------------------
 
-// AssignRole assigns a role to a user by staff ID
-func AssignUserRole(staffID string, roleID int) error {
+const permissionSelect = `
+	SELECT p.id, p.action_id, a.name AS action, p.resource_id, res.name AS resource,
+		CONCAT(a.name, ':', res.name) AS name,
+		(SELECT COUNT(*) FROM role_permissions rp WHERE rp.permission_id = p.id) AS role_count,
+		p.created_at, p.updated_at
+	FROM permissions p
+	JOIN actions a ON a.id = p.action_id
+	JOIN resources res ON res.id = p.resource_id
+`
+
+// permissionError maps constraint failures on permissions.
+func permissionError(err error, action string) error {
+	switch {
+	case strings.Contains(err.Error(), `unique constraint "unique_resource_action"`):
+		return errRbac.ErrResourceNameTaken
+	case strings.Contains(err.Error(), "foreign key"):
+		return errRbac.ErrResourceNotFound
+	}
+	return fmt.Errorf("failed to %s: %v", action, err)
+}
 
+// CreatePermission adds an action/resource pair to the catalogue.
+func CreatePermission(actionID, resourceID int) (*mdlRbac.Permission, error) {
 	db := &config.DBConnList[0]
 
-	query := `
-		UPDATE public.users SET role_id = ? WHERE staff_id = ?
-	`
-	// exist, err := helperScript.CheckUserExists(staffID)
+	var id int
+	query := `INSERT INTO permissions (resource_id, action_id) VALUES (?, ?) RETURNING id`
+	if err := db.Raw(query, resourceID, actionID).Scan(&id).Error; err != nil {
+		return nil, permissionError(err, "create permission")
+	}
 
-	// if err != nil {
-	// 	return fmt.Errorf("failed to check user existence: %v", err)
-	// }
+	return GetPermissionByID(id)
+}
 
-	// if !exist {
-	// 	return fmt.Errorf("user with staff ID %v does not exist", staffID)
-	// }
+// PermissionSorting lists the sort fields of FetchPermissions.
+var PermissionSorting = pagination.Sorting{
+	Columns: map[string]string{
+		"id": "p.id", "name": "CONCAT(a.name, ':', res.name)", "action": "a.name",
+		"resource": "res.name", "created_at": "p.created_at",
+	},
+	Default: "name",
+}
 
-	result := db.Exec(query, roleID, staffID)
+// FetchPermissions returns one page of the catalogue; q searches the
+// formatted action:resource names.
+func FetchPermissions(p pagination.Params) ([]mdlRbac.Permission, int64, error) {
+	permissions := []mdlRbac.Permission{}
+	query := permissionSelect + ` WHERE ?::text = '' OR CONCAT(a.name, ':', res.name) ILIKE ?`
+	total, err := listPage(&permissions, query, []interface{}{p.Search, p.Like()}, p, "p.id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch permissions: %v", err)
+	}
 
-	if result.Error != nil {
-		return fmt.Errorf("failed to update resource: %v", result.Error)
+	return permissions, total, nil
+}
+
+// GetPermissionByID - Get single permission by ID
+func GetPermissionByID(id int) (*mdlRbac.Permission, error) {
+	db := &config.DBConnList[0]
+
+	var permission mdlRbac.Permission
+	if err := db.Raw(permissionSelect+` WHERE p.id = ?`, id).Scan(&permission).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch permission: %v", err)
 	}
 
-	if result.RowsAffected == 0 {
-		return errRbac.ErrResourceNotFound
+	if permission.ID == 0 {
+		return nil, errRbac.ErrResourceNotFound
 	}
 
-	fmt.Printf("✅ Assigned role %d to staff ID %v\n", roleID, staffID)
-	return nil
+	return &permission, nil
 }
 
-func FetchAllUserRoles() ([]mdlRbac.RBACItemResponse, error) {
-
+// UpdatePermission points a permission at another action/resource pair. A
+// permission granted to a role is in use: retargeting it would silently
+// change what the role grants, so assign the new pair instead.
+func UpdatePermission(permissionID, actionID, resourceID int) (*mdlRbac.Permission, error) {
 	db := &config.DBConnList[0]
 
-	var userRoles []mdlRbac.RBACItemResponse
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var inUse bool
+		query := `SELECT EXISTS (SELECT 1 FROM role_permissions WHERE permission_id = ?)`
+		if err := tx.Raw(query, permissionID).Scan(&inUse).Error; err != nil {
+			return fmt.Errorf("failed to check permission usage: %v", err)
+		}
+		if inUse {
+			return errRbac.ErrResourceInUse
+		}
 
-	query := `
-		SELECT * FROM roles
-	`
-	if err := db.Raw(query).Scan(&userRoles).Error; err != nil {
-		log.Printf("❌ Failed to fetch user roled: %v", err)
+		query = `UPDATE permissions SET resource_id = ?, action_id = ?, updated_at = NOW() WHERE id = ?`
+		result := tx.Exec(query, resourceID, actionID, permissionID)
+		if result.Error != nil {
+			return permissionError(result.Error, "update permission")
+		}
+
+		if result.RowsAffected == 0 {
+			return errRbac.ErrResourceNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return userRoles, nil
+	return GetPermissionByID(permissionID)
+}
+
+// DeletePermission removes a permission that no role grants.
+func DeletePermission(permissionID int) error {
+	db := &config.DBConnList[0]
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var inUse bool
+		query := `SELECT EXISTS (SELECT 1 FROM role_permissions WHERE permission_id = ?)`
+		if err := tx.Raw(query, permissionID).Scan(&inUse).Error; err != nil {
+			return fmt.Errorf("failed to check permission usage: %v", err)
+		}
+		if inUse {
+			return errRbac.ErrResourceInUse
+		}
+
+		result := tx.Exec(`DELETE FROM permissions WHERE id = ?`, permissionID)
+		if result.Error != nil {
+			if strings.Contains(result.Error.Error(), "foreign key") {
+				return errRbac.ErrResourceInUse
+			}
+			return fmt.Errorf("failed to delete permission: %v", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return errRbac.ErrResourceNotFound
+		}
+
+		return nil
+	})
 }
 
 // ----------------------------
-// ACTION
+// USER
 // ----------------------------
 
-// CreateAction - Create a new action in database
-func CreateAction(name, description string) error {
-	db := &config.DBConnList[0]
+// AssignUserRole replaces the user's primary role (users.role_id) and gives
+// the user its global user_roles row, which is what grants it. The old
+// primary role's global row is dropped; other roles are left alone.
+// validFrom and validUntil bound the assignment; when both are nil an
+// existing window is kept. Superuser roles also need allowSuperuser, i.e.
+// update:superuser.
+func AssignUserRole(staffID string, roleID int, validFrom, validUntil *time.Time, allowSuperuser bool) error {
 
-	query := `INSERT INTO actions (name, description) VALUES (?, ?)`
+	db := &config.DBConnList[0]
 
-	if err := db.Debug().Exec(query, name, description).Error; err != nil {
-		if strings.Contains(err.Error(), `unique constraint "actions_name_key"`) {
-			return errRbac.ErrResourceNameTaken
-		}
-		return fmt.Errorf("failed to create action: %v", err)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return assignUserRole(tx, staffID, roleID, validFrom, validUntil, allowSuperuser)
+	})
+	if err != nil {
+		return err
 	}
 
+	log.Printf("Assigned role %d to staff ID %v", roleID, staffID)
 	return nil
 }
 
-// GetActions - Get all actions from database
-func GetActions() ([]mdlRbac.RBACItemResponse, error) {
-	db := &config.DBConnList[0]
-	var actions []mdlRbac.RBACItemResponse
+func assignUserRole(tx *gorm.DB, staffID string, roleID int, validFrom, validUntil *time.Time, allowSuperuser bool) error {
+	var user struct {
+		ID     int
+		RoleID *int
+	}
+	if err := tx.Raw(`SELECT id, role_id FROM users WHERE staff_id = ? FOR UPDATE`, staffID).Scan(&user).Error; err != nil {
+		return fmt.Errorf("failed to fetch user: %v", err)
+	}
+	if user.ID == 0 {
+		return errRbac.ErrResourceNotFound
+	}
+
+	if err := assignableRole(tx, roleID, allowSuperuser); err != nil {
+		return err
+	}
+
+	guard, err := guardSoD(tx, []int{user.ID}, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Exec(`UPDATE users SET role_id = ? WHERE id = ?`, roleID, user.ID).Error; err != nil {
+		if strings.Contains(err.Error(), "foreign key") {
+			return errRbac.ErrResourceNotFound
+		}
+		return fmt.Errorf("failed to update user role: %v", err)
+	}
 
-	query := `SELECT id, name, description, created_at, updated_at FROM actions ORDER BY id ASC`
+	if user.RoleID != nil && *user.RoleID != roleID {
+		query := `DELETE FROM user_roles WHERE user_id = ? AND role_id = ? AND institution_code IS NULL`
+		if err := tx.Exec(query, user.ID, *user.RoleID).Error; err != nil {
+			return fmt.Errorf("failed to drop previous role: %v", err)
+		}
+	}
 
-	if err := db.Raw(query).Scan(&actions).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch actions: %v", err)
+	// Reassigning without a window keeps the current one, unless it has
+	// already run out and the sweeper has not removed the row yet.
+	query := `
+		INSERT INTO user_roles (user_id, role_id, valid_from, valid_until) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, role_id, COALESCE(institution_code, ''))
+		DO UPDATE SET valid_from = EXCLUDED.valid_from, valid_until = EXCLUDED.valid_until
+		WHERE EXCLUDED.valid_from IS NOT NULL OR EXCLUDED.valid_until IS NOT NULL
+			OR user_roles.valid_until <= now()
+	`
+	if err := tx.Exec(query, user.ID, roleID, validFrom, validUntil).Error; err != nil {
+		return fmt.Errorf("failed to assign role: %v", err)
 	}
 
-	return actions, nil
+	return guard.check()
 }
 
-// GetActionByID - Get single action by ID
-func GetActionByID(id int) (*mdlRbac.RBACItemResponse, error) {
+// GetUserRoles lists every role held by the user, with the institution each
+// assignment is limited to.
+func GetUserRoles(staffID string) ([]mdlRbac.UserRoleItem, error) {
 	db := &config.DBConnList[0]
-	var action mdlRbac.RBACItemResponse
-
-	query := `SELECT id, name, description, created_at, updated_at FROM actions WHERE id = ?`
 
-	if err := db.Raw(query, id).Scan(&action).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch action: %v", err)
+	userID, err := userIDByStaffID(staffID)
+	if err != nil {
+		return nil, err
 	}
 
-	if action.ID == 0 {
-		return nil, errRbac.ErrResourceNotFound
+	var roles []mdlRbac.UserRoleItem
+	query := `
+		SELECT r.id, r.name, r.description, r.is_superuser, r.parent_role_id, r.created_at, r.updated_at,
+			ura.institution_code, ura.valid_from, ura.valid_until
+		FROM user_role_assignments(?) ura
+		JOIN roles r ON r.id = ura.role_id
+		ORDER BY r.name, ura.institution_code, ura.valid_from
+	`
+	if err := db.Raw(query, userID).Scan(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch user roles: %v", err)
 	}
 
-	return &action, nil
+	return roles, nil
 }
 
-// UpdateAction - Update an action in database
-func UpdateAction(id int, name, description string) error {
+// AddUserRole gives the user one more role, limited to institutionCode when
+// it is not nil. Superuser roles also need allowSuperuser.
+func AddUserRole(staffID string, roleID int, institutionCode *string, validFrom, validUntil *time.Time, allowSuperuser bool) error {
 	db := &config.DBConnList[0]
 
-	query := `UPDATE actions SET name = ?, description = ?, updated_at = NOW() WHERE id = ?`
-	result := db.Exec(query, name, description, id)
+	userID, err := userIDByStaffID(staffID)
+	if err != nil {
+		return err
+	}
 
-	if result.Error != nil {
-		if strings.Contains(result.Error.Error(), `unique constraint "actions_name_key"`) {
-			return errRbac.ErrResourceNameTaken
+	// An expired row the sweeper has not removed yet is renewed in place.
+	query := `
+		INSERT INTO user_roles (user_id, role_id, institution_code, valid_from, valid_until)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, role_id, COALESCE(institution_code, ''))
+		DO UPDATE SET valid_from = EXCLUDED.valid_from, valid_until = EXCLUDED.valid_until
+		WHERE user_roles.valid_until IS NOT NULL AND user_roles.valid_until <= now()
+	`
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := assignableRole(tx, roleID, allowSuperuser); err != nil {
+			return err
 		}
-		return fmt.Errorf("failed to update action: %v", result.Error)
-	}
 
-	if result.RowsAffected == 0 {
-		return errRbac.ErrResourceNotFound
-	}
+		guard, err := guardSoD(tx, []int{userID}, nil)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		result := tx.Exec(query, userID, roleID, institutionCode, validFrom, validUntil)
+		if result.Error != nil {
+			if strings.Contains(result.Error.Error(), "foreign key") {
+				return errRbac.ErrResourceNotFound
+			}
+			return fmt.Errorf("failed to add user role: %v", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return errRbac.ErrRoleAssigned
+		}
+
+		return guard.check()
+	})
 }
 
-// DeleteAction - Delete an action from database
-func DeleteAction(id int) error {
+// RemoveUserRole takes a role away from the user, for one institution when
+// institutionCode is set or the global assignment otherwise. Once no
+// assignment of the primary role is left, users.role_id is cleared too.
+func RemoveUserRole(staffID string, roleID int, institutionCode *string) error {
 	db := &config.DBConnList[0]
 
-	query := `DELETE FROM actions WHERE id = ?`
-	result := db.Exec(query, id)
+	userID, err := userIDByStaffID(staffID)
+	if err != nil {
+		return err
+	}
 
-	if result.Error != nil {
-		if strings.Contains(result.Error.Error(), "foreign key") {
-			return errRbac.ErrResourceInUse
+	return db.Transaction(func(tx *gorm.DB) error {
+		query := `
+			DELETE FROM user_roles
+			WHERE user_id = ? AND role_id = ? AND institution_code IS NOT DISTINCT FROM ?
+		`
+		deleted := tx.Exec(query, userID, roleID, institutionCode)
+		if deleted.Error != nil {
+			return fmt.Errorf("failed to remove user role: %v", deleted.Error)
+		}
+
+		if deleted.RowsAffected == 0 {
+			return errRbac.ErrResourceNotFound
 		}
-		return fmt.Errorf("failed to delete action: %v", result.Error)
+		return clearStalePrimaryRoles(tx, &userID)
+	})
+}
+
+// clearStalePrimaryRoles clears users.role_id where the user no longer holds
+// that role in user_roles, for one user or for everyone when userID is nil.
+func clearStalePrimaryRoles(tx *gorm.DB, userID *int) error {
+	query := `
+		UPDATE users u SET role_id = NULL
+		WHERE u.role_id IS NOT NULL AND (?::int IS NULL OR u.id = ?)
+			AND NOT EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = u.id AND ur.role_id = u.role_id)
+	`
+	if err := tx.Exec(query, userID, userID).Error; err != nil {
+		return fmt.Errorf("failed to clear primary role: %v", err)
 	}
+	return nil
+}
 
-	if result.RowsAffected == 0 {
+// activeRole fails with ErrResourceNotFound unless the role exists and is
+// not archived.
+func activeRole(tx *gorm.DB, roleID int) error {
+	var exists bool
+	if err := tx.Raw(`SELECT EXISTS (SELECT 1 FROM roles WHERE id = ? AND archived_at IS NULL)`, roleID).Scan(&exists).Error; err != nil {
+		return fmt.Errorf("failed to check role: %v", err)
+	}
+	if !exists {
 		return errRbac.ErrResourceNotFound
 	}
-
 	return nil
 }
 
-// ----------------------------
-// Resources
-// ----------------------------
+// assignableRole is activeRole for role assignments: a superuser role
+// bypasses every permission check, so handing one out also needs
+// allowSuperuser, the same as setting the flag on the role.
+func assignableRole(tx *gorm.DB, roleID int, allowSuperuser bool) error {
+	var role struct {
+		ID          int
+		IsSuperuser bool
+	}
+	query := `SELECT id, is_superuser FROM roles WHERE id = ? AND archived_at IS NULL`
+	if err := tx.Raw(query, roleID).Scan(&role).Error; err != nil {
+		return fmt.Errorf("failed to check role: %v", err)
+	}
+	if role.ID == 0 {
+		return errRbac.ErrResourceNotFound
+	}
+	if role.IsSuperuser && !allowSuperuser {
+		return fmt.Errorf("%w: role %d", errRbac.ErrSuperuserDenied, roleID)
+	}
+	return nil
+}
 
-// CreateResource - Create a new resource in database
-func CreateResource(name, description string) error {
+func userIDByStaffID(staffID string) (int, error) {
 	db := &config.DBConnList[0]
 
-	query := `INSERT INTO resources (name, description) VALUES (?, ?)`
+	var userID int
+	if err := db.Raw(`SELECT id FROM users WHERE staff_id = ?`, staffID).Scan(&userID).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch user: %v", err)
+	}
+	if userID == 0 {
+		return 0, errRbac.ErrResourceNotFound
+	}
+	return userID, nil
+}
 
-	if err := db.Exec(query, name, description).Error; err != nil {
-		if strings.Contains(err.Error(), `unique constraint "resources_name_key"`) {
-			return errRbac.ErrResourceNameTaken
-		}
-		return fmt.Errorf("failed to create resource: %v", err)
+// RoleSorting lists the sort fields of FetchAllUserRoles.
+var RoleSorting = pagination.Sorting{
+	Columns: map[string]string{"id": "id", "name": "name", "created_at": "created_at", "updated_at": "updated_at"},
+	Default: "id",
+}
+
+// FetchAllUserRoles returns one page of roles; q searches role names.
+// Archived roles are only listed when includeArchived is set.
+func FetchAllUserRoles(p pagination.Params, includeArchived bool) ([]mdlRbac.Role, int64, error) {
+	userRoles := []mdlRbac.Role{}
+
+	query := `
+		SELECT id, name, description, is_superuser, parent_role_id, created_at, updated_at, archived_at FROM roles
+		WHERE (?::text = '' OR name ILIKE ?) AND (? OR archived_at IS NULL)
+	`
+	total, err := listPage(&userRoles, query, []interface{}{p.Search, p.Like(), includeArchived}, p, "id")
+	if err != nil {
+		log.Printf("❌ Failed to fetch user roled: %v", err)
+		return nil, 0, err
 	}
 
-	return nil
+	return userRoles, total, nil
 }
 
-// GetResources - Get all resources from database
-func GetResources() ([]mdlRbac.RBACItemResponse, error) {
+// CreateRole - Create a new role in database
+func CreateRole(name, description string) (*mdlRbac.Role, error) {
 	db := &config.DBConnList[0]
-	var resources []mdlRbac.RBACItemResponse
 
-	query := `SELECT id, name, description, created_at, updated_at FROM resources ORDER BY id ASC`
+	var role mdlRbac.Role
+	query := `
+		INSERT INTO roles (name, description) VALUES (?, ?)
+		RETURNING id, name, description, is_superuser, parent_role_id, created_at, updated_at
+	`
 
-	if err := db.Raw(query).Scan(&resources).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch resources: %v", err)
+	if err := db.Raw(query, name, description).Scan(&role).Error; err != nil {
+		if strings.Contains(err.Error(), `unique constraint "roles_name_key"`) {
+			return nil, errRbac.ErrResourceNameTaken
+		}
+		return nil, fmt.Errorf("failed to create role: %v", err)
 	}
 
-	return resources, nil
+	return &role, nil
 }
 
-// GetResourceByID - Get single resource by ID
-func GetResourceByID(id int) (*mdlRbac.RBACItemResponse, error) {
+// GetRoleByID - Get single role by ID
+func GetRoleByID(id int) (*mdlRbac.Role, error) {
 	db := &config.DBConnList[0]
-	var resource mdlRbac.RBACItemResponse
+	var role mdlRbac.Role
 
-	query := `SELECT id, name, description, created_at, updated_at FROM resources WHERE id = ?`
+	query := `SELECT id, name, description, is_superuser, parent_role_id, created_at, updated_at, archived_at FROM roles WHERE id = ?`
 
-	if err := db.Raw(query, id).Scan(&resource).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch resource: %v", err)
+	if err := db.Raw(query, id).Scan(&role).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch role: %v", err)
 	}
 
-	// Check if resource exists
-	if resource.ID == 0 {
-		return nil, fmt.Errorf("resource with ID %d not found", id)
+	if role.ID == 0 {
+		return nil, errRbac.ErrResourceNotFound
 	}
 
-	return &resource, nil
+	return &role, nil
 }
 
-// UpdateResource - Update a resource in database
+// UpdateRole - Rename or describe a role
+func UpdateRole(id int, name, description string) error {
+	db := &config.DBConnList[0]
+
+	query := `UPDATE roles SET name = ?, description = ?, updated_at = NOW() WHERE id = ?`
+	result := db.Exec(query, name, description, id)
+
+	if result.Error != nil {
+		if strings.Contains(result.Error.Error(), `unique constraint "roles_name_key"`) {
+			return errRbac.ErrResourceNameTaken
+		}
+		return fmt.Errorf("failed to update role: %v", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errRbac.ErrResourceNotFound
+	}
+
+	return nil
+}
+
+// DeleteRole - Archive a role. Its grants, assignments and child links are
+// kept but stop taking effect until RestoreRole.
+func DeleteRole(id int) error {
+	return archiveItem("roles", "role", id, true)
+}
+
+// RestoreRole brings back an archived role together with everything that
+// depended on it.
+func RestoreRole(id int) error {
+	return archiveItem("roles", "role", id, false)
+}
+
+// SetRoleSuperuser flags or unflags a role as superuser and audits the
+// change in the same transaction.
+func SetRoleSuperuser(roleID int, isSuperuser bool, actor string) error {
+	db := &config.DBConnList[0]
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		query := `UPDATE roles SET is_superuser = ?, updated_at = NOW() WHERE id = ?`
+		result := tx.Exec(query, isSuperuser, roleID)
+
+		if result.Error != nil {
+			return fmt.Errorf("failed to update role: %v", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return errRbac.ErrResourceNotFound
+		}
+
+		event := mdlRbac.AuditSuperuserRevoked
+		if isSuperuser {
+			event = mdlRbac.AuditSuperuserGranted
+		}
+		return recordAudit(tx, event, actor, strconv.Itoa(roleID), nil)
+	})
+}
+
+// SetRoleParent makes the role inherit from parentID, or detaches it when
+// parentID is nil. Parents that already inherit from the role are rejected.
+func SetRoleParent(roleID int, parentID *int) error {
+	db := &config.DBConnList[0]
+
+	if parentID != nil {
+		var exists bool
+		if err := db.Raw(`SELECT EXISTS (SELECT 1 FROM roles WHERE id = ? AND archived_at IS NULL)`, *parentID).Scan(&exists).Error; err != nil {
+			return fmt.Errorf("failed to check parent role: %v", err)
+		}
+		if !exists {
+			return errRbac.ErrResourceNotFound
+		}
+
+		var cycle bool
+		query := `SELECT EXISTS (SELECT 1 FROM role_ancestors(?) WHERE role_id = ?)`
+		if err := db.Raw(query, *parentID, roleID).Scan(&cycle).Error; err != nil {
+			return fmt.Errorf("failed to check role hierarchy: %v", err)
+		}
+		if cycle {
+			return errRbac.ErrRoleCycle
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// A new parent changes the effective grants of the whole subtree
+		guard, err := guardRoleSoD(tx, roleID)
+		if err != nil {
+			return err
+		}
+
+		query := `UPDATE roles SET parent_role_id = ?, updated_at = NOW() WHERE id = ?`
+		result := tx.Exec(query, parentID, roleID)
+
+		if result.Error != nil {
+			// The trigger catches cycles created by concurrent updates
+			if strings.Contains(result.Error.Error(), "role hierarchy cycle") {
+				return errRbac.ErrRoleCycle
+			}
+			return fmt.Errorf("failed to update role: %v", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return errRbac.ErrResourceNotFound
+		}
+
+		return guard.check()
+	})
+}
+
+// ExpireUserRoles deletes every time-bound assignment whose valid_until has
+// passed and audits each revocation in the same transaction. Concurrent
+// sweepers on other instances never revoke the same row twice.
+func ExpireUserRoles() ([]mdlRbac.ExpiredUserRole, error) {
+	db := &config.DBConnList[0]
+
+	var expired []mdlRbac.ExpiredUserRole
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := `
+			WITH deleted AS (
+				DELETE FROM user_roles
+				WHERE valid_until IS NOT NULL AND valid_until <= now()
+				RETURNING user_id, role_id, institution_code, valid_until
+			)
+			SELECT u.username, u.staff_id, d.role_id, r.name AS role_name, d.institution_code, d.valid_until
+			FROM deleted d
+			JOIN users u ON u.id = d.user_id
+			JOIN roles r ON r.id = d.role_id
+		`
+		if err := tx.Raw(query).Scan(&expired).Error; err != nil {
+			return fmt.Errorf("failed to expire user roles: %v", err)
+		}
+		if len(expired) > 0 {
+			if err := clearStalePrimaryRoles(tx, nil); err != nil {
+				return err
+			}
+		}
+
+		for _, item := range expired {
+			detail := map[string]interface{}{
+				"role_id":          item.RoleID,
+				"role":             item.RoleName,
+				"institution_code": item.InstitutionCode,
+				"valid_until":      item.ValidUntil,
+			}
+			if err := recordAudit(tx, mdlRbac.AuditRoleExpired, "system", item.StaffID, detail); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return expired, nil
+}
+
+// ----------------------------
+// AUDIT
+// ----------------------------
+
+// RecordAudit writes one entry to rbac_audit_logs.
+func RecordAudit(event, actor, target string, detail map[string]interface{}) error {
+	return recordAudit(&config.DBConnList[0], event, actor, target, detail)
+}
+
+func recordAudit(db *gorm.DB, event, actor, target string, detail map[string]interface{}) error {
+	if detail == nil {
+		detail = map[string]interface{}{}
+	}
+	detailJSON, _ := json.Marshal(detail)
+
+	query := `INSERT INTO rbac_audit_logs (event, actor, target, detail) VALUES (?, ?, ?, ?::jsonb)`
+
+	if err := db.Exec(query, event, actor, target, string(detailJSON)).Error; err != nil {
+		return fmt.Errorf("failed to record audit entry: %v", err)
+	}
+
+	return nil
+}
+
+// ----------------------------
+// ACTION
+// ----------------------------
+
+// CreateAction - Create a new action in database
+func CreateAction(name, description string) error {
+	db := &config.DBConnList[0]
+
+	query := `INSERT INTO actions (name, description) VALUES (?, ?)`
+
+	if err := db.Debug().Exec(query, name, description).Error; err != nil {
+		if strings.Contains(err.Error(), `unique constraint "actions_name_key"`) {
+			return errRbac.ErrResourceNameTaken
+		}
+		return fmt.Errorf("failed to create action: %v", err)
+	}
+
+	return nil
+}
+
+// ActionSorting lists the sort fields of GetActions.
+var ActionSorting = pagination.Sorting{
+	Columns: map[string]string{"id": "id", "name": "name", "created_at": "created_at", "updated_at": "updated_at"},
+	Default: "id",
+}
+
+// GetActions - Get one page of actions; q searches names. Archived actions
+// are only listed when includeArchived is set.
+func GetActions(p pagination.Params, includeArchived bool) ([]mdlRbac.RBACItemResponse, int64, error) {
+	actions := []mdlRbac.RBACItemResponse{}
+
+	query := `
+		SELECT id, name, description, created_at, updated_at, archived_at FROM actions
+		WHERE (?::text = '' OR name ILIKE ?) AND (? OR archived_at IS NULL)
+	`
+
+	total, err := listPage(&actions, query, []interface{}{p.Search, p.Like(), includeArchived}, p, "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch actions: %v", err)
+	}
+
+	return actions, total, nil
+}
+
+// GetActionByID - Get single action by ID
+func GetActionByID(id int) (*mdlRbac.RBACItemResponse, error) {
+	db := &config.DBConnList[0]
+	var action mdlRbac.RBACItemResponse
+
+	query := `SELECT id, name, description, created_at, updated_at, archived_at FROM actions WHERE id = ?`
+
+	if err := db.Raw(query, id).Scan(&action).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch action: %v", err)
+	}
+
+	if action.ID == 0 {
+		return nil, errRbac.ErrResourceNotFound
+	}
+
+	return &action, nil
+}
+
+// UpdateAction - Update an action in database
+func UpdateAction(id int, name, description string) error {
+	db := &config.DBConnList[0]
+
+	query := `UPDATE actions SET name = ?, description = ?, updated_at = NOW() WHERE id = ?`
+	result := db.Exec(query, name, description, id)
+
+	if result.Error != nil {
+		if strings.Contains(result.Error.Error(), `unique constraint "actions_name_key"`) {
+			return errRbac.ErrResourceNameTaken
+		}
+		return fmt.Errorf("failed to update action: %v", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errRbac.ErrResourceNotFound
+	}
+
+	return nil
+}
+
+// DeleteAction - Archive an action. Grants and approved requests on it are
+// kept but stop taking effect until RestoreAction.
+func DeleteAction(id int) error {
+	return archiveItem("actions", "action", id, true)
+}
+
+// RestoreAction brings back an archived action together with its grants.
+func RestoreAction(id int) error {
+	return archiveItem("actions", "action", id, false)
+}
+
+// ----------------------------
+// Resources
+// ----------------------------
+
+// CreateResource - Create a new resource in database
+func CreateResource(name, description string) error {
+	db := &config.DBConnList[0]
+
+	query := `INSERT INTO resources (name, description) VALUES (?, ?)`
+
+	if err := db.Exec(query, name, description).Error; err != nil {
+		if strings.Contains(err.Error(), `unique constraint "resources_name_key"`) {
+			return errRbac.ErrResourceNameTaken
+		}
+		return fmt.Errorf("failed to create resource: %v", err)
+	}
+
+	return nil
+}
+
+// ResourceSorting lists the sort fields of GetResources.
+var ResourceSorting = pagination.Sorting{
+	Columns: map[string]string{"id": "id", "name": "name", "created_at": "created_at", "updated_at": "updated_at"},
+	Default: "id",
+}
+
+// GetResources - Get one page of resources; q searches names. Archived
+// resources are only listed when includeArchived is set.
+func GetResources(p pagination.Params, includeArchived bool) ([]mdlRbac.RBACItemResponse, int64, error) {
+	resources := []mdlRbac.RBACItemResponse{}
+
+	query := `
+		SELECT id, name, description, created_at, updated_at, archived_at FROM resources
+		WHERE (?::text = '' OR name ILIKE ?) AND (? OR archived_at IS NULL)
+	`
+
+	total, err := listPage(&resources, query, []interface{}{p.Search, p.Like(), includeArchived}, p, "id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch resources: %v", err)
+	}
+
+	return resources, total, nil
+}
+
+// GetResourceByID - Get single resource by ID
+func GetResourceByID(id int) (*mdlRbac.RBACItemResponse, error) {
+	db := &config.DBConnList[0]
+	var resource mdlRbac.RBACItemResponse
+
+	query := `SELECT id, name, description, created_at, updated_at, archived_at FROM resources WHERE id = ?`
+
+	if err := db.Raw(query, id).Scan(&resource).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch resource: %v", err)
+	}
+
+	// Check if resource exists
+	if resource.ID == 0 {
+		return nil, fmt.Errorf("resource with ID %d not found", id)
+	}
+
+	return &resource, nil
+}
+
+// UpdateResource - Update a resource in database
 func UpdateResource(id int, name, description string) error {
 	db := &config.DBConnList[0]
 
@@ -393,17 +1161,58 @@ func UpdateResource(id int, name, description string) error {
 	return nil
 }
 
+// DeleteResource - Archive a resource. Grants and approved requests on it
+// are kept but stop taking effect until RestoreResource.
 func DeleteResource(id int) error {
+	return archiveItem("resources", "resource", id, true)
+}
+
+// RestoreResource brings back an archived resource together with its grants.
+func RestoreResource(id int) error {
+	return archiveItem("resources", "resource", id, false)
+}
+
+// archiveItem sets or clears archived_at on one row of table. Archiving an
+// archived row, or restoring an active one, is ErrResourceNotFound. A restore
+// brings dependent grants and assignments back, so it is guarded against
+// separation-of-duties violations like any other grant.
+func archiveItem(table, kind string, id int, archive bool) error {
 	db := &config.DBConnList[0]
+	if archive {
+		return archiveRow(db, table, kind, id, true)
+	}
 
-	query := `DELETE FROM resources WHERE id = ?`
-	result := db.Exec(query, id)
+	return db.Transaction(func(tx *gorm.DB) error {
+		var guard *sodGuard
+		var err error
+		if table == "roles" {
+			guard, err = guardRoleSoD(tx, id)
+		} else {
+			guard, err = guardAllSoD(tx)
+		}
+		if err != nil {
+			return err
+		}
 
-	if result.Error != nil {
-		if strings.Contains(result.Error.Error(), "foreign key") {
-			return errRbac.ErrResourceInUse
+		if err := archiveRow(tx, table, kind, id, false); err != nil {
+			return err
 		}
-		return fmt.Errorf("failed to delete resource: %v", result.Error)
+
+		return guard.check()
+	})
+}
+
+func archiveRow(db *gorm.DB, table, kind string, id int, archive bool) error {
+	query := `UPDATE ` + table + ` SET archived_at = now(), updated_at = NOW() WHERE id = ? AND archived_at IS NULL`
+	verb := "archive"
+	if !archive {
+		query = `UPDATE ` + table + ` SET archived_at = NULL, updated_at = NOW() WHERE id = ? AND archived_at IS NOT NULL`
+		verb = "restore"
+	}
+
+	result := db.Exec(query, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to %s %s: %v", verb, kind, result.Error)
 	}
 
 	if result.RowsAffected == 0 {
@@ -412,3 +1221,1509 @@ func DeleteResource(id int) error {
 
 	return nil
 }
+
+// ----------------------------
+// ACCESS REQUESTS
+// ----------------------------
+
+const accessRequestSelect = `
+	SELECT ar.id, ar.requester_id, u.username AS requester, ar.role_id, r.name AS role_name,
+		a.name AS action, res.name AS resource, ar.institution_code, ar.reason, ar.duration_minutes,
+		ar.status, d.username AS decided_by, ar.decided_at, ar.decision_note, ar.valid_from,
+		ar.valid_until, ar.created_at, ar.updated_at
+	FROM access_requests ar
+	JOIN users u ON u.id = ar.requester_id
+	LEFT JOIN roles r ON r.id = ar.role_id
+	LEFT JOIN actions a ON a.id = ar.action_id
+	LEFT JOIN resources res ON res.id = ar.resource_id
+	LEFT JOIN users d ON d.id = ar.decided_by
+`
+
+// CreateAccessRequest files a pending request for a role or a permission.
+func CreateAccessRequest(requesterID int, requester string, req mdlRbac.AccessRequestCreate) (*mdlRbac.AccessRequest, error) {
+	db := &config.DBConnList[0]
+
+	var id int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var actionID, resourceID *int
+		if req.RoleID == nil {
+			var ids struct {
+				ActionID   int
+				ResourceID int
+			}
+			query := `
+				SELECT a.id AS action_id, r.id AS resource_id
+				FROM actions a, resources r
+				WHERE a.name = ? AND r.name = ? AND a.archived_at IS NULL AND r.archived_at IS NULL
+			`
+			if err := tx.Raw(query, req.ActionName, req.ResourceName).Scan(&ids).Error; err != nil {
+				return fmt.Errorf("failed to look up permission: %v", err)
+			}
+			if ids.ActionID == 0 {
+				return errRbac.ErrResourceNotFound
+			}
+			actionID, resourceID = &ids.ActionID, &ids.ResourceID
+		} else if err := activeRole(tx, *req.RoleID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO access_requests (requester_id, role_id, action_id, resource_id, institution_code, reason, duration_minutes)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			RETURNING id
+		`
+		if err := tx.Raw(query, requesterID, req.RoleID, actionID, resourceID, req.InstitutionCode, req.Reason, req.DurationMinutes).Scan(&id).Error; err != nil {
+			if strings.Contains(err.Error(), "foreign key") {
+				return errRbac.ErrResourceNotFound
+			}
+			return fmt.Errorf("failed to create access request: %v", err)
+		}
+
+		detail := map[string]interface{}{
+			"request_id":       id,
+			"role_id":          req.RoleID,
+			"action":           req.ActionName,
+			"resource":         req.ResourceName,
+			"institution_code": req.InstitutionCode,
+			"reason":           req.Reason,
+			"duration_minutes": req.DurationMinutes,
+		}
+		return recordAudit(tx, mdlRbac.AuditAccessRequested, requester, requester, detail)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetAccessRequest(id)
+}
+
+// GetAccessRequest returns one request with its role, permission and decider names.
+func GetAccessRequest(id int) (*mdlRbac.AccessRequest, error) {
+	db := &config.DBConnList[0]
+
+	var request mdlRbac.AccessRequest
+	if err := db.Raw(accessRequestSelect+` WHERE ar.id = ?`, id).Scan(&request).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch access request: %v", err)
+	}
+	if request.ID == 0 {
+		return nil, errRbac.ErrResourceNotFound
+	}
+
+	return &request, nil
+}
+
+// AccessRequestSorting lists the sort fields of ListAccessRequests; newest
+// first by default.
+var AccessRequestSorting = pagination.Sorting{
+	Columns: map[string]string{
+		"id": "ar.id", "created_at": "ar.created_at", "status": "ar.status", "valid_until": "ar.valid_until",
+	},
+	Default: "created_at",
+	Desc:    true,
+}
+
+// ListAccessRequests returns one page of requests, optionally filtered by
+// status and requester (0 for everyone); q searches the requester, role and
+// permission.
+func ListAccessRequests(status string, requesterID int, p pagination.Params) ([]mdlRbac.AccessRequest, int64, error) {
+	requests := []mdlRbac.AccessRequest{}
+	query := accessRequestSelect + `
+		WHERE (?::text = '' OR ar.status = ?) AND (? = 0 OR ar.requester_id = ?)
+			AND (?::text = '' OR u.username ILIKE ? OR r.name ILIKE ? OR CONCAT(a.name, ':', res.name) ILIKE ?)
+	`
+	like := p.Like()
+	args := []interface{}{status, status, requesterID, requesterID, p.Search, like, like, like}
+	total, err := listPage(&requests, query, args, p, "ar.id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch access requests: %v", err)
+	}
+
+	return requests, total, nil
+}
+
+// DecideAccessRequest approves or denies a pending request. An approved
+// request is in force from now for its duration. Requesters cannot decide
+// their own requests, and approving a superuser role needs allowSuperuser.
+func DecideAccessRequest(id, deciderID int, decider string, approve bool, note string, allowSuperuser bool) (*mdlRbac.AccessRequest, error) {
+	status, event := mdlRbac.AccessRequestDenied, mdlRbac.AuditAccessDenied
+	update := `
+		UPDATE access_requests
+		SET status = ?, decided_by = ?, decided_at = now(), decision_note = NULLIF(?, ''), updated_at = now()
+		WHERE id = ?
+	`
+	if approve {
+		status, event = mdlRbac.AccessRequestApproved, mdlRbac.AuditAccessApproved
+		update = `
+			UPDATE access_requests
+			SET status = ?, decided_by = ?, decided_at = now(), decision_note = NULLIF(?, ''), updated_at = now(),
+				valid_from = now(), valid_until = now() + make_interval(mins => duration_minutes)
+			WHERE id = ?
+		`
+	}
+
+	return transitionAccessRequest(id, mdlRbac.AccessRequestPending, event, decider, note, func(tx *gorm.DB, requesterID int) error {
+		if requesterID == deciderID {
+			return errRbac.ErrSelfApproval
+		}
+
+		if approve && !allowSuperuser {
+			var superuser bool
+			query := `SELECT EXISTS (SELECT 1 FROM access_requests ar JOIN roles r ON r.id = ar.role_id WHERE ar.id = ? AND r.is_superuser)`
+			if err := tx.Raw(query, id).Scan(&superuser).Error; err != nil {
+				return fmt.Errorf("failed to check requested role: %v", err)
+			}
+			if superuser {
+				return fmt.Errorf("%w: access request %d", errRbac.ErrSuperuserDenied, id)
+			}
+		}
+
+		guard, err := guardSoD(tx, []int{requesterID}, nil)
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec(update, status, deciderID, note, id).Error; err != nil {
+			return fmt.Errorf("failed to decide access request: %v", err)
+		}
+		return guard.check()
+	})
+}
+
+// CancelAccessRequest withdraws a pending request. Only its requester may
+// cancel it; anyone else gets ErrResourceNotFound.
+func CancelAccessRequest(id, requesterID int, requester string) (*mdlRbac.AccessRequest, error) {
+	return transitionAccessRequest(id, mdlRbac.AccessRequestPending, mdlRbac.AuditAccessCancelled, requester, "", func(tx *gorm.DB, owner int) error {
+		if owner != requesterID {
+			return errRbac.ErrResourceNotFound
+		}
+		query := `UPDATE access_requests SET status = ?, updated_at = now() WHERE id = ?`
+		return tx.Exec(query, mdlRbac.AccessRequestCancelled, id).Error
+	})
+}
+
+// RevokeAccessRequest ends an approved request before it expires.
+func RevokeAccessRequest(id int, actor string, note string) (*mdlRbac.AccessRequest, error) {
+	return transitionAccessRequest(id, mdlRbac.AccessRequestApproved, mdlRbac.AuditAccessRevoked, actor, note, func(tx *gorm.DB, _ int) error {
+		query := `
+			UPDATE access_requests
+			SET status = ?, valid_until = LEAST(valid_until, now()), updated_at = now()
+			WHERE id = ?
+		`
+		return tx.Exec(query, mdlRbac.AccessRequestRevoked, id).Error
+	})
+}
+
+// transitionAccessRequest locks the request, checks it is in status from,
+// applies the change and audits it in one transaction.
+func transitionAccessRequest(id int, from, event, actor, note string, apply func(tx *gorm.DB, requesterID int) error) (*mdlRbac.AccessRequest, error) {
+	db := &config.DBConnList[0]
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current struct {
+			RequesterID int
+			Requester   string
+			Status      string
+		}
+		query := `
+			SELECT ar.requester_id, u.username AS requester, ar.status
+			FROM access_requests ar
+			JOIN users u ON u.id = ar.requester_id
+			WHERE ar.id = ?
+			FOR UPDATE OF ar
+		`
+		if err := tx.Raw(query, id).Scan(&current).Error; err != nil {
+			return fmt.Errorf("failed to fetch access request: %v", err)
+		}
+		if current.RequesterID == 0 {
+			return errRbac.ErrResourceNotFound
+		}
+		if current.Status != from {
+			return errRbac.ErrRequestStatus
+		}
+
+		if err := apply(tx, current.RequesterID); err != nil {
+			return err
+		}
+
+		detail := map[string]interface{}{"request_id": id, "from": from}
+		if note != "" {
+			detail["note"] = note
+		}
+		return recordAudit(tx, event, actor, current.Requester, detail)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetAccessRequest(id)
+}
+
+// ExpireAccessRequests marks approved requests past valid_until as expired
+// and audits each one.
+func ExpireAccessRequests() ([]mdlRbac.AccessRequest, error) {
+	db := &config.DBConnList[0]
+
+	var expired []mdlRbac.AccessRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := `
+			WITH updated AS (
+				UPDATE access_requests
+				SET status = ?, updated_at = now()
+				WHERE status = ? AND valid_until <= now()
+				RETURNING id, requester_id, status, valid_until
+			)
+			SELECT updated.id, updated.requester_id, u.username AS requester, updated.status, updated.valid_until
+			FROM updated
+			JOIN users u ON u.id = updated.requester_id
+		`
+		if err := tx.Raw(query, mdlRbac.AccessRequestExpired, mdlRbac.AccessRequestApproved).Scan(&expired).Error; err != nil {
+			return fmt.Errorf("failed to expire access requests: %v", err)
+		}
+
+		for _, request := range expired {
+			detail := map[string]interface{}{"request_id": request.ID, "valid_until": request.ValidUntil}
+			if err := recordAudit(tx, mdlRbac.AuditAccessExpired, "system", request.Requester, detail); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return expired, nil
+}
+
+// ----------------------------
+// SEPARATION OF DUTIES
+// ----------------------------
+
+// CreateSoDRule stores a rule with its role and permission members.
+func CreateSoDRule(req mdlRbac.SoDRuleRequest) (*mdlRbac.SoDRule, error) {
+	db := &config.DBConnList[0]
+
+	var id int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := `INSERT INTO sod_rules (name, description) VALUES (?, ?) RETURNING id`
+		if err := tx.Raw(query, req.Name, req.Description).Scan(&id).Error; err != nil {
+			if strings.Contains(err.Error(), "duplicate key") {
+				return errRbac.ErrResourceNameTaken
+			}
+			return fmt.Errorf("failed to create SoD rule: %v", err)
+		}
+
+		for _, roleID := range req.RoleIDs {
+			query := `INSERT INTO sod_rule_members (rule_id, role_id) VALUES (?, ?) ON CONFLICT DO NOTHING`
+			if err := tx.Exec(query, id, roleID).Error; err != nil {
+				if strings.Contains(err.Error(), "foreign key") {
+					return fmt.Errorf("%w: role %d", errRbac.ErrResourceNotFound, roleID)
+				}
+				return fmt.Errorf("failed to add SoD rule role: %v", err)
+			}
+		}
+
+		for _, permission := range req.Permissions {
+			actionName, resourceName, _ := strings.Cut(permission, ":")
+			query := `
+				INSERT INTO sod_rule_members (rule_id, action_id, resource_id)
+				SELECT ?, a.id, r.id FROM actions a, resources r WHERE a.name = ? AND r.name = ?
+				ON CONFLICT DO NOTHING
+			`
+			result := tx.Exec(query, id, actionName, resourceName)
+			if result.Error != nil {
+				return fmt.Errorf("failed to add SoD rule permission: %v", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				var exists bool
+				query := `SELECT EXISTS (SELECT 1 FROM actions a, resources r WHERE a.name = ? AND r.name = ?)`
+				if err := tx.Raw(query, actionName, resourceName).Scan(&exists).Error; err != nil {
+					return fmt.Errorf("failed to check permission: %v", err)
+				}
+				if !exists {
+					return fmt.Errorf("%w: permission %s", errRbac.ErrResourceNotFound, permission)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := getSoDRules(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, errRbac.ErrResourceNotFound
+	}
+	return &rules[0], nil
+}
+
+// SoDRuleSorting lists the sort fields of GetSoDRules.
+var SoDRuleSorting = pagination.Sorting{
+	Columns: map[string]string{"id": "sr.id", "name": "sr.name", "created_at": "sr.created_at"},
+	Default: "name",
+}
+
+// GetSoDRules returns one page of rules with their members; q searches rule
+// names.
+func GetSoDRules(p pagination.Params) ([]mdlRbac.SoDRule, int64, error) {
+	var rows []sodRuleRow
+	query := sodRuleSelect + ` WHERE ?::text = '' OR sr.name ILIKE ?`
+	total, err := listPage(&rows, query, []interface{}{p.Search, p.Like()}, p, "sr.id")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch SoD rules: %v", err)
+	}
+
+	rules, err := sodRules(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rules, total, nil
+}
+
+// sodRuleSelect lists each rule with its role and permission labels as JSON
+// arrays, one row per rule so the list can be paged in SQL.
+const sodRuleSelect = `
+	SELECT sr.id, sr.name, sr.description, sr.created_at,
+		COALESCE((
+			SELECT json_agg(sm.label ORDER BY sm.label) FROM sod_members() sm
+			WHERE sm.rule_id = sr.id AND sm.role_id IS NOT NULL
+		), '[]'::json)::text AS roles,
+		COALESCE((
+			SELECT json_agg(sm.label ORDER BY sm.label) FROM sod_members() sm
+			WHERE sm.rule_id = sr.id AND sm.role_id IS NULL
+		), '[]'::json)::text AS permissions
+	FROM sod_rules sr`
+
+type sodRuleRow struct {
+	ID          int
+	Name        string
+	Description *string
+	CreatedAt   time.Time
+	Roles       string
+	Permissions string
+}
+
+func sodRules(rows []sodRuleRow) ([]mdlRbac.SoDRule, error) {
+	rules := make([]mdlRbac.SoDRule, 0, len(rows))
+	for _, row := range rows {
+		rule := mdlRbac.SoDRule{ID: row.ID, Name: row.Name, Description: row.Description, CreatedAt: row.CreatedAt}
+		if err := json.Unmarshal([]byte(row.Roles), &rule.Roles); err != nil {
+			return nil, fmt.Errorf("error parsing response: %v", err)
+		}
+		if err := json.Unmarshal([]byte(row.Permissions), &rule.Permissions); err != nil {
+			return nil, fmt.Errorf("error parsing response: %v", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func getSoDRules(id int) ([]mdlRbac.SoDRule, error) {
+	db := &config.DBConnList[0]
+
+	var rows []sodRuleRow
+	if err := db.Raw(sodRuleSelect+` WHERE sr.id = ?`, id).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch SoD rules: %v", err)
+	}
+
+	return sodRules(rows)
+}
+
+// DeleteSoDRule removes a rule and its members.
+func DeleteSoDRule(id int) error {
+	db := &config.DBConnList[0]
+
+	result := db.Exec(`DELETE FROM sod_rules WHERE id = ?`, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete SoD rule: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errRbac.ErrResourceNotFound
+	}
+
+	return nil
+}
+
+// GetSoDViolations reports every user and role that currently breaks a rule,
+// e.g. because the assignment predates the rule.
+func GetSoDViolations() ([]mdlRbac.SoDViolation, error) {
+	db := &config.DBConnList[0]
+
+	violations := []mdlRbac.SoDViolation{}
+	query := `SELECT * FROM sod_violations() ORDER BY rule_name, subject_type, subject`
+	if err := db.Raw(query).Scan(&violations).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch SoD violations: %v", err)
+	}
+
+	return violations, nil
+}
+
+// sodViolations returns the rules the given users and roles break, keyed by
+// subject and rule.
+func sodViolations(tx *gorm.DB, userIDs, roleIDs []int) (map[string]mdlRbac.SoDViolation, error) {
+	var violations []mdlRbac.SoDViolation
+	query := `
+		SELECT 'user' AS subject_type, u.id AS subject_id, u.username AS subject, v.rule_id, v.rule_name, v.members
+		FROM users u
+		CROSS JOIN LATERAL sod_user_violations(u.id) v
+		WHERE u.id IN ?
+		UNION ALL
+		SELECT 'role', r.id, r.name, v.rule_id, v.rule_name, v.members
+		FROM roles r
+		CROSS JOIN LATERAL sod_role_violations(r.id) v
+		WHERE r.id IN ?
+	`
+	if err := tx.Raw(query, inList(userIDs), inList(roleIDs)).Scan(&violations).Error; err != nil {
+		return nil, fmt.Errorf("failed to check SoD rules: %v", err)
+	}
+
+	return sodViolationsByKey(violations), nil
+}
+
+func sodViolationsByKey(violations []mdlRbac.SoDViolation) map[string]mdlRbac.SoDViolation {
+	byKey := make(map[string]mdlRbac.SoDViolation, len(violations))
+	for _, v := range violations {
+		byKey[fmt.Sprintf("%s:%d:%d", v.SubjectType, v.SubjectID, v.RuleID)] = v
+	}
+	return byKey
+}
+
+// sodGuard remembers which rules a set of users and roles broke before a
+// change so check can tell which violations the change introduced.
+// Violations that predate a rule do not block unrelated changes; they show
+// up in GetSoDViolations instead.
+type sodGuard struct {
+	load   func() (map[string]mdlRbac.SoDViolation, error)
+	before map[string]mdlRbac.SoDViolation
+}
+
+func guardSoD(tx *gorm.DB, userIDs, roleIDs []int) (*sodGuard, error) {
+	return newSoDGuard(func() (map[string]mdlRbac.SoDViolation, error) {
+		return sodViolations(tx, userIDs, roleIDs)
+	})
+}
+
+// guardAllSoD guards every user and role, for bulk changes such as a policy
+// import.
+func guardAllSoD(tx *gorm.DB) (*sodGuard, error) {
+	return newSoDGuard(func() (map[string]mdlRbac.SoDViolation, error) {
+		var violations []mdlRbac.SoDViolation
+		if err := tx.Raw(`SELECT * FROM sod_violations()`).Scan(&violations).Error; err != nil {
+			return nil, fmt.Errorf("failed to check SoD rules: %v", err)
+		}
+		return sodViolationsByKey(violations), nil
+	})
+}
+
+func newSoDGuard(load func() (map[string]mdlRbac.SoDViolation, error)) (*sodGuard, error) {
+	before, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return &sodGuard{load: load, before: before}, nil
+}
+
+// check fails with ErrSoDViolation when a guarded user or role now breaks a
+// rule it did not break before. Call it before committing.
+func (g *sodGuard) check() error {
+	after, err := g.load()
+	if err != nil {
+		return err
+	}
+
+	var introduced []string
+	for key, v := range after {
+		if _, ok := g.before[key]; !ok {
+			introduced = append(introduced, fmt.Sprintf("%s %s would hold %s (rule %q)", v.SubjectType, v.Subject, v.Members, v.RuleName))
+		}
+	}
+	if len(introduced) > 0 {
+		sort.Strings(introduced)
+		return fmt.Errorf("%w: %s", errRbac.ErrSoDViolation, strings.Join(introduced, "; "))
+	}
+	return nil
+}
+
+// guardRoleSoD guards the role, its descendants and every user holding one
+// of them, i.e. everyone whose effective grants change with the role.
+func guardRoleSoD(tx *gorm.DB, roleID int) (*sodGuard, error) {
+	userIDs, roleIDs, err := roleSoDSubjects(tx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	return guardSoD(tx, userIDs, roleIDs)
+}
+
+// inList keeps an "IN ?" clause valid for an empty list; no row has id 0.
+func inList(ids []int) []int {
+	return append(append([]int{}, ids...), 0)
+}
+
+// roleSoDSubjects lists the roles whose effective grants change with roleID
+// (the role and its descendants) and every user holding one of them.
+func roleSoDSubjects(tx *gorm.DB, roleID int) ([]int, []int, error) {
+	var roleIDs []int
+	query := `SELECT r.id FROM roles r WHERE EXISTS (SELECT 1 FROM role_ancestors(r.id) anc WHERE anc.role_id = ?)`
+	if err := tx.Raw(query, roleID).Scan(&roleIDs).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch role descendants: %v", err)
+	}
+
+	var userIDs []int
+	query = `
+		SELECT DISTINCT h.user_id
+		FROM (
+			SELECT user_id, role_id FROM user_roles WHERE valid_until IS NULL OR valid_until > now()
+			UNION
+			SELECT requester_id, role_id FROM access_requests
+			WHERE status = 'approved' AND role_id IS NOT NULL AND valid_until > now()
+		) h
+		WHERE h.role_id IN ?
+	`
+	if err := tx.Raw(query, inList(roleIDs)).Scan(&userIDs).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch role holders: %v", err)
+	}
+
+	return userIDs, roleIDs, nil
+}
+
+// ----------------------------
+// POLICY EXPORT / IMPORT
+// ----------------------------
+
+// errPolicyDryRun rolls back a dry-run import after the diff is collected.
+var errPolicyDryRun = errors.New("policy dry run")
+
+// ExportPolicy reads the whole RBAC configuration: actions, resources and
+// roles with their parent, superuser flag and direct permissions. Archived
+// rows, and grants that depend on them, are left out.
+func ExportPolicy() (*mdlRbac.PolicyDocument, error) {
+	db := &config.DBConnList[0]
+
+	doc := &mdlRbac.PolicyDocument{
+		Version:    mdlRbac.PolicyVersion,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		Actions:    []mdlRbac.PolicyItem{},
+		Resources:  []mdlRbac.PolicyItem{},
+		Roles:      []mdlRbac.PolicyRole{},
+	}
+
+	query := `SELECT name, COALESCE(description, '') AS description FROM actions WHERE archived_at IS NULL ORDER BY name`
+	if err := db.Raw(query).Scan(&doc.Actions).Error; err != nil {
+		return nil, fmt.Errorf("failed to export actions: %v", err)
+	}
+
+	query = `SELECT name, COALESCE(description, '') AS description FROM resources WHERE archived_at IS NULL ORDER BY name`
+	if err := db.Raw(query).Scan(&doc.Resources).Error; err != nil {
+		return nil, fmt.Errorf("failed to export resources: %v", err)
+	}
+
+	current, err := loadPolicyRoles(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range current {
+		if role.Archived {
+			continue
+		}
+		parent := role.Parent
+		if parent != "" && current[parent].Archived {
+			parent = ""
+		}
+
+		permissions := make([]mdlRbac.PolicyPermission, 0, len(role.permissions))
+		for _, perm := range role.permissions {
+			permissions = append(permissions, perm)
+		}
+		sort.Slice(permissions, func(i, j int) bool {
+			return permissions[i].Action+":"+permissions[i].Resource < permissions[j].Action+":"+permissions[j].Resource
+		})
+		doc.Roles = append(doc.Roles, mdlRbac.PolicyRole{
+			Name:        role.Name,
+			Description: role.Description,
+			Parent:      parent,
+			IsSuperuser: role.IsSuperuser,
+			Permissions: permissions,
+		})
+	}
+	sort.Slice(doc.Roles, func(i, j int) bool { return doc.Roles[i].Name < doc.Roles[j].Name })
+
+	return doc, nil
+}
+
+type policyRole struct {
+	ID          int
+	Name        string
+	Description string
+	Parent      string
+	IsSuperuser bool
+	Archived    bool
+	permissions map[string]mdlRbac.PolicyPermission // "action:resource" -> grant
+}
+
+// loadPolicyRoles reads every role, archived ones included, with its grants
+// on active actions and resources.
+func loadPolicyRoles(db *gorm.DB) (map[string]*policyRole, error) {
+	var roles []policyRole
+	query := `
+		SELECT r.id, r.name, COALESCE(r.description, '') AS description,
+			COALESCE(p.name, '') AS parent, r.is_superuser, r.archived_at IS NOT NULL AS archived
+		FROM roles r
+		LEFT JOIN roles p ON p.id = r.parent_role_id
+	`
+	if err := db.Raw(query).Scan(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to load roles: %v", err)
+	}
+
+	byName := make(map[string]*policyRole, len(roles))
+	for i := range roles {
+		roles[i].permissions = map[string]mdlRbac.PolicyPermission{}
+		byName[roles[i].Name] = &roles[i]
+	}
+
+	var grants []struct {
+		Role      string
+		Action    string
+		Resource  string
+		Condition *string
+	}
+	query = `
+		SELECT r.name AS role, a.name AS action, res.name AS resource, rp.condition
+		FROM role_permissions rp
+		JOIN roles r ON r.id = rp.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		JOIN actions a ON a.id = p.action_id AND a.archived_at IS NULL
+		JOIN resources res ON res.id = p.resource_id AND res.archived_at IS NULL
+	`
+	if err := db.Raw(query).Scan(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to load role permissions: %v", err)
+	}
+	for _, g := range grants {
+		if role, ok := byName[g.Role]; ok {
+			role.permissions[g.Action+":"+g.Resource] = mdlRbac.PolicyPermission{
+				Action: g.Action, Resource: g.Resource, Condition: g.Condition,
+			}
+		}
+	}
+
+	return byName, nil
+}
+
+// ImportPolicy makes the database match the document in one transaction,
+// matching everything by name. Every role in the document gets exactly the
+// listed permissions and archived rows it names are restored; roles, actions
+// and resources missing from the document are only archived with Prune. A dry run performs the same statements and
+// rolls back, so it also surfaces constraint, hierarchy and SoD errors.
+func ImportPolicy(doc *mdlRbac.PolicyDocument, opts mdlRbac.PolicyImportOptions, actor string) (*mdlRbac.PolicyDiff, error) {
+	db := &config.DBConnList[0]
+	diff := &mdlRbac.PolicyDiff{DryRun: opts.DryRun, Changes: []mdlRbac.PolicyChange{}}
+	record := func(kind, op, name, from, to string) {
+		diff.Changes = append(diff.Changes, mdlRbac.PolicyChange{Kind: kind, Op: op, Name: name, From: from, To: to})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		guard, err := guardAllSoD(tx)
+		if err != nil {
+			return err
+		}
+
+		if err := importPolicyItems(tx, "actions", "action", doc.Actions, record); err != nil {
+			return err
+		}
+		if err := importPolicyItems(tx, "resources", "resource", doc.Resources, record); err != nil {
+			return err
+		}
+
+		current, err := loadPolicyRoles(tx)
+		if err != nil {
+			return err
+		}
+
+		// Roles first, so parents and grants can refer to roles created here
+		for _, role := range doc.Roles {
+			existing, ok := current[role.Name]
+			if !ok {
+				if role.IsSuperuser && !opts.AllowSuperuser {
+					return fmt.Errorf("%w: role %q", errRbac.ErrSuperuserDenied, role.Name)
+				}
+
+				var id int
+				query := `INSERT INTO roles (name, description, is_superuser) VALUES (?, ?, ?) RETURNING id`
+				if err := tx.Raw(query, role.Name, role.Description, role.IsSuperuser).Scan(&id).Error; err != nil {
+					return fmt.Errorf("failed to create role %q: %v", role.Name, err)
+				}
+				current[role.Name] = &policyRole{ID: id, Name: role.Name, permissions: map[string]mdlRbac.PolicyPermission{}}
+				record("role", mdlRbac.PolicyCreate, role.Name, "", "")
+				if role.IsSuperuser {
+					if err := recordAudit(tx, mdlRbac.AuditSuperuserGranted, actor, strconv.Itoa(id), map[string]interface{}{"source": "policy_import"}); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			// Restoring an archived superuser role turns the bypass back on
+			touchesSuperuser := existing.IsSuperuser != role.IsSuperuser || (existing.Archived && role.IsSuperuser)
+			if touchesSuperuser && !opts.AllowSuperuser {
+				return fmt.Errorf("%w: role %q", errRbac.ErrSuperuserDenied, role.Name)
+			}
+
+			if existing.Archived {
+				query := `UPDATE roles SET archived_at = NULL, updated_at = NOW() WHERE id = ?`
+				if err := tx.Exec(query, existing.ID).Error; err != nil {
+					return fmt.Errorf("failed to restore role %q: %v", role.Name, err)
+				}
+				existing.Archived = false
+				record("role", mdlRbac.PolicyRestore, role.Name, "", "")
+			}
+			if existing.Description != role.Description {
+				query := `UPDATE roles SET description = ?, updated_at = NOW() WHERE id = ?`
+				if err := tx.Exec(query, role.Description, existing.ID).Error; err != nil {
+					return fmt.Errorf("failed to update role %q: %v", role.Name, err)
+				}
+				record("role", mdlRbac.PolicyUpdate, role.Name, existing.Description, role.Description)
+			}
+			if existing.IsSuperuser != role.IsSuperuser {
+				query := `UPDATE roles SET is_superuser = ?, updated_at = NOW() WHERE id = ?`
+				if err := tx.Exec(query, role.IsSuperuser, existing.ID).Error; err != nil {
+					return fmt.Errorf("failed to update role %q: %v", role.Name, err)
+				}
+				record("role", mdlRbac.PolicyUpdate, role.Name, fmt.Sprintf("is_superuser=%t", existing.IsSuperuser), fmt.Sprintf("is_superuser=%t", role.IsSuperuser))
+
+				event := mdlRbac.AuditSuperuserRevoked
+				if role.IsSuperuser {
+					event = mdlRbac.AuditSuperuserGranted
+				}
+				if err := recordAudit(tx, event, actor, strconv.Itoa(existing.ID), map[string]interface{}{"source": "policy_import"}); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, role := range doc.Roles {
+			existing := current[role.Name]
+			if existing.Parent == role.Parent {
+				continue
+			}
+
+			var parentID *int
+			if role.Parent != "" {
+				parent, ok := current[role.Parent]
+				if !ok {
+					return fmt.Errorf("%w: parent role %q of %q", errRbac.ErrResourceNotFound, role.Parent, role.Name)
+				}
+				parentID = &parent.ID
+			}
+
+			query := `UPDATE roles SET parent_role_id = ?, updated_at = NOW() WHERE id = ?`
+			if err := tx.Exec(query, parentID, existing.ID).Error; err != nil {
+				if strings.Contains(err.Error(), "role hierarchy cycle") {
+					return fmt.Errorf("%w: %s -> %s", errRbac.ErrRoleCycle, role.Name, role.Parent)
+				}
+				return fmt.Errorf("failed to set parent of role %q: %v", role.Name, err)
+			}
+			record("role_parent", mdlRbac.PolicyUpdate, role.Name, existing.Parent, role.Parent)
+		}
+
+		for _, role := range doc.Roles {
+			if err := importRolePermissions(tx, current[role.Name], role.Permissions, record); err != nil {
+				return err
+			}
+		}
+
+		if opts.Prune {
+			if err := prunePolicyRoles(tx, doc, current, record); err != nil {
+				return err
+			}
+			if err := prunePolicyItems(tx, "actions", "action", doc.Actions, record); err != nil {
+				return err
+			}
+			if err := prunePolicyItems(tx, "resources", "resource", doc.Resources, record); err != nil {
+				return err
+			}
+		}
+
+		if err := guard.check(); err != nil {
+			return err
+		}
+
+		if opts.DryRun {
+			return errPolicyDryRun
+		}
+
+		detail := map[string]interface{}{"changes": len(diff.Changes), "prune": opts.Prune}
+		return recordAudit(tx, mdlRbac.AuditPolicyImported, actor, "policy", detail)
+	})
+	if err != nil && !errors.Is(err, errPolicyDryRun) {
+		return nil, err
+	}
+
+	diff.Applied = !opts.DryRun
+	return diff, nil
+}
+
+// importPolicyItems creates, restores or updates the named actions or
+// resources. table is trusted (actions or resources).
+func importPolicyItems(tx *gorm.DB, table, kind string, items []mdlRbac.PolicyItem, record func(kind, op, name, from, to string)) error {
+	var current []struct {
+		Name        string
+		Description string
+		Archived    bool
+	}
+	query := `SELECT name, COALESCE(description, '') AS description, archived_at IS NOT NULL AS archived FROM ` + table
+	if err := tx.Raw(query).Scan(&current).Error; err != nil {
+		return fmt.Errorf("failed to load %s: %v", table, err)
+	}
+	byName := make(map[string]string, len(current))
+	archived := map[string]bool{}
+	for _, item := range current {
+		byName[item.Name] = item.Description
+		archived[item.Name] = item.Archived
+	}
+
+	for _, item := range items {
+		if archived[item.Name] {
+			if err := tx.Exec(`UPDATE `+table+` SET archived_at = NULL, updated_at = NOW() WHERE name = ?`, item.Name).Error; err != nil {
+				return fmt.Errorf("failed to restore %s %q: %v", kind, item.Name, err)
+			}
+			record(kind, mdlRbac.PolicyRestore, item.Name, "", "")
+		}
+
+		description, ok := byName[item.Name]
+		switch {
+		case !ok:
+			if err := tx.Exec(`INSERT INTO `+table+` (name, description) VALUES (?, ?)`, item.Name, item.Description).Error; err != nil {
+				return fmt.Errorf("failed to create %s %q: %v", kind, item.Name, err)
+			}
+			record(kind, mdlRbac.PolicyCreate, item.Name, "", "")
+		case description != item.Description:
+			if err := tx.Exec(`UPDATE `+table+` SET description = ?, updated_at = NOW() WHERE name = ?`, item.Description, item.Name).Error; err != nil {
+				return fmt.Errorf("failed to update %s %q: %v", kind, item.Name, err)
+			}
+			record(kind, mdlRbac.PolicyUpdate, item.Name, description, item.Description)
+		}
+	}
+
+	return nil
+}
+
+// importRolePermissions gives a role exactly the listed grants.
+func importRolePermissions(tx *gorm.DB, role *policyRole, desired []mdlRbac.PolicyPermission, record func(kind, op, name, from, to string)) error {
+	wanted := make(map[string]bool, len(desired))
+	for _, perm := range desired {
+		key := perm.Action + ":" + perm.Resource
+		name := role.Name + " " + key
+		wanted[key] = true
+
+		existing, ok := role.permissions[key]
+		if ok {
+			if sameCondition(existing.Condition, perm.Condition) {
+				continue
+			}
+			query := `
+				UPDATE role_permissions rp SET condition = ?, updated_at = NOW()
+				FROM permissions p, actions a, resources res
+				WHERE rp.role_id = ? AND rp.permission_id = p.id AND p.action_id = a.id AND p.resource_id = res.id
+					AND a.name = ? AND res.name = ?
+			`
+			if err := tx.Exec(query, perm.Condition, role.ID, perm.Action, perm.Resource).Error; err != nil {
+				return fmt.Errorf("failed to update %s: %v", name, err)
+			}
+			record("role_permission", mdlRbac.PolicyUpdate, name, conditionText(existing.Condition), conditionText(perm.Condition))
+			continue
+		}
+
+		permissionID, err := ensurePermission(tx, perm.Action, perm.Resource)
+		if err != nil {
+			return err
+		}
+		insert := `INSERT INTO role_permissions (role_id, permission_id, condition) VALUES (?, ?, ?)`
+		if err := tx.Exec(insert, role.ID, permissionID, perm.Condition).Error; err != nil {
+			return fmt.Errorf("failed to add %s: %v", name, err)
+		}
+		record("role_permission", mdlRbac.PolicyCreate, name, "", conditionText(perm.Condition))
+	}
+
+	for key, perm := range role.permissions {
+		if wanted[key] {
+			continue
+		}
+		query := `
+			DELETE FROM role_permissions rp
+			USING permissions p, actions a, resources res
+			WHERE rp.role_id = ? AND rp.permission_id = p.id AND p.action_id = a.id AND p.resource_id = res.id
+				AND a.name = ? AND res.name = ?
+		`
+		if err := tx.Exec(query, role.ID, perm.Action, perm.Resource).Error; err != nil {
+			return fmt.Errorf("failed to remove %s %s: %v", role.Name, key, err)
+		}
+		record("role_permission", mdlRbac.PolicyDelete, role.Name+" "+key, conditionText(perm.Condition), "")
+	}
+
+	return nil
+}
+
+// prunePolicyRoles archives active roles missing from the document. Their
+// assignments, grants and child links are kept for a later restore.
+func prunePolicyRoles(tx *gorm.DB, doc *mdlRbac.PolicyDocument, current map[string]*policyRole, record func(kind, op, name, from, to string)) error {
+	keep := make(map[string]bool, len(doc.Roles))
+	for _, role := range doc.Roles {
+		keep[role.Name] = true
+	}
+
+	var stale []int
+	var names []string
+	for name, role := range current {
+		if !keep[name] && !role.Archived {
+			stale = append(stale, role.ID)
+			names = append(names, name)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	if err := tx.Exec(`UPDATE roles SET archived_at = now(), updated_at = NOW() WHERE id IN ?`, stale).Error; err != nil {
+		return fmt.Errorf("failed to archive roles: %v", err)
+	}
+	for _, name := range names {
+		record("role", mdlRbac.PolicyArchive, name, "", "")
+	}
+
+	return nil
+}
+
+// prunePolicyItems archives active actions or resources missing from the
+// document. table is trusted.
+func prunePolicyItems(tx *gorm.DB, table, kind string, items []mdlRbac.PolicyItem, record func(kind, op, name, from, to string)) error {
+	keep := make([]string, 0, len(items)+1)
+	for _, item := range items {
+		keep = append(keep, item.Name)
+	}
+	keep = append(keep, "") // keeps NOT IN valid for an empty document
+
+	var archived []string
+	query := `
+		UPDATE ` + table + ` SET archived_at = now(), updated_at = NOW()
+		WHERE name NOT IN ? AND archived_at IS NULL
+		RETURNING name
+	`
+	if err := tx.Raw(query, keep).Scan(&archived).Error; err != nil {
+		return fmt.Errorf("failed to archive %ss: %v", kind, err)
+	}
+	sort.Strings(archived)
+	for _, name := range archived {
+		record(kind, mdlRbac.PolicyArchive, name, "", "")
+	}
+
+	return nil
+}
+
+func conditionText(condition *string) string {
+	if condition == nil {
+		return ""
+	}
+	return "if " + *condition
+}
+
+// ----------------------------
+// ROUTE REGISTRY
+// ----------------------------
+
+// ActionAndResourceNames returns the set of active action names and the set
+// of active resource names, so route requirements can be checked against them.
+func ActionAndResourceNames() (map[string]bool, map[string]bool, error) {
+	db := &config.DBConnList[0]
+
+	var rows []struct {
+		Kind string
+		Name string
+	}
+	query := `
+		SELECT 'action' AS kind, name FROM actions WHERE archived_at IS NULL
+		UNION ALL
+		SELECT 'resource', name FROM resources WHERE archived_at IS NULL
+	`
+	if err := db.Raw(query).Scan(&rows).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch action and resource names: %v", err)
+	}
+
+	actions := map[string]bool{}
+	resources := map[string]bool{}
+	for _, row := range rows {
+		if row.Kind == "action" {
+			actions[row.Name] = true
+		} else {
+			resources[row.Name] = true
+		}
+	}
+
+	return actions, resources, nil
+}
+
+// ----------------------------
+// MENUS
+// ----------------------------
+
+const menuColumns = `id AS menu_id, parent_id, name, slug, path, icon, sort_order, system_tag, permission, created_at, updated_at`
+
+// menuError maps constraint failures on menus and role_menus.
+func menuError(err error, action string) error {
+	switch {
+	case strings.Contains(err.Error(), `unique constraint "menus_slug_key"`):
+		return errRbac.ErrResourceNameTaken
+	case strings.Contains(err.Error(), "menus_parent_check"):
+		return errRbac.ErrMenuCycle
+	case strings.Contains(err.Error(), "foreign key"):
+		return errRbac.ErrResourceNotFound
+	}
+	return fmt.Errorf("failed to %s: %v", action, err)
+}
+
+// CreateMenu adds a menu, under ParentID when set.
+func CreateMenu(req mdlRbac.MenuRequest) (*mdlRbac.Menu, error) {
+	db := &config.DBConnList[0]
+
+	var menu mdlRbac.Menu
+	query := `
+		INSERT INTO menus (parent_id, name, slug, path, icon, sort_order, system_tag, permission)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING ` + menuColumns
+
+	if err := db.Raw(query, req.ParentID, req.Name, req.Slug, req.Path, req.Icon, req.SortOrder, req.SystemTag, req.Permission).Scan(&menu).Error; err != nil {
+		return nil, menuError(err, "create menu")
+	}
+
+	return &menu, nil
+}
+
+// GetMenus returns every menu as a flat list ordered for display. A non-empty
+// systemTag keeps that system's menus and the untagged ones.
+func GetMenus(systemTag string) ([]mdlRbac.Menu, error) {
+	db := &config.DBConnList[0]
+
+	var menus []mdlRbac.Menu
+	query := `
+		SELECT ` + menuColumns + `
+		FROM menus
+		WHERE ?::text = '' OR system_tag IS NULL OR system_tag = ?::text
+		ORDER BY sort_order, name, id
+	`
+	if err := db.Raw(query, systemTag, systemTag).Scan(&menus).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch menus: %v", err)
+	}
+
+	return menus, nil
+}
+
+// UpdateMenu rewrites a menu. Moving it under itself or one of its
+// descendants is rejected.
+func UpdateMenu(id int, req mdlRbac.MenuRequest) (*mdlRbac.Menu, error) {
+	db := &config.DBConnList[0]
+
+	if req.ParentID != nil {
+		var cycle bool
+		query := `
+			WITH RECURSIVE chain AS (
+				SELECT id, parent_id FROM menus WHERE id = ?
+				UNION
+				SELECT m.id, m.parent_id FROM menus m JOIN chain c ON m.id = c.parent_id
+			)
+			SELECT EXISTS (SELECT 1 FROM chain WHERE id = ?)
+		`
+		if err := db.Raw(query, *req.ParentID, id).Scan(&cycle).Error; err != nil {
+			return nil, fmt.Errorf("failed to check menu tree: %v", err)
+		}
+		if cycle {
+			return nil, errRbac.ErrMenuCycle
+		}
+	}
+
+	var menu mdlRbac.Menu
+	query := `
+		UPDATE menus
+		SET parent_id = ?, name = ?, slug = ?, path = ?, icon = ?, sort_order = ?, system_tag = ?,
+			permission = ?, updated_at = NOW()
+		WHERE id = ?
+		RETURNING ` + menuColumns
+
+	if err := db.Raw(query, req.ParentID, req.Name, req.Slug, req.Path, req.Icon, req.SortOrder, req.SystemTag, req.Permission, id).Scan(&menu).Error; err != nil {
+		return nil, menuError(err, "update menu")
+	}
+
+	if menu.MenuID == 0 {
+		return nil, errRbac.ErrResourceNotFound
+	}
+
+	return &menu, nil
+}
+
+// DeleteMenu removes a menu and its role assignments. Menus with children are
+// in use and kept.
+func DeleteMenu(id int) error {
+	db := &config.DBConnList[0]
+
+	result := db.Exec(`DELETE FROM menus WHERE id = ?`, id)
+	if result.Error != nil {
+		if strings.Contains(result.Error.Error(), "foreign key") {
+			return errRbac.ErrResourceInUse
+		}
+		return fmt.Errorf("failed to delete menu: %v", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errRbac.ErrResourceNotFound
+	}
+
+	return nil
+}
+
+// GetRoleMenus returns the menus assigned directly to a role.
+func GetRoleMenus(roleID int) ([]mdlRbac.Menu, error) {
+	db := &config.DBConnList[0]
+
+	if _, err := GetRoleByID(roleID); err != nil {
+		return nil, err
+	}
+
+	var menus []mdlRbac.Menu
+	query := `
+		SELECT ` + menuColumns + `
+		FROM menus
+		WHERE id IN (SELECT menu_id FROM role_menus WHERE role_id = ?)
+		ORDER BY sort_order, name, id
+	`
+	if err := db.Raw(query, roleID).Scan(&menus).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch role menus: %v", err)
+	}
+
+	return menus, nil
+}
+
+// ReplaceRoleMenus sets the menus assigned to a role to exactly menuIDs.
+func ReplaceRoleMenus(roleID int, menuIDs []int) error {
+	db := &config.DBConnList[0]
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var exists bool
+		if err := tx.Raw(`SELECT EXISTS (SELECT 1 FROM roles WHERE id = ?)`, roleID).Scan(&exists).Error; err != nil {
+			return fmt.Errorf("failed to check role: %v", err)
+		}
+		if !exists {
+			return errRbac.ErrResourceNotFound
+		}
+
+		if err := tx.Exec(`DELETE FROM role_menus WHERE role_id = ? AND menu_id NOT IN ?`, roleID, inList(menuIDs)).Error; err != nil {
+			return fmt.Errorf("failed to remove role menus: %v", err)
+		}
+
+		for _, menuID := range menuIDs {
+			query := `INSERT INTO role_menus (role_id, menu_id) VALUES (?, ?) ON CONFLICT (role_id, menu_id) DO NOTHING`
+			if err := tx.Exec(query, roleID, menuID).Error; err != nil {
+				return menuError(err, "assign role menu")
+			}
+		}
+
+		return nil
+	})
+}
+
+// MenuIDsForRoles returns the IDs of menus assigned to any of the roles or to
+// a role they inherit from.
+func MenuIDsForRoles(roleIDs []int) (map[int]bool, error) {
+	db := &config.DBConnList[0]
+
+	var ids []int
+	query := `
+		SELECT DISTINCT rm.menu_id
+		FROM roles r
+		CROSS JOIN LATERAL role_ancestors(r.id) anc
+		JOIN role_menus rm ON rm.role_id = anc.role_id
+		WHERE r.id IN ? AND NOT EXISTS (
+			-- an archived role hides its own menus and everything it inherits
+			SELECT 1 FROM role_ancestors(r.id) up
+			JOIN roles ur ON ur.id = up.role_id
+			WHERE ur.archived_at IS NOT NULL AND up.depth <= anc.depth
+		)
+	`
+	if err := db.Raw(query, inList(roleIDs)).Scan(&ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch role menus: %v", err)
+	}
+
+	assigned := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		assigned[id] = true
+	}
+	return assigned, nil
+}
+
+// ----------------------------
+// IMPACT ANALYSIS
+// ----------------------------
+
+var errImpactPreview = errors.New("impact preview")
+
+// ImpactSnapshot is the state before and after a previewed change. Before and
+// After hold the same users in the same order.
+type ImpactSnapshot struct {
+	Roles  []mdlRbac.ImpactRole
+	Before []*mdlAuth.UserWithPermissions
+	After  []*mdlAuth.UserWithPermissions
+}
+
+// impactScope names what a change touches directly. Holders of every role
+// whose effective permissions shrink are looked at as well.
+type impactScope struct {
+	roleIDs    []int
+	staffIDs   []string
+	actionID   int
+	resourceID int
+}
+
+// PreviewDeleteAction previews archiving an action.
+func PreviewDeleteAction(id int) (*ImpactSnapshot, error) {
+	return previewImpact(impactScope{actionID: id}, func(tx *gorm.DB) error {
+		return archiveRow(tx, "actions", "action", id, true)
+	})
+}
+
+// PreviewDeleteResource previews archiving a resource.
+func PreviewDeleteResource(id int) (*ImpactSnapshot, error) {
+	return previewImpact(impactScope{resourceID: id}, func(tx *gorm.DB) error {
+		return archiveRow(tx, "resources", "resource", id, true)
+	})
+}
+
+// PreviewDeleteRole previews archiving a role.
+func PreviewDeleteRole(id int) (*ImpactSnapshot, error) {
+	return previewImpact(impactScope{roleIDs: []int{id}}, func(tx *gorm.DB) error {
+		return archiveRow(tx, "roles", "role", id, true)
+	})
+}
+
+// PreviewRemoveRolePermission previews taking a direct grant away from a role.
+func PreviewRemoveRolePermission(roleID int, actionName, resourceName string) (*ImpactSnapshot, error) {
+	return previewImpact(impactScope{roleIDs: []int{roleID}}, func(tx *gorm.DB) error {
+		var result mdlRbac.PermissionResult
+		query := `SELECT success, message, role_name, action_name, resource_name FROM remove_role_permission(?, ?, ?)`
+		if err := tx.Raw(query, roleID, actionName, resourceName).Scan(&result).Error; err != nil {
+			return fmt.Errorf("error executing remove_permission_from_role: %v", err)
+		}
+		if !result.Success {
+			return fmt.Errorf("%w: %s", errRbac.ErrResourceNotFound, result.Message)
+		}
+		return nil
+	})
+}
+
+// PreviewAssignUserRole previews replacing the user's primary role. Nothing
+// is committed, so previewing a superuser role needs no update:superuser.
+func PreviewAssignUserRole(staffID string, roleID int) (*ImpactSnapshot, error) {
+	return previewImpact(impactScope{staffIDs: []string{staffID}}, func(tx *gorm.DB) error {
+		return assignUserRole(tx, staffID, roleID, nil, nil, true)
+	})
+}
+
+// previewImpact applies the change in a transaction that is always rolled
+// back. Only roles the change can reach are compared, and the savepoint is
+// rolled back as soon as the after state is read so row locks taken by the
+// change are not held while the before state loads.
+func previewImpact(scope impactScope, apply func(tx *gorm.DB) error) (*ImpactSnapshot, error) {
+	db := &config.DBConnList[0]
+
+	snapshot := &ImpactSnapshot{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		reachable, err := impactRoles(tx, scope)
+		if err != nil {
+			return err
+		}
+		before, err := loadRoleGrants(tx, reachable)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.SavePoint("impact_preview").Error; err != nil {
+			return fmt.Errorf("failed to start impact preview: %v", err)
+		}
+		if err := apply(tx); err != nil {
+			return err
+		}
+
+		after, err := loadRoleGrants(tx, reachable)
+		if err != nil {
+			return err
+		}
+
+		roleIDs := append([]int{}, scope.roleIDs...)
+		lost := map[int][]string{}
+		for id, role := range before {
+			for permission := range role.permissions {
+				if kept := after[id]; kept == nil || !kept.permissions[permission] {
+					lost[id] = append(lost[id], permission)
+				}
+			}
+			if len(lost[id]) > 0 {
+				roleIDs = append(roleIDs, id)
+			}
+		}
+
+		usernames, err := impactSubjects(tx, scope, roleIDs)
+		if err != nil {
+			return err
+		}
+		if snapshot.After, err = loadImpactUsers(tx, usernames); err != nil {
+			return err
+		}
+
+		if err := tx.RollbackTo("impact_preview").Error; err != nil {
+			return fmt.Errorf("failed to roll back impact preview: %v", err)
+		}
+		if snapshot.Before, err = loadImpactUsers(tx, usernames); err != nil {
+			return err
+		}
+
+		// Roles users stop holding count even when their grants are unchanged
+		names := map[int]string{}
+		for i, user := range snapshot.Before {
+			held := map[int]bool{}
+			for _, role := range snapshot.After[i].Roles {
+				held[role.ID] = true
+			}
+			for _, role := range user.Roles {
+				if _, ok := lost[role.ID]; !ok && !held[role.ID] {
+					lost[role.ID] = []string{}
+				}
+				names[role.ID] = role.Name
+			}
+		}
+
+		for id, permissions := range lost {
+			name := names[id]
+			if role := before[id]; role != nil {
+				name = role.name
+			}
+			sort.Strings(permissions)
+			snapshot.Roles = append(snapshot.Roles, mdlRbac.ImpactRole{ID: id, Name: name, LostPermissions: permissions})
+		}
+		sort.Slice(snapshot.Roles, func(i, j int) bool { return snapshot.Roles[i].Name < snapshot.Roles[j].Name })
+
+		return errImpactPreview
+	})
+	if err != nil && !errors.Is(err, errImpactPreview) {
+		return nil, err
+	}
+
+	if snapshot.Roles == nil {
+		snapshot.Roles = []mdlRbac.ImpactRole{}
+	}
+	return snapshot, nil
+}
+
+type roleGrants struct {
+	name        string
+	permissions map[string]bool
+}
+
+// impactRoles lists the roles whose effective grants the change can alter:
+// the scope's roles, the roles granting the scope's action or resource, and
+// every descendant of those.
+func impactRoles(tx *gorm.DB, scope impactScope) ([]int, error) {
+	var roleIDs []int
+	query := `
+		SELECT r.id FROM roles r
+		WHERE EXISTS (
+			SELECT 1 FROM role_ancestors(r.id) anc
+			WHERE anc.role_id IN ? OR EXISTS (
+				SELECT 1 FROM role_permissions rp
+				JOIN permissions p ON p.id = rp.permission_id
+				WHERE rp.role_id = anc.role_id AND (p.action_id = ? OR p.resource_id = ?)
+			)
+		)
+	`
+	if err := tx.Raw(query, inList(scope.roleIDs), scope.actionID, scope.resourceID).Scan(&roleIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to find affected roles: %v", err)
+	}
+	return roleIDs, nil
+}
+
+// loadRoleGrants reads the effective permissions of roleIDs.
+func loadRoleGrants(tx *gorm.DB, roleIDs []int) (map[int]*roleGrants, error) {
+	var rows []struct {
+		ID         int
+		Name       string
+		Permission *string
+	}
+	query := `
+		SELECT r.id, r.name, ep.action_name || ':' || ep.resource_name AS permission
+		FROM roles r
+		LEFT JOIN LATERAL role_effective_permissions(r.id) ep ON true
+		WHERE r.id IN ?
+	`
+	if err := tx.Raw(query, inList(roleIDs)).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load role permissions: %v", err)
+	}
+
+	roles := map[int]*roleGrants{}
+	for _, row := range rows {
+		role, ok := roles[row.ID]
+		if !ok {
+			role = &roleGrants{name: row.Name, permissions: map[string]bool{}}
+			roles[row.ID] = role
+		}
+		if row.Permission != nil {
+			role.permissions[*row.Permission] = true
+		}
+	}
+	return roles, nil
+}
+
+// impactSubjects lists the users who hold one of roleIDs (directly or through
+// an approved request), are named in the scope, or hold an approved request
+// for the scope's action or resource.
+func impactSubjects(tx *gorm.DB, scope impactScope, roleIDs []int) ([]string, error) {
+	staffIDs := append(append([]string{}, scope.staffIDs...), "") // keeps IN valid when empty
+
+	var usernames []string
+	query := `
+		SELECT u.username FROM users u
+		WHERE u.staff_id IN ?
+			OR EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = u.id AND ur.role_id IN ?)
+			OR EXISTS (
+				SELECT 1 FROM access_requests ar
+				WHERE ar.requester_id = u.id AND ar.status = 'approved' AND ar.valid_until > now()
+					AND (ar.role_id IN ? OR ar.action_id = ? OR ar.resource_id = ?)
+			)
+		ORDER BY u.username
+	`
+	ids := inList(roleIDs)
+	if err := tx.Raw(query, staffIDs, ids, ids, scope.actionID, scope.resourceID).Scan(&usernames).Error; err != nil {
+		return nil, fmt.Errorf("failed to find affected users: %v", err)
+	}
+	return usernames, nil
+}
+
+// loadImpactUsers reads users with their permissions as the login does, in
+// one query inside the preview transaction, ordered by username.
+func loadImpactUsers(tx *gorm.DB, usernames []string) ([]*mdlAuth.UserWithPermissions, error) {
+	users := []*mdlAuth.UserWithPermissions{}
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	var raw string
+	query := `
+		SELECT COALESCE(json_agg(get_user_by_username(u.username) ORDER BY u.username), '[]'::json)::text
+		FROM users u
+		WHERE u.username IN ?
+	`
+	if err := tx.Raw(query, usernames).Scan(&raw).Error; err != nil {
+		return nil, fmt.Errorf("failed to load affected users: %v", err)
+	}
+	if err := json.Unmarshal([]byte(raw), &users); err != nil {
+		return nil, fmt.Errorf("failed to decode affected users: %v", err)
+	}
+	return users, nil
+}
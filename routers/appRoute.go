@@ -14,56 +14,128 @@ func APIRoute(app *fiber.App) {
 	publicV1 := app.Group("/api/public/v1")
 	privateV1 := app.Group("/api/private/v1")
 
+	// Every route is registered with the permission it requires (empty when
+	// a valid token, or nothing at all, is enough); see GET /rbac/routes.
+	public := middleware.PublicRoutes(publicV1)
+	private := middleware.PublicRoutes(privateV1)
+
 	// HealthCheck
-	publicV1.Get("/", svcHealthcheck.HealthCheck)
-	privateV1.Get("/", svcHealthcheck.HealthCheck)
+	public.Get("/", "", svcHealthcheck.HealthCheck)
+	private.Get("/", "", svcHealthcheck.HealthCheck)
+
+	metrics := middleware.SecuredRoutes(privateV1.Group("/metrics", middleware.AuthMiddleware))
+	metrics.Get("/outbound", "view:metrics", svcHealthcheck.OutboundMetrics)
 
 	// // Sample
 	// sampleEndpoint := publicV1.Group("/sample")
 	// sampleEndpoint.Get("/", ctrFeatureOne.GetSampleData)
 
-	auth := publicV1.Group("/auth")
-	auth.Post("/register", ctrAuth.RegisterUser)
-	auth.Post("/login", ctrAuth.LoginUser)
-	auth.Post("/logout", ctrAuth.LogoutUser)
-	auth.Post("/change-temp-password", ctrAuth.ChangeTempPassword)
-	auth.Post("/delete-user", ctrAuth.DeleteUser)
-	auth.Post("/update-user/:username", ctrAuth.UpdateUser)
-	auth.Post("/forgot-password", ctrAuth.ForgotPassword)
-	auth.Post("/verify-reset-token", ctrAuth.VerifyResetToken)
+	auth := middleware.PublicRoutes(publicV1.Group("/auth"))
+	auth.Post("/register", "", ctrAuth.RegisterUser)
+	auth.Post("/login", "", ctrAuth.LoginUser)
+	auth.Post("/logout", "", ctrAuth.LogoutUser)
+	auth.Post("/change-temp-password", "", ctrAuth.ChangeTempPassword)
+	auth.Post("/delete-user", "", ctrAuth.DeleteUser)
+	auth.Post("/update-user/:username", "", ctrAuth.UpdateUser)
+	auth.Post("/forgot-password", "", ctrAuth.ForgotPassword)
+	auth.Post("/verify-reset-token", "", ctrAuth.VerifyResetToken)
 
 	// ----------------------------
 	// 🔐 RBAC Endpoints
 	// ----------------------------
-	rbac := publicV1.Group("/rbac", middleware.AuthMiddleware)
-	// rbac.Get("/getmenubyrole", ctrRbac.GetUserMenus)
-	rbac.Get("/roles", ctrRbac.FetchAllUserRoles, middleware.RequirePermission("view:role"))
-	rbac.Put("/users/:staffId/roles/:roleId", ctrRbac.AssignUserRole, middleware.RequirePermission("update:role"))
+	rbac := middleware.SecuredRoutes(publicV1.Group("/rbac", middleware.AuthMiddleware))
+	rbac.Get("/roles", "view:role", ctrRbac.FetchAllUserRoles)
+	rbac.Put("/users/:staffId/roles/:roleId", "update:role", ctrRbac.AssignUserRole)
+	rbac.Get("/users/:staffId/roles", "view:role", ctrRbac.GetUserRoles)
+	rbac.Post("/users/:staffId/roles", "update:role", ctrRbac.AddUserRole)
+	rbac.Delete("/users/:staffId/roles/:roleId", "update:role", ctrRbac.RemoveUserRole)
+	rbac.Put("/roles/:roleId/parent", "update:role", ctrRbac.SetRoleParent)
+	rbac.Put("/roles/:roleId/superuser", "update:superuser", ctrRbac.SetRoleSuperuser)
 
 	//CRUD Actions
-	rbac.Post("/actions", ctrRbac.CreateAction, middleware.RequirePermission("create:action"))
-	rbac.Get("/actions", ctrRbac.GetActions, middleware.RequirePermission("view:action"))
-	rbac.Put("/actions/:id", ctrRbac.UpdateAction, middleware.RequirePermission("update:action"))
-	rbac.Delete("/actions/:id", ctrRbac.DeleteAction, middleware.RequirePermission("delete:action"))
+	rbac.Post("/actions", "create:action", ctrRbac.CreateAction)
+	rbac.Get("/actions", "view:action", ctrRbac.GetActions)
+	rbac.Put("/actions/:id", "update:action", ctrRbac.UpdateAction)
+	rbac.Delete("/actions/:id", "delete:action", ctrRbac.DeleteAction)
+	rbac.Post("/actions/:id/restore", "update:action", ctrRbac.RestoreAction)
 
 	// CRUD Resources
-	rbac.Post("/resources", ctrRbac.CreateResource, middleware.RequirePermission("create:action"))
-	rbac.Get("/resources", ctrRbac.GetResources, middleware.RequirePermission("view:action"))
-	rbac.Put("/resources/:id", ctrRbac.UpdateResource, middleware.RequirePermission("update:action"))
-	rbac.Delete("/resources/:id", ctrRbac.DeleteResource, middleware.RequirePermission("delete:action"))
+	rbac.Post("/resources", "create:resource", ctrRbac.CreateResource)
+	rbac.Get("/resources", "view:resource", ctrRbac.GetResources)
+	rbac.Put("/resources/:id", "update:resource", ctrRbac.UpdateResource)
+	rbac.Delete("/resources/:id", "delete:resource", ctrRbac.DeleteResource)
+	rbac.Post("/resources/:id/restore", "update:resource", ctrRbac.RestoreResource)
 
 	// // ROle permissions Assignment
-	rbac.Post("/roles/:roleId/permissions", ctrRbac.AssignRolePermission, middleware.RequirePermission("create:permission"))
-	rbac.Get("/roles/permissions", ctrRbac.GetAllRolesPermissions, middleware.RequirePermission("view:permission"))
-	rbac.Get("/roles/:roleId/permissions", ctrRbac.GetRolePermissionsbyRole, middleware.RequirePermission("view:permission"))
-	rbac.Delete("/roles/:roleId/permissions", ctrRbac.RemoveRolePermission, middleware.RequirePermission("delete:permission"))
+	rbac.Post("/roles/:roleId/permissions", "create:permission", ctrRbac.AssignRolePermission)
+	rbac.Get("/roles/permissions", "view:permission", ctrRbac.GetAllRolesPermissions)
+	rbac.Get("/roles/:roleId/permissions", "view:permission", ctrRbac.GetRolePermissionsbyRole)
+	rbac.Delete("/roles/:roleId/permissions", "delete:permission", ctrRbac.RemoveRolePermission)
+	rbac.Put("/roles/:roleId/permissions", "create:permission && delete:permission", ctrRbac.ReplaceRolePermissions)
+	rbac.Put("/roles/:roleId/permissions/condition", "update:permission", ctrRbac.SetRolePermissionCondition)
+
+	// Permission catalogue (action/resource pairs)
+	rbac.Post("/permissions", "create:permission", ctrRbac.CreatePermission)
+	rbac.Get("/permissions", "view:permission", ctrRbac.FetchAllPermissions)
+	rbac.Put("/permissions/:id", "update:permission", ctrRbac.UpdatePermission)
+	rbac.Delete("/permissions/:id", "delete:permission", ctrRbac.DeletePermission)
+
+	// CRUD Roles (after /roles/permissions so it is not captured by :roleId)
+	rbac.Post("/roles", "create:role", ctrRbac.CreateRole)
+	rbac.Get("/roles/:roleId", "view:role", ctrRbac.GetRole)
+	rbac.Put("/roles/:roleId", "update:role", ctrRbac.UpdateRole)
+	rbac.Delete("/roles/:roleId", "delete:role", ctrRbac.DeleteRole)
+	rbac.Post("/roles/:roleId/restore", "update:role", ctrRbac.RestoreRole)
+
+	// Access decisions for downstream services
+	rbac.Post("/check", "view:permission", ctrRbac.CheckAccess)
+	rbac.Post("/check/batch", "view:permission", ctrRbac.CheckAccessBatch)
+	rbac.Get("/explain", "view:permission", ctrRbac.ExplainPermissions)
+
+	// Just-in-time access requests (any authenticated user may file and cancel their own)
+	rbac.Post("/access-requests", "", ctrRbac.CreateAccessRequest)
+	rbac.Get("/access-requests/mine", "", ctrRbac.ListMyAccessRequests)
+	rbac.Post("/access-requests/:id/cancel", "", ctrRbac.CancelAccessRequest)
+	rbac.Get("/access-requests", "view:access_request", ctrRbac.ListAccessRequests)
+	rbac.Post("/access-requests/:id/approve", "approve:access_request", ctrRbac.ApproveAccessRequest)
+	rbac.Post("/access-requests/:id/deny", "approve:access_request", ctrRbac.DenyAccessRequest)
+	rbac.Post("/access-requests/:id/revoke", "approve:access_request", ctrRbac.RevokeAccessRequest)
+
+	// Separation-of-duties rules
+	rbac.Post("/sod/rules", "create:sod_rule", ctrRbac.CreateSoDRule)
+	rbac.Get("/sod/rules", "view:sod_rule", ctrRbac.GetSoDRules)
+	rbac.Delete("/sod/rules/:id", "delete:sod_rule", ctrRbac.DeleteSoDRule)
+	rbac.Get("/sod/violations", "view:sod_rule", ctrRbac.GetSoDViolations)
+
+	// Policy promotion between environments
+	rbac.Get("/policy/export", "view:policy", ctrRbac.ExportPolicy)
+	rbac.Post("/policy/import", "update:policy", ctrRbac.ImportPolicy)
+
+	// Navigation menus
+	rbac.Get("/menus/me", "", ctrRbac.GetMyMenus)
+	rbac.Post("/menus", "create:menu", ctrRbac.CreateMenu)
+	rbac.Get("/menus", "view:menu", ctrRbac.GetMenus)
+	rbac.Put("/menus/:id", "update:menu", ctrRbac.UpdateMenu)
+	rbac.Delete("/menus/:id", "delete:menu", ctrRbac.DeleteMenu)
+	rbac.Get("/roles/:roleId/menus", "view:menu", ctrRbac.GetRoleMenus)
+	rbac.Put("/roles/:roleId/menus", "update:menu", ctrRbac.ReplaceRoleMenus)
+
+	// Route-permission map for admin UIs
+	rbac.Get("/routes", "view:route", ctrRbac.GetRoutes)
+
+	// Impact previews: who would lose what, without making the change
+	rbac.Get("/impact/actions/:id", "delete:action", ctrRbac.PreviewDeleteAction)
+	rbac.Get("/impact/resources/:id", "delete:resource", ctrRbac.PreviewDeleteResource)
+	rbac.Get("/impact/roles/:roleId", "delete:role", ctrRbac.PreviewDeleteRole)
+	rbac.Get("/impact/roles/:roleId/permissions", "delete:permission", ctrRbac.PreviewRemoveRolePermission)
+	rbac.Get("/impact/users/:staffId/roles/:roleId", "update:role", ctrRbac.PreviewAssignUserRole)
 
 	// ----------------------------
 	//  OFFICES Endpoints
 	// ----------------------------
 
-	offices := publicV1.Group("/offices", middleware.AuthMiddleware)
-	offices.Get("/branches", officesController.GetBranches)
-	offices.Get("/units", officesController.GetUnits)
+	offices := middleware.SecuredRoutes(publicV1.Group("/offices", middleware.AuthMiddleware))
+	offices.Get("/branches", "", officesController.GetBranches)
+	offices.Get("/units", "", officesController.GetUnits)
 
 }